@@ -0,0 +1,134 @@
+//go:build !nolibpcap && linux
+// +build !nolibpcap,linux
+
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/afpacket"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	defaultAfpacketRingSizeMB    = 16
+	defaultAfpacketFanoutWorkers = 4
+	afpacketBlockSize            = 1 << 17 // 128KB per TPACKET_V3 block
+)
+
+// afpacketBackend is a zero-copy Linux AF_PACKET TPACKET_V3 ring-buffer capture
+// backend. It runs cfg.FanoutWorkers goroutines sharing a PACKET_FANOUT_HASH
+// fanout group on iface, so packets are load-balanced across workers without
+// being duplicated to each of them.
+type afpacketBackend struct {
+	taps    []*afpacket.TPacket
+	packets chan gopacket.Packet
+	wg      sync.WaitGroup
+}
+
+func newAfpacketBackend(iface string, port int, mode CaptureMode, cfg CaptureConfig) (CaptureBackend, error) {
+	if iface == "" {
+		return nil, fmt.Errorf("afpacket backend requires an explicit interface")
+	}
+
+	ringSizeMB := cfg.RingSizeMB
+	if ringSizeMB <= 0 {
+		ringSizeMB = defaultAfpacketRingSizeMB
+	}
+	workers := cfg.FanoutWorkers
+	if workers <= 0 {
+		workers = defaultAfpacketFanoutWorkers
+	}
+
+	blockCount := (ringSizeMB * 1024 * 1024) / afpacketBlockSize
+	if blockCount < 1 {
+		blockCount = 1
+	}
+
+	// All taps share the same fanout group ID so the kernel hashes flows across
+	// them (PACKET_FANOUT_HASH) instead of delivering every packet to every tap.
+	fanoutGroupID := uint16(portToFanoutID(port))
+
+	b := &afpacketBackend{packets: make(chan gopacket.Packet, 1024)}
+
+	for i := 0; i < workers; i++ {
+		tap, err := afpacket.NewTPacket(
+			afpacket.OptInterface(iface),
+			afpacket.OptFrameSize(afpacketBlockSize/4),
+			afpacket.OptBlockSize(afpacketBlockSize),
+			afpacket.OptNumBlocks(blockCount),
+			afpacket.OptPollTimeout(-1),
+			afpacket.OptTPacketVersion(afpacket.TPacketVersion3),
+		)
+		if err != nil {
+			b.Close()
+			return nil, fmt.Errorf("afpacket: failed to open %s (worker %d): %v", iface, i, err)
+		}
+		if err := tap.SetFanout(afpacket.FanoutHash, fanoutGroupID); err != nil {
+			tap.Close()
+			b.Close()
+			return nil, fmt.Errorf("afpacket: failed to join fanout group on %s: %v", iface, err)
+		}
+		b.taps = append(b.taps, tap)
+
+		b.wg.Add(1)
+		go b.readLoop(tap, mode, port)
+	}
+
+	return b, nil
+}
+
+func (b *afpacketBackend) readLoop(tap *afpacket.TPacket, mode CaptureMode, port int) {
+	defer b.wg.Done()
+	source := gopacket.NewPacketSource(tap, layers.LinkTypeEthernet)
+	for packet := range source.Packets() {
+		if !afpacketMatchesMode(packet, mode, port) {
+			continue
+		}
+		b.packets <- packet
+	}
+}
+
+func (b *afpacketBackend) Packets() <-chan gopacket.Packet { return b.packets }
+
+func (b *afpacketBackend) Close() {
+	for _, tap := range b.taps {
+		tap.Close()
+	}
+	b.wg.Wait()
+	close(b.packets)
+}
+
+// afpacketMatchesMode replicates the BPF filter applied by the libpcap backend
+// (see bpfFilterForMode), since afpacket.TPacket captures raw link-layer frames
+// with no kernel-side filter attached.
+func afpacketMatchesMode(packet gopacket.Packet, mode CaptureMode, port int) bool {
+	tcpLayer := packet.Layer(layers.LayerTypeTCP)
+	if tcpLayer == nil {
+		return false
+	}
+	tcp := tcpLayer.(*layers.TCP)
+
+	switch mode {
+	case CaptureModeSYNACK:
+		return int(tcp.SrcPort) == port && tcp.SYN && tcp.ACK
+	case CaptureModeRST:
+		return int(tcp.DstPort) == port && tcp.RST
+	case CaptureModeAll:
+		return int(tcp.DstPort) == port && (tcp.SYN || tcp.RST)
+	default: // CaptureModeSYN
+		return int(tcp.DstPort) == port && tcp.SYN && !tcp.ACK
+	}
+}
+
+// portToFanoutID derives a stable, non-zero fanout group ID from the listening
+// port so unrelated capture sessions on the same host don't collide.
+func portToFanoutID(port int) int {
+	id := port & 0xffff
+	if id == 0 {
+		return 1
+	}
+	return id
+}
@@ -0,0 +1,14 @@
+//go:build !nolibpcap && !linux && (darwin || windows)
+// +build !nolibpcap
+// +build !linux
+// +build darwin windows
+
+package main
+
+import "fmt"
+
+// newAfpacketBackend is a stub on non-Linux platforms: AF_PACKET is a
+// Linux-specific socket family, so gopacket/afpacket isn't available here.
+func newAfpacketBackend(iface string, port int, mode CaptureMode, cfg CaptureConfig) (CaptureBackend, error) {
+	return nil, fmt.Errorf("afpacket capture backend is only available on Linux")
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -41,6 +44,7 @@ type FingerprintSummary struct {
 	HTTP2   string `json:"http2,omitempty"`   // HTTP/2 Akamai hash
 	TCP     string `json:"tcp,omitempty"`     // TCP 签名: "64:65535:M1460,S,T,W7"
 	TCPOS   string `json:"tcp_os,omitempty"`  // TCP 推断的 OS
+	FaviconMMH3 string `json:"favicon_mmh3,omitempty"` // Shodan-style http.favicon.hash (see favicon.go)
 }
 
 // AnalysisResult contains the complete network fingerprint analysis (for details)
@@ -50,9 +54,19 @@ type AnalysisResult struct {
 	TLSAnalysis      *TLSAnalysis           `json:"tls_analysis"`
 	HTTP2Analysis    *HTTP2Analysis         `json:"http2_analysis,omitempty"`
 	TCPAnalysis      *TCPAnalysis           `json:"tcp_analysis,omitempty"`
+	FaviconAnalysis  *FaviconAnalysis       `json:"favicon_analysis,omitempty"`
 	ConsistencyCheck *ConsistencyAnalysis   `json:"consistency_check"`
 	SecurityAdvice   *SecurityAdvice        `json:"security_advice"`
 	RawFingerprint   *CombinedFingerprint   `json:"raw_fingerprint"`
+
+	// RecogFacts collects every Recog-style database hit (see recog.go)
+	// across JA3, HTTP/2 and UA matching, each carrying its own provenance
+	// ("recog:ja3"/"recog:http2"/"recog:ua") and certainty.
+	RecogFacts []RecogMatch `json:"recog_facts,omitempty"`
+
+	// VersionFindings lists every VersionRule (see policy.go) the claimed
+	// browser/OS version violated - outdated, EOL, or impossibly-new/spoofed.
+	VersionFindings []VersionFinding `json:"version_findings,omitempty"`
 }
 
 // RequestInfo shows what data was used for analysis
@@ -60,9 +74,33 @@ type RequestInfo struct {
 	ClientIP        string            `json:"client_ip"`
 	UserAgent       string            `json:"user_agent,omitempty"`
 	UserAgentParsed *ParsedUserAgent  `json:"user_agent_parsed,omitempty"`
+	ClientHints     *ClientHints      `json:"client_hints,omitempty"`
 	Headers         map[string]string `json:"headers,omitempty"`
 }
 
+// ClientHints holds the User-Agent Client Hints (UA-CH) a modern Chromium
+// browser sends alongside (or instead of) the legacy User-Agent string. Pure
+// "Chrome-like" impersonators frequently omit these or leave them internally
+// inconsistent with the legacy UA, which is what analyzeClientHints checks for.
+type ClientHints struct {
+	Present         bool             `json:"present"`                     // any Sec-CH-UA* header was seen
+	Brands          []UABrandVersion `json:"brands,omitempty"`            // Sec-CH-UA
+	FullVersionList []UABrandVersion `json:"full_version_list,omitempty"` // Sec-CH-UA-Full-Version-List
+	Mobile          *bool            `json:"mobile,omitempty"`            // Sec-CH-UA-Mobile ("?0"/"?1")
+	Platform        string           `json:"platform,omitempty"`          // Sec-CH-UA-Platform
+	PlatformVersion string           `json:"platform_version,omitempty"`  // Sec-CH-UA-Platform-Version
+	Arch            string           `json:"arch,omitempty"`              // Sec-CH-UA-Arch
+	Bitness         string           `json:"bitness,omitempty"`           // Sec-CH-UA-Bitness
+	Model           string           `json:"model,omitempty"`             // Sec-CH-UA-Model
+}
+
+// UABrandVersion is one (brand, version) pair from a Sec-CH-UA* structured
+// header list, e.g. `"Chromium";v="131"`.
+type UABrandVersion struct {
+	Brand   string `json:"brand"`
+	Version string `json:"version"`
+}
+
 // ParsedUserAgent contains parsed User-Agent information
 type ParsedUserAgent struct {
 	Browser        string `json:"browser,omitempty"`
@@ -70,8 +108,30 @@ type ParsedUserAgent struct {
 	OS             string `json:"os,omitempty"`
 	OSVersion      string `json:"os_version,omitempty"`
 	Platform       string `json:"platform,omitempty"`    // Desktop, Mobile, Tablet
+	DeviceType     string `json:"device_type,omitempty"` // Phone, Tablet, Desktop, TV, Console, Wearable
+	DeviceVendor   string `json:"device_vendor,omitempty"`
+	EngineName     string `json:"engine_name,omitempty"`    // Blink, Gecko, WebKit, Trident
+	EngineVersion  string `json:"engine_version,omitempty"`
 	IsBot          bool   `json:"is_bot"`
 	BotName        string `json:"bot_name,omitempty"`
+
+	// WebView is set when the UA identifies an in-app browser/embedded
+	// WebView rather than a standalone browser - see detectWebView.
+	WebView *WebViewInfo `json:"webview,omitempty"`
+}
+
+// WebViewInfo describes an in-app browser or embedded WebView detected in a
+// UA string: a social/chat app's own Chromium-or-WebKit-based browser
+// (Facebook, Instagram, TikTok, ...), the Android system WebView, an
+// Electron-based desktop app, or an iOS third-party browser forced onto
+// Apple's WebKit (CriOS/FxiOS/EdgiOS). These legitimately carry a TLS/HTTP2
+// fingerprint that doesn't match their claimed Browser field, which
+// analyzeConsistency treats as expected rather than a spoofing signal.
+type WebViewInfo struct {
+	IsWebView      bool   `json:"is_webview"`
+	HostApp        string `json:"host_app,omitempty"`
+	HostAppVersion string `json:"host_app_version,omitempty"`
+	EmbeddedEngine string `json:"embedded_engine,omitempty"` // WebKit, Blink, Chromium Embedded Framework (Electron), ...
 }
 
 // AnalysisSummary provides a high-level overview
@@ -82,6 +142,7 @@ type AnalysisSummary struct {
 	DetectedOS      string   `json:"detected_os"`       // Windows, macOS, Linux, etc.
 	IsBot           bool     `json:"is_bot"`            // Likely automated client
 	IsSpoofed       bool     `json:"is_spoofed"`        // Fingerprint appears manipulated
+	IsTorBrowser    bool     `json:"is_tor_browser"`    // Tor Browser, not a spoofed Firefox ESR (see torbrowser.go)
 	Warnings        []string `json:"warnings,omitempty"`
 }
 
@@ -95,6 +156,20 @@ type TLSAnalysis struct {
 	JA4Popularity   string   `json:"ja4_popularity"`
 	CipherStrength  string   `json:"cipher_strength"`   // Strong, Medium, Weak
 	Observations    []string `json:"observations"`
+	FuzzyMatches    []FuzzyJA3Match `json:"fuzzy_matches,omitempty"` // nearest corpus neighbors when JA3 hash misses
+
+	// MITM* are EvaluateMITMFingerprint's verdict on the claimed UA vs the
+	// observed ClientHello (see mitm.go) - match/likely_mitm/unknown_ua/bad_header.
+	MITMVerdict string   `json:"mitm_verdict,omitempty"`
+	MITMScore   float64  `json:"mitm_score,omitempty"`
+	MITMDetails []string `json:"mitm_details,omitempty"`
+
+	// IsTorBrowser and TorBrowserESR are DetectTorBrowser's verdict (see
+	// torbrowser.go): the UA claims a Firefox ESR version whose ClientHello
+	// actually matches Tor Browser's fixed signature for that release, not
+	// mainline Firefox.
+	IsTorBrowser  bool   `json:"is_tor_browser,omitempty"`
+	TorBrowserESR string `json:"tor_browser_esr,omitempty"`
 }
 
 // HTTP2Analysis analyzes HTTP/2 fingerprint
@@ -103,6 +178,20 @@ type HTTP2Analysis struct {
 	ClientMatch     string   `json:"client_match,omitempty"`  // Matches Chrome/Firefox/etc.
 	IsImpersonator  bool     `json:"is_impersonator"`         // Detected as curl-impersonate or similar
 	ImpersonatorType string  `json:"impersonator_type,omitempty"` // curl-impersonate, tls-client, etc.
+
+	// Candidates is ClassifyHTTP2's top-3 scored profile matches (see
+	// http2profile.go), ranked highest-confidence first. ClientMatch/
+	// IsImpersonator above stay as the single-best-guess summary existing
+	// callers rely on; this is the evidence behind it.
+	Candidates []HTTP2Candidate `json:"candidates,omitempty"`
+
+	// ClosestBrowser and FieldDiff are DiffAgainstClosestBrowser's result:
+	// the nearest real-browser profile and exactly which
+	// buildAkamaiFingerprint fields diverge from it, regardless of which
+	// category Candidates' top entry landed in.
+	ClosestBrowser string   `json:"closest_browser,omitempty"`
+	FieldDiff      []string `json:"field_diff,omitempty"`
+
 	Observations    []string `json:"observations"`
 }
 
@@ -117,10 +206,16 @@ type TCPAnalysis struct {
 
 // ConsistencyAnalysis checks cross-layer consistency
 type ConsistencyAnalysis struct {
-	Passed     bool     `json:"passed"`
-	Score      int      `json:"score"`        // 0-100
-	Anomalies  []string `json:"anomalies,omitempty"`
-	Details    []string `json:"details"`
+	Passed    bool     `json:"passed"`
+	Score     int      `json:"score"` // 0-100
+	Anomalies []string `json:"anomalies,omitempty"`
+	Details   []string `json:"details"`
+
+	// RuleFired lists the ConsistencyRule IDs (see consistency_rules.go)
+	// that fired for this request, in the order they were evaluated - a
+	// SIEM can alert on a specific rule ID here instead of pattern-matching
+	// Anomalies strings.
+	RuleFired []string `json:"rule_fired,omitempty"`
 }
 
 // SecurityAdvice provides recommendations
@@ -143,8 +238,11 @@ type AdviceItem struct {
 // See database.go for the loading logic and data structures
 // Files: ja3_fingerprints.json, ja4_fingerprints.json, http2_fingerprints.json
 
-// AnalyzeFingerprint performs comprehensive analysis
-func AnalyzeFingerprint(fp *CombinedFingerprint, clientIP string, userAgent string) *AnalysisResult {
+// AnalyzeFingerprint performs comprehensive analysis. headers carries the raw
+// request headers (keyed case-insensitively) so phases like
+// analyzeClientHints can inspect headers beyond User-Agent; it may be nil
+// when none were captured (e.g. synthetic /api/decode requests).
+func AnalyzeFingerprint(fp *CombinedFingerprint, clientIP string, userAgent string, headers map[string]string) *AnalysisResult {
 	result := &AnalysisResult{
 		Summary:          &AnalysisSummary{},
 		RequestInfo:      &RequestInfo{ClientIP: clientIP},
@@ -158,6 +256,25 @@ func AnalyzeFingerprint(fp *CombinedFingerprint, clientIP string, userAgent stri
 	if userAgent != "" {
 		result.RequestInfo.UserAgent = userAgent
 		result.RequestInfo.UserAgentParsed = parseUserAgent(userAgent)
+
+		// Recog-style UA fingerprints are additive to the Go evaluators in
+		// parseUserAgent above - an operator-maintained rule can assert facts
+		// (e.g. hw.device, os.version) the built-in parser doesn't extract.
+		if recogMatch, found := GetDatabase().LookupUA(userAgent); found {
+			result.RecogFacts = append(result.RecogFacts, recogMatch)
+		}
+	}
+
+	// Parse and store Client Hints, if the request sent any
+	if hints := parseClientHints(headers); hints != nil {
+		result.RequestInfo.ClientHints = hints
+	}
+
+	// Favicon fingerprinting (see favicon.go) only makes sense once we know
+	// which origin sent the client here - the Referer (or, failing that,
+	// Origin) header.
+	if origin := originFromHeaders(headers); origin != "" {
+		result.FaviconAnalysis = AnalyzeFavicon(origin, "")
 	}
 
 	if fp == nil || fp.TLS == nil {
@@ -171,7 +288,7 @@ func AnalyzeFingerprint(fp *CombinedFingerprint, clientIP string, userAgent stri
 
 	// Analyze HTTP/2
 	if fp.HTTP2 != nil {
-		result.HTTP2Analysis = analyzeHTTP2(fp.HTTP2)
+		result.HTTP2Analysis = analyzeHTTP2(fp.HTTP2, result)
 	}
 
 	// Analyze TCP/IP
@@ -182,6 +299,12 @@ func AnalyzeFingerprint(fp *CombinedFingerprint, clientIP string, userAgent stri
 	// Cross-layer consistency check (enhanced)
 	analyzeConsistency(fp, result, userAgent)
 
+	// Client Hints vs legacy UA / TCP consistency
+	analyzeClientHints(fp, result)
+
+	// Outdated/EOL/spoofed browser version checks (see policy.go)
+	analyzeVersionPolicy(result)
+
 	// Generate summary
 	generateSummary(result, userAgent)
 
@@ -191,7 +314,63 @@ func AnalyzeFingerprint(fp *CombinedFingerprint, clientIP string, userAgent stri
 	return result
 }
 
-// parseUserAgent extracts browser, OS, and platform info from User-Agent string
+// botPatterns maps a lowercase UA substring to the bot/library display name.
+var botPatterns = map[string]string{
+	"googlebot":           "Googlebot",
+	"bingbot":             "Bingbot",
+	"slurp":               "Yahoo Slurp",
+	"duckduckbot":         "DuckDuckBot",
+	"baiduspider":         "Baiduspider",
+	"yandexbot":           "YandexBot",
+	"facebookexternalhit": "Facebook",
+	"twitterbot":          "Twitterbot",
+	"curl/":               "curl",
+	"wget/":               "Wget",
+	"python-requests":     "Python Requests",
+	"python-urllib":       "Python urllib",
+	"go-http-client":      "Go HTTP Client",
+	"java/":               "Java",
+	"apache-httpclient":   "Apache HttpClient",
+	"okhttp":              "OkHttp",
+	"axios":               "Axios",
+	"node-fetch":          "Node Fetch",
+	"scrapy":              "Scrapy",
+	"headless":            "Headless Browser",
+	"phantomjs":           "PhantomJS",
+	"selenium":            "Selenium",
+	"puppeteer":           "Puppeteer",
+	"playwright":          "Playwright",
+}
+
+// Per-evaluator version regexes. Each platform evaluator owns the one(s) it
+// needs, mirroring the field layouts browsers actually put in that slot of
+// the UA string.
+var (
+	windowsPhoneVersionRe = regexp.MustCompile(`Windows Phone(?: OS)? ([\d.]+)`)
+	windowsNTVersionRe    = regexp.MustCompile(`Windows NT ([\d.]+)`)
+	macOSXVersionRe       = regexp.MustCompile(`Mac OS X ([\d_.]+)`)
+	iOSVersionRe          = regexp.MustCompile(`CPU (?:iPhone )?OS ([\d_]+)`)
+	androidVersionRe      = regexp.MustCompile(`Android ([\d.]+)`)
+	blackBerryVersionRe   = regexp.MustCompile(`Version/([\d.]+)`)
+	chromeOSVersionRe     = regexp.MustCompile(`CrOS \S+ ([\d.]+)`)
+	playStationVersionRe  = regexp.MustCompile(`PlayStation \d+/([\d.]+)`)
+	xboxVersionRe         = regexp.MustCompile(`Xbox; Xbox (\S+)`)
+
+	chromeVersionRe  = regexp.MustCompile(`Chrome/([\d.]+)`)
+	geckoVersionRe   = regexp.MustCompile(`Gecko/(\d+)`)
+	webkitVersionRe  = regexp.MustCompile(`AppleWebKit/([\d.]+)`)
+	tridentVersionRe = regexp.MustCompile(`Trident/([\d.]+)`)
+
+	// kindleFireRe spots Fire/Kindle model codes (e.g. "KFTHWI", "SD4930UR")
+	// sitting inside the platform token, padded with spaces so the \s
+	// boundaries match the token edges too.
+	kindleFireRe = regexp.MustCompile(`\s(k[a-z]{3,5}|sd\d{4}ur)\s`)
+)
+
+// parseUserAgent extracts browser, OS, device, and engine info from a
+// User-Agent string. It first pulls out the parenthesized platform token and
+// dispatches to a per-OS evaluator, then separately detects browser and
+// rendering engine from the full string.
 func parseUserAgent(ua string) *ParsedUserAgent {
 	if ua == "" {
 		return nil
@@ -201,33 +380,6 @@ func parseUserAgent(ua string) *ParsedUserAgent {
 	uaLower := strings.ToLower(ua)
 
 	// Detect bots first
-	botPatterns := map[string]string{
-		"googlebot":   "Googlebot",
-		"bingbot":     "Bingbot",
-		"slurp":       "Yahoo Slurp",
-		"duckduckbot": "DuckDuckBot",
-		"baiduspider": "Baiduspider",
-		"yandexbot":   "YandexBot",
-		"facebookexternalhit": "Facebook",
-		"twitterbot":  "Twitterbot",
-		"curl/":       "curl",
-		"wget/":       "Wget",
-		"python-requests": "Python Requests",
-		"python-urllib": "Python urllib",
-		"go-http-client": "Go HTTP Client",
-		"java/":       "Java",
-		"apache-httpclient": "Apache HttpClient",
-		"okhttp":      "OkHttp",
-		"axios":       "Axios",
-		"node-fetch":  "Node Fetch",
-		"scrapy":      "Scrapy",
-		"headless":    "Headless Browser",
-		"phantomjs":   "PhantomJS",
-		"selenium":    "Selenium",
-		"puppeteer":   "Puppeteer",
-		"playwright":  "Playwright",
-	}
-
 	for pattern, name := range botPatterns {
 		if strings.Contains(uaLower, pattern) {
 			parsed.IsBot = true
@@ -236,8 +388,255 @@ func parseUserAgent(ua string) *ParsedUserAgent {
 		}
 	}
 
-	// Detect browser
+	tokens := platformTokens(ua)
+	dispatchPlatformEvaluator(tokens, ua, parsed)
+	detectKindleFire(extractPlatformToken(ua), parsed)
+	detectBrowser(uaLower, ua, parsed)
+	detectEngine(uaLower, ua, parsed)
+	detectWebView(tokens, uaLower, ua, parsed)
+
+	parsed.Platform = platformFromDeviceType(parsed.DeviceType)
+
+	return parsed
+}
+
+// extractPlatformToken returns the contents of the first "(...)" group in ua,
+// e.g. "Windows NT 10.0; Win64; x64" - the section every browser uses to
+// describe the platform it's running on.
+func extractPlatformToken(ua string) string {
+	start := strings.Index(ua, "(")
+	if start == -1 {
+		return ""
+	}
+	rest := ua[start+1:]
+	end := strings.Index(rest, ")")
+	if end == -1 {
+		return rest
+	}
+	return rest[:end]
+}
+
+// platformTokens splits the platform token on ";" into trimmed, lowercased
+// fields for the evaluators to inspect.
+func platformTokens(ua string) []string {
+	raw := strings.Split(extractPlatformToken(ua), ";")
+	tokens := make([]string, 0, len(raw))
+	for _, t := range raw {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+func tokensContain(tokens []string, substr string) bool {
+	for _, t := range tokens {
+		if strings.Contains(t, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func tokensHavePrefix(tokens []string, prefix string) bool {
+	for _, t := range tokens {
+		if strings.HasPrefix(t, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// dispatchPlatformEvaluator routes to the per-OS evaluator that matches the
+// platform token. Game consoles are checked first because some (Xbox) embed
+// a "Windows NT" token in their platform string that would otherwise be
+// caught by evalWindows.
+func dispatchPlatformEvaluator(tokens []string, ua string, parsed *ParsedUserAgent) {
+	switch {
+	case tokensContain(tokens, "playstation") || tokensContain(tokens, "xbox") || tokensContain(tokens, "nintendo"):
+		evalConsole(tokens, ua, parsed)
+	case tokensContain(tokens, "windows phone"):
+		evalWindowsPhone(ua, parsed)
+	case tokensContain(tokens, "windows"):
+		evalWindows(ua, parsed)
+	case tokensContain(tokens, "cros"):
+		evalChromeOS(ua, parsed)
+	case tokensContain(tokens, "macintosh"):
+		evalMacintosh(ua, parsed)
+	case tokensHavePrefix(tokens, "iphone") || tokensHavePrefix(tokens, "ipad") || tokensHavePrefix(tokens, "ipod"):
+		evaliOS(tokens, ua, parsed)
+	case tokensHavePrefix(tokens, "blackberry") || tokensContain(tokens, "bb10"):
+		evalBlackBerry(ua, parsed)
+	case tokensContain(tokens, "linux") || tokensContain(tokens, "android"):
+		evalLinux(tokens, ua, parsed)
+	}
+}
+
+func evalWindowsPhone(ua string, parsed *ParsedUserAgent) {
+	parsed.OS = "Windows Phone"
+	parsed.DeviceType = "Phone"
+	parsed.DeviceVendor = "Microsoft"
+	if m := windowsPhoneVersionRe.FindStringSubmatch(ua); m != nil {
+		parsed.OSVersion = m[1]
+	}
+}
+
+func evalWindows(ua string, parsed *ParsedUserAgent) {
+	parsed.OS = "Windows"
+	parsed.DeviceType = "Desktop"
+	m := windowsNTVersionRe.FindStringSubmatch(ua)
+	if m == nil {
+		return
+	}
+	switch m[1] {
+	case "10.0":
+		parsed.OSVersion = "10/11"
+	case "6.3":
+		parsed.OSVersion = "8.1"
+	case "6.2":
+		parsed.OSVersion = "8"
+	case "6.1":
+		parsed.OSVersion = "7"
+	default:
+		parsed.OSVersion = m[1]
+	}
+}
+
+func evalMacintosh(ua string, parsed *ParsedUserAgent) {
+	parsed.OS = "macOS"
+	parsed.DeviceType = "Desktop"
+	parsed.DeviceVendor = "Apple"
+	if m := macOSXVersionRe.FindStringSubmatch(ua); m != nil {
+		parsed.OSVersion = strings.ReplaceAll(m[1], "_", ".")
+	}
+}
+
+// evaliOS handles iPhone/iPad/iPod platform tokens. Deriving DeviceType from
+// the platform token (rather than a "mobile" substring search over the whole
+// UA) is what keeps an iPad correctly classified as a Tablet even though its
+// browser portion carries a "Mobile Safari" token.
+func evaliOS(tokens []string, ua string, parsed *ParsedUserAgent) {
+	parsed.OS = "iOS"
+	parsed.DeviceVendor = "Apple"
+	switch {
+	case tokensHavePrefix(tokens, "ipad"):
+		parsed.DeviceType = "Tablet"
+	default:
+		parsed.DeviceType = "Phone"
+	}
+	if m := iOSVersionRe.FindStringSubmatch(ua); m != nil {
+		parsed.OSVersion = strings.ReplaceAll(m[1], "_", ".")
+	}
+}
+
+// evalLinux handles both plain Linux desktops and Android (which embeds
+// "Linux" in its platform token). Android phones carry a "Mobile" token in
+// the browser portion of the UA; tablets omit it.
+func evalLinux(tokens []string, ua string, parsed *ParsedUserAgent) {
+	if !tokensContain(tokens, "android") {
+		parsed.OS = "Linux"
+		parsed.DeviceType = "Desktop"
+		return
+	}
+	parsed.OS = "Android"
+	parsed.DeviceVendor = "Google"
+	if m := androidVersionRe.FindStringSubmatch(ua); m != nil {
+		parsed.OSVersion = m[1]
+	}
+	switch {
+	case strings.Contains(ua, "Mobile"):
+		parsed.DeviceType = "Phone"
+	case strings.Contains(ua, "TV"):
+		parsed.DeviceType = "TV"
+	default:
+		parsed.DeviceType = "Tablet"
+	}
+}
+
+func evalBlackBerry(ua string, parsed *ParsedUserAgent) {
+	parsed.OS = "BlackBerry"
+	parsed.DeviceVendor = "BlackBerry"
+	parsed.DeviceType = "Phone"
+	if m := blackBerryVersionRe.FindStringSubmatch(ua); m != nil {
+		parsed.OSVersion = m[1]
+	}
+}
+
+func evalChromeOS(ua string, parsed *ParsedUserAgent) {
+	parsed.OS = "ChromeOS"
+	parsed.DeviceType = "Desktop"
+	parsed.DeviceVendor = "Google"
+	if m := chromeOSVersionRe.FindStringSubmatch(ua); m != nil {
+		parsed.OSVersion = m[1]
+	}
+}
+
+func evalConsole(tokens []string, ua string, parsed *ParsedUserAgent) {
+	parsed.DeviceType = "Console"
+	switch {
+	case tokensContain(tokens, "playstation"):
+		parsed.OS = "PlayStation OS"
+		parsed.DeviceVendor = "Sony"
+		if m := playStationVersionRe.FindStringSubmatch(ua); m != nil {
+			parsed.OSVersion = m[1]
+		}
+	case tokensContain(tokens, "xbox"):
+		parsed.OS = "Xbox OS"
+		parsed.DeviceVendor = "Microsoft"
+		if m := xboxVersionRe.FindStringSubmatch(ua); m != nil {
+			parsed.OSVersion = m[1]
+		}
+	case tokensContain(tokens, "nintendo"):
+		parsed.OS = "Nintendo OS"
+		parsed.DeviceVendor = "Nintendo"
+	}
+}
+
+// detectKindleFire looks for Amazon Fire/Kindle model codes (e.g. "KFTHWI",
+// "SD4930UR") in the platform token. These ship as Android devices, so by the
+// time this runs evalLinux has usually already set DeviceType; this only
+// fills in the vendor and upgrades an unset/Phone guess to Tablet, since
+// nearly all Fire hardware is a tablet.
+func detectKindleFire(platformToken string, parsed *ParsedUserAgent) {
+	padded := " " + strings.ToLower(platformToken) + " "
+	if !kindleFireRe.MatchString(padded) {
+		return
+	}
+	parsed.DeviceVendor = "Amazon"
+	if parsed.DeviceType == "" || parsed.DeviceType == "Phone" {
+		parsed.DeviceType = "Tablet"
+	}
+}
+
+// platformFromDeviceType maps the richer DeviceType down to the legacy
+// Desktop/Mobile/Tablet Platform field that existing callers (analyzeConsistency,
+// buildClientInfo) already key off of.
+func platformFromDeviceType(deviceType string) string {
+	switch deviceType {
+	case "Phone":
+		return "Mobile"
+	case "":
+		return "Desktop"
+	default:
+		return deviceType
+	}
+}
+
+// detectBrowser identifies the browser family/version from the full UA string.
+func detectBrowser(uaLower, ua string, parsed *ParsedUserAgent) {
 	switch {
+	case strings.Contains(uaLower, "edgios/"):
+		// iOS forces every third-party browser onto WebKit, so EdgiOS/CriOS/
+		// FxiOS never carry their desktop engine's own token (e.g. "Edg/").
+		parsed.Browser = "Edge"
+		parsed.BrowserVersion = extractVersion(ua, "EdgiOS/")
+	case strings.Contains(uaLower, "crios/"):
+		parsed.Browser = "Chrome"
+		parsed.BrowserVersion = extractVersion(ua, "CriOS/")
+	case strings.Contains(uaLower, "fxios/"):
+		parsed.Browser = "Firefox"
+		parsed.BrowserVersion = extractVersion(ua, "FxiOS/")
 	case strings.Contains(uaLower, "edg/"):
 		parsed.Browser = "Edge"
 		parsed.BrowserVersion = extractVersion(ua, "Edg/")
@@ -256,49 +655,98 @@ func parseUserAgent(ua string) *ParsedUserAgent {
 	case strings.Contains(uaLower, "msie") || strings.Contains(uaLower, "trident"):
 		parsed.Browser = "Internet Explorer"
 	}
+}
 
-	// Detect OS
+// detectEngine identifies the rendering engine family/version. This is kept
+// independent of detectBrowser so analyzeConsistency can flag engine/UA
+// mismatches (e.g. a UA claiming iOS Safari but an engine string of Blink).
+func detectEngine(uaLower, ua string, parsed *ParsedUserAgent) {
 	switch {
-	case strings.Contains(uaLower, "windows nt 10"):
-		parsed.OS = "Windows"
-		parsed.OSVersion = "10/11"
-	case strings.Contains(uaLower, "windows nt 6.3"):
-		parsed.OS = "Windows"
-		parsed.OSVersion = "8.1"
-	case strings.Contains(uaLower, "windows nt 6.1"):
-		parsed.OS = "Windows"
-		parsed.OSVersion = "7"
-	case strings.Contains(uaLower, "windows"):
-		parsed.OS = "Windows"
-	case strings.Contains(uaLower, "mac os x"):
-		parsed.OS = "macOS"
-		// Extract version like "Mac OS X 10_15_7" -> "10.15.7"
-		if idx := strings.Index(ua, "Mac OS X "); idx != -1 {
-			verStr := ua[idx+9:]
-			if endIdx := strings.IndexAny(verStr, ");"); endIdx != -1 {
-				parsed.OSVersion = strings.ReplaceAll(verStr[:endIdx], "_", ".")
-			}
+	case strings.Contains(uaLower, "trident") || strings.Contains(uaLower, "msie"):
+		parsed.EngineName = "Trident"
+		if m := tridentVersionRe.FindStringSubmatch(ua); m != nil {
+			parsed.EngineVersion = m[1]
+		}
+	case strings.Contains(uaLower, "gecko/") && !strings.Contains(uaLower, "like gecko"):
+		parsed.EngineName = "Gecko"
+		if m := geckoVersionRe.FindStringSubmatch(ua); m != nil {
+			parsed.EngineVersion = m[1]
+		}
+	case strings.Contains(uaLower, "chrome/") || strings.Contains(uaLower, "chromium/") || strings.Contains(uaLower, "crios/"):
+		parsed.EngineName = "Blink"
+		if m := chromeVersionRe.FindStringSubmatch(ua); m != nil {
+			parsed.EngineVersion = m[1]
+		}
+	case strings.Contains(uaLower, "applewebkit"):
+		parsed.EngineName = "WebKit"
+		if m := webkitVersionRe.FindStringSubmatch(ua); m != nil {
+			parsed.EngineVersion = m[1]
 		}
-	case strings.Contains(uaLower, "iphone") || strings.Contains(uaLower, "ipad"):
-		parsed.OS = "iOS"
-	case strings.Contains(uaLower, "android"):
-		parsed.OS = "Android"
-		parsed.OSVersion = extractVersion(ua, "Android ")
-	case strings.Contains(uaLower, "linux"):
-		parsed.OS = "Linux"
 	}
+}
+
+// detectWebView flags in-app browsers and embedded WebViews: social/chat
+// apps with their own embedded browser (Facebook, Instagram, TikTok, WeChat,
+// Line, Snapchat, Twitter, Google Search App), the Android system WebView
+// token ("; wv)"), Electron-based desktop apps, and the iOS third-party
+// browsers Apple forces onto its own WebKit (CriOS/FxiOS/EdgiOS, already
+// identified as Chrome/Firefox/Edge by detectBrowser above). Runs after
+// detectBrowser/detectEngine so analyzeConsistency can compare what the UA
+// claims against what it actually is.
+func detectWebView(tokens []string, uaLower, ua string, parsed *ParsedUserAgent) {
+	switch {
+	case strings.Contains(uaLower, "fban/") || strings.Contains(uaLower, "fbav/"):
+		parsed.WebView = &WebViewInfo{IsWebView: true, HostApp: "Facebook", HostAppVersion: extractVersion(ua, "FBAV/"), EmbeddedEngine: "WebKit/Blink"}
+	case strings.Contains(uaLower, "instagram "):
+		parsed.WebView = &WebViewInfo{IsWebView: true, HostApp: "Instagram", HostAppVersion: extractVersion(ua, "Instagram "), EmbeddedEngine: "WebKit/Blink"}
+	case strings.Contains(uaLower, "musical_ly"):
+		parsed.WebView = &WebViewInfo{IsWebView: true, HostApp: "TikTok", EmbeddedEngine: "WebKit/Blink"}
+	case strings.Contains(uaLower, "micromessenger/"):
+		parsed.WebView = &WebViewInfo{IsWebView: true, HostApp: "WeChat", HostAppVersion: extractVersion(ua, "MicroMessenger/"), EmbeddedEngine: "WebKit/Blink"}
+	case strings.Contains(uaLower, "line/"):
+		parsed.WebView = &WebViewInfo{IsWebView: true, HostApp: "Line", HostAppVersion: extractVersion(ua, "Line/"), EmbeddedEngine: "WebKit/Blink"}
+	case strings.Contains(uaLower, "snapchat/"):
+		parsed.WebView = &WebViewInfo{IsWebView: true, HostApp: "Snapchat", HostAppVersion: extractVersion(ua, "Snapchat/"), EmbeddedEngine: "WebKit/Blink"}
+	case strings.Contains(uaLower, "twitter for") || strings.Contains(uaLower, "twitterandroid"):
+		parsed.WebView = &WebViewInfo{IsWebView: true, HostApp: "Twitter", EmbeddedEngine: "WebKit/Blink"}
+	case strings.Contains(uaLower, "gsa/"):
+		parsed.WebView = &WebViewInfo{IsWebView: true, HostApp: "Google Search App", HostAppVersion: extractVersion(ua, "GSA/"), EmbeddedEngine: "WebKit/Blink"}
+	case strings.Contains(uaLower, "electron/"):
+		parsed.WebView = &WebViewInfo{IsWebView: true, HostApp: electronHostApp(uaLower), HostAppVersion: extractVersion(ua, "Electron/"), EmbeddedEngine: "Chromium Embedded Framework (Electron)"}
+	case tokensEqual(tokens, "wv"):
+		parsed.WebView = &WebViewInfo{IsWebView: true, EmbeddedEngine: "Blink (Android System WebView)"}
+	case strings.Contains(uaLower, "crios/") || strings.Contains(uaLower, "fxios/") || strings.Contains(uaLower, "edgios/"):
+		parsed.WebView = &WebViewInfo{IsWebView: true, EmbeddedEngine: "WebKit (Apple-mandated iOS browser engine)"}
+	}
+}
 
-	// Detect platform type
+// electronHostApp recognizes a handful of well-known Electron desktop apps
+// by the strings they leave in their UA; anything else just says "Electron app".
+func electronHostApp(uaLower string) string {
 	switch {
-	case strings.Contains(uaLower, "mobile") || strings.Contains(uaLower, "iphone") || strings.Contains(uaLower, "android"):
-		parsed.Platform = "Mobile"
-	case strings.Contains(uaLower, "ipad") || strings.Contains(uaLower, "tablet"):
-		parsed.Platform = "Tablet"
+	case strings.Contains(uaLower, "vscode"):
+		return "VSCode"
+	case strings.Contains(uaLower, "slack"):
+		return "Slack"
+	case strings.Contains(uaLower, "discord"):
+		return "Discord"
+	case strings.Contains(uaLower, "mattermost"):
+		return "Mattermost Desktop"
 	default:
-		parsed.Platform = "Desktop"
+		return "Electron app"
 	}
+}
 
-	return parsed
+// tokensEqual reports whether any platform token is exactly value (as
+// opposed to tokensContain's substring match), for short marker tokens like
+// the Android WebView's "wv" that would false-positive on a substring check.
+func tokensEqual(tokens []string, value string) bool {
+	for _, t := range tokens {
+		if t == value {
+			return true
+		}
+	}
+	return false
 }
 
 // extractVersion extracts version number after a prefix
@@ -318,6 +766,40 @@ func extractVersion(ua, prefix string) string {
 	return ""
 }
 
+// describeWebView renders a WebViewInfo for use in an observation string,
+// e.g. "Instagram's in-app WebView" or "an iOS WebKit wrapper".
+func describeWebView(wv *WebViewInfo) string {
+	if wv.HostApp != "" {
+		return wv.HostApp + "'s in-app WebView"
+	}
+	return wv.EmbeddedEngine
+}
+
+// descriptionSuffix renders a recog fingerprint's description as " (<desc>)"
+// for an observation string, or "" if it didn't have one.
+func descriptionSuffix(description string) string {
+	if description == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", description)
+}
+
+// formatRecogFacts renders a recog fact map as "key=value, key=value" in
+// sorted key order, for deterministic observation strings.
+func formatRecogFacts(facts map[string]string) string {
+	keys := make([]string, 0, len(facts))
+	for k := range facts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%s", k, facts[k]))
+	}
+	return strings.Join(parts, ", ")
+}
+
 func analyzeTLS(tls *TLSFingerprint, result *AnalysisResult, userAgent string) {
 	analysis := result.TLSAnalysis
 	db := GetDatabase()
@@ -335,8 +817,9 @@ func analyzeTLS(tls *TLSFingerprint, result *AnalysisResult, userAgent string) {
 	}
 
 	// Check JA3 hash against database
-	if clientName, clientType, found := db.LookupJA3(tls.JA3Hash); found {
+	if clientName, clientType, clientVersion, found := db.LookupJA3(tls.JA3Hash); found {
 		analysis.ClientName = clientName
+		analysis.ClientVersion = clientVersion
 		analysis.JA3Popularity = "Known"
 		analysis.Observations = append(analysis.Observations, fmt.Sprintf("JA3 matches known client: %s (type: %s)", clientName, clientType))
 
@@ -348,6 +831,33 @@ func analyzeTLS(tls *TLSFingerprint, result *AnalysisResult, userAgent string) {
 	} else {
 		analysis.JA3Popularity = "Unknown"
 		analysis.Observations = append(analysis.Observations, "JA3 hash not in database - could be modified or uncommon client")
+
+		// Exact hash missed - fall back to nearest-neighbor scoring against
+		// the fuzzy-match corpus so a near-identical ClientHello (e.g. one
+		// reordered extension) still attributes instead of staying unknown.
+		if matches := db.FuzzyMatchJA3(tls, 3); len(matches) > 0 {
+			analysis.FuzzyMatches = matches
+			if matches[0].Score >= fuzzyJA3ConfidentThreshold {
+				analysis.ClientName = matches[0].Label
+				analysis.JA3Popularity = fmt.Sprintf("Fuzzy match (%.0f%% confidence)", matches[0].Score*100)
+				analysis.Observations = append(analysis.Observations,
+					fmt.Sprintf("JA3 hash unknown but closely matches %s (score %.2f)", matches[0].Label, matches[0].Score))
+			}
+		}
+
+		// Still unknown - try the operator-maintained Recog fingerprints
+		// (./data/recog/, see recog.go), which match on the readable JA3
+		// string rather than its hash.
+		if recogMatch, found := db.LookupJA3Recog(tls.JA3); found {
+			result.RecogFacts = append(result.RecogFacts, recogMatch)
+			analysis.Observations = append(analysis.Observations,
+				fmt.Sprintf("JA3 matches recog fingerprint%s (certainty %.2f): %s",
+					descriptionSuffix(recogMatch.Description), recogMatch.Certainty, formatRecogFacts(recogMatch.Facts)))
+			if product, ok := recogMatch.Facts["service.family"]; ok && analysis.ClientName == "" {
+				analysis.ClientName = product
+				analysis.JA3Popularity = fmt.Sprintf("Recog match (certainty %.2f)", recogMatch.Certainty)
+			}
+		}
 	}
 
 	// Analyze JA4 using database
@@ -361,11 +871,36 @@ func analyzeTLS(tls *TLSFingerprint, result *AnalysisResult, userAgent string) {
 		}
 	}
 
+	// Corpus-backed MITM classification: does the observed ClientHello
+	// actually look like the browser/version the UA claims? (see mitm.go)
+	if parsedUA := result.RequestInfo.UserAgentParsed; parsedUA != nil && parsedUA.Browser != "" {
+		verdict, score, details := EvaluateMITMFingerprint(db.GetMITM(), parsedUA.Browser, majorVersion(parsedUA.BrowserVersion), tls)
+		analysis.MITMVerdict = string(verdict)
+		analysis.MITMScore = score
+		analysis.MITMDetails = details
+		if verdict == VerdictLikelyMITM {
+			analysis.Observations = append(analysis.Observations, details[0])
+		}
+	}
+
+	// Tor Browser rides Firefox's ESR version train but negotiates a fixed,
+	// distinctive ClientHello (see torbrowser.go) - check it whenever the UA
+	// claims a Firefox ESR release, so a genuine Tor user isn't scored as a
+	// spoofed/mismatched Firefox below.
+	if parsedUA := result.RequestInfo.UserAgentParsed; parsedUA != nil && parsedUA.Browser == "Firefox" {
+		if isTor, esr, details := DetectTorBrowser(tls, majorVersion(parsedUA.BrowserVersion)); isTor {
+			analysis.IsTorBrowser = true
+			analysis.TorBrowserESR = esr
+			analysis.ClientName = fmt.Sprintf("Tor Browser (Firefox ESR %s)", esr)
+			analysis.Observations = append(analysis.Observations, details...)
+		}
+	}
+
 	// Determine client type based on various signals
 	analysis.ClientType = detectClientType(tls, userAgent)
 
 	// Analyze cipher strength
-	analysis.CipherStrength = analyzeCipherStrength(tls.Ciphers)
+	analysis.CipherStrength = analyzeCipherStrength(tls)
 
 	// Check for suspicious patterns
 	if len(tls.ALPN) == 0 {
@@ -376,6 +911,15 @@ func analyzeTLS(tls *TLSFingerprint, result *AnalysisResult, userAgent string) {
 		analysis.Observations = append(analysis.Observations, "No SNI (Server Name Indication) - often indicates non-browser client or IP-direct access")
 	}
 
+	if len(tls.CertCompressionAlgs) > 0 {
+		var algNames []string
+		for _, alg := range tls.CertCompressionAlgs {
+			algNames = append(algNames, getCertCompressionName(alg))
+		}
+		analysis.Observations = append(analysis.Observations,
+			fmt.Sprintf("Advertises certificate compression: %s", strings.Join(algNames, ", ")))
+	}
+
 	// Check cipher suite count
 	if len(tls.Ciphers) < 5 {
 		analysis.Observations = append(analysis.Observations, "Very few cipher suites offered - possibly a limited/custom client")
@@ -384,7 +928,7 @@ func analyzeTLS(tls *TLSFingerprint, result *AnalysisResult, userAgent string) {
 	}
 }
 
-func analyzeHTTP2(http2 *HTTP2Fingerprint) *HTTP2Analysis {
+func analyzeHTTP2(http2 *HTTP2Fingerprint, result *AnalysisResult) *HTTP2Analysis {
 	analysis := &HTTP2Analysis{
 		Detected: true,
 	}
@@ -403,6 +947,15 @@ func analyzeHTTP2(http2 *HTTP2Fingerprint) *HTTP2Analysis {
 		}
 	} else {
 		analysis.Observations = append(analysis.Observations, "HTTP/2 fingerprint doesn't match database entries")
+
+		// No curated match - try the operator-maintained Recog fingerprints.
+		if recogMatch, found := db.LookupHTTP2Recog(http2.Akamai); found {
+			result.RecogFacts = append(result.RecogFacts, recogMatch)
+			analysis.ClientMatch = recogMatch.Facts["service.family"]
+			analysis.Observations = append(analysis.Observations,
+				fmt.Sprintf("HTTP/2 fingerprint matches recog fingerprint%s (certainty %.2f): %s",
+					descriptionSuffix(recogMatch.Description), recogMatch.Certainty, formatRecogFacts(recogMatch.Facts)))
+		}
 	}
 
 	// Analyze settings for Chrome default window size
@@ -414,7 +967,7 @@ func analyzeHTTP2(http2 *HTTP2Fingerprint) *HTTP2Analysis {
 	}
 
 	// ===== curl-impersonate / Impersonator Detection =====
-	// Use database rules for detection
+	// Use the database's rule-based detection (see rules.go)
 
 	// Extract pseudo_header_order from akamai string if not set directly
 	pseudoOrder := http2.PseudoHeaderOrder
@@ -426,7 +979,6 @@ func analyzeHTTP2(http2 *HTTP2Fingerprint) *HTTP2Analysis {
 		}
 	}
 
-	// Check against database detection rules
 	if isImpersonator, reasons := db.IsImpersonatorByHTTP2Rules(http2.Akamai, pseudoOrder); isImpersonator {
 		analysis.IsImpersonator = true
 		analysis.ImpersonatorType = "curl-impersonate/curl_cffi"
@@ -440,6 +992,21 @@ func analyzeHTTP2(http2 *HTTP2Fingerprint) *HTTP2Analysis {
 			fmt.Sprintf("Note: %d potential impersonator signal(s): %s", len(reasons), strings.Join(reasons, "; ")))
 	}
 
+	// Evidence-based top-3 classification against the HTTP2Profile corpus
+	// (see http2profile.go) - additive to the lookup-table/rule-based
+	// detection above, not a replacement for it.
+	profiles := db.GetHTTP2Profiles()
+	if candidates := ClassifyHTTP2(http2, profiles, 3); len(candidates) > 0 {
+		analysis.Candidates = candidates
+		top := candidates[0]
+		analysis.Observations = append(analysis.Observations,
+			fmt.Sprintf("Profile classifier: closest match %s (%s, score %.2f)", top.Label, top.Category, top.Score))
+	}
+	if closest, diffs := DiffAgainstClosestBrowser(http2, profiles); closest != "" && len(diffs) > 0 {
+		analysis.ClosestBrowser = closest
+		analysis.FieldDiff = diffs
+	}
+
 	// Additional frame_order check
 	if len(http2.FrameOrder) > 0 && pseudoOrder == "" {
 		hasHeaders := false
@@ -503,6 +1070,12 @@ func analyzeConsistency(fp *CombinedFingerprint, result *AnalysisResult, userAge
 	check := result.ConsistencyCheck
 	check.Score = 100
 
+	// engine fires the named, weighted rules from the active
+	// ConsistencyRulePack (see consistency_rules.go) in place of this
+	// function's old hardcoded score deltas - a disabled rule simply never
+	// fires, costing no score and never appearing in check.RuleFired.
+	engine := GetDatabase().GetConsistencyRules()
+
 	// Get parsed UA info
 	parsedUA := result.RequestInfo.UserAgentParsed
 
@@ -514,17 +1087,33 @@ func analyzeConsistency(fp *CombinedFingerprint, result *AnalysisResult, userAge
 				fmt.Sprintf("User-Agent identifies as: %s", parsedUA.BotName))
 		}
 
+		// A WebKit-backed WebView/iOS wrapper claiming "Chrome" or another
+		// non-Safari browser legitimately produces a Safari-style TLS/HTTP2
+		// fingerprint - that's expected, not a spoofing signal.
+		isWebKitWebView := parsedUA.WebView != nil && parsedUA.WebView.IsWebView &&
+			strings.Contains(parsedUA.WebView.EmbeddedEngine, "WebKit")
+
 		// Check 1a: UA Browser vs TLS fingerprint
 		tlsClient := strings.ToLower(result.TLSAnalysis.ClientName)
 		if parsedUA.Browser != "" && tlsClient != "" {
 			uaBrowser := strings.ToLower(parsedUA.Browser)
-			if !strings.Contains(tlsClient, uaBrowser) && !strings.Contains(uaBrowser, "edge") {
+			switch {
+			case isWebKitWebView && strings.Contains(tlsClient, "safari"):
+				check.Details = append(check.Details,
+					fmt.Sprintf("UA claims %s but is actually %s - Safari-style TLS fingerprint expected, not a spoof",
+						parsedUA.Browser, describeWebView(parsedUA.WebView)))
+			case result.TLSAnalysis.IsTorBrowser:
+				check.Details = append(check.Details,
+					fmt.Sprintf("UA claims Firefox ESR %s but is actually Tor Browser - distinctive ClientHello expected, not a spoof",
+						result.TLSAnalysis.TorBrowserESR))
+			case !strings.Contains(tlsClient, uaBrowser) && !strings.Contains(uaBrowser, "edge"):
 				// Edge uses Chrome's TLS, so that's expected
-				check.Anomalies = append(check.Anomalies,
-					fmt.Sprintf("UA claims %s but TLS fingerprint matches %s",
-						parsedUA.Browser, result.TLSAnalysis.ClientName))
-				check.Score -= 25
-			} else {
+				if engine.Fire(check, "ua-tls-browser-mismatch") {
+					check.Anomalies = append(check.Anomalies,
+						fmt.Sprintf("UA claims %s but TLS fingerprint matches %s",
+							parsedUA.Browser, result.TLSAnalysis.ClientName))
+				}
+			default:
 				check.Details = append(check.Details,
 					fmt.Sprintf("UA browser (%s) consistent with TLS fingerprint", parsedUA.Browser))
 			}
@@ -534,13 +1123,14 @@ func analyzeConsistency(fp *CombinedFingerprint, result *AnalysisResult, userAge
 		if fp.HTTP2 != nil && result.HTTP2Analysis != nil && parsedUA.Browser != "" {
 			http2Client := strings.ToLower(result.HTTP2Analysis.ClientMatch)
 			uaBrowser := strings.ToLower(parsedUA.Browser)
-			if http2Client != "" && !strings.Contains(http2Client, uaBrowser) {
+			if http2Client != "" && !strings.Contains(http2Client, uaBrowser) && !(isWebKitWebView && strings.Contains(http2Client, "safari")) {
 				// Chrome/Edge share HTTP/2 fingerprint
 				if !(strings.Contains(uaBrowser, "edge") && strings.Contains(http2Client, "chrome")) {
-					check.Anomalies = append(check.Anomalies,
-						fmt.Sprintf("UA claims %s but HTTP/2 fingerprint matches %s",
-							parsedUA.Browser, result.HTTP2Analysis.ClientMatch))
-					check.Score -= 20
+					if engine.Fire(check, "ua-http2-browser-mismatch") {
+						check.Anomalies = append(check.Anomalies,
+							fmt.Sprintf("UA claims %s but HTTP/2 fingerprint matches %s",
+								parsedUA.Browser, result.HTTP2Analysis.ClientMatch))
+					}
 				}
 			}
 		}
@@ -553,10 +1143,11 @@ func analyzeConsistency(fp *CombinedFingerprint, result *AnalysisResult, userAge
 
 		if tlsClient != "" && http2Client != "" {
 			if !strings.Contains(tlsClient, http2Client) && !strings.Contains(http2Client, tlsClient) {
-				check.Anomalies = append(check.Anomalies,
-					fmt.Sprintf("TLS fingerprint suggests %s but HTTP/2 suggests %s",
-						result.TLSAnalysis.ClientName, result.HTTP2Analysis.ClientMatch))
-				check.Score -= 20
+				if engine.Fire(check, "tls-http2-mismatch") {
+					check.Anomalies = append(check.Anomalies,
+						fmt.Sprintf("TLS fingerprint suggests %s but HTTP/2 suggests %s",
+							result.TLSAnalysis.ClientName, result.HTTP2Analysis.ClientMatch))
+				}
 			} else {
 				check.Details = append(check.Details, "TLS and HTTP/2 fingerprints are consistent")
 			}
@@ -564,9 +1155,23 @@ func analyzeConsistency(fp *CombinedFingerprint, result *AnalysisResult, userAge
 
 		// Check for impersonator
 		if result.HTTP2Analysis.IsImpersonator {
-			check.Anomalies = append(check.Anomalies,
-				fmt.Sprintf("HTTP/2 fingerprint indicates impersonator: %s", result.HTTP2Analysis.ImpersonatorType))
-			check.Score -= 30
+			if engine.Fire(check, "http2-impersonator") {
+				check.Anomalies = append(check.Anomalies,
+					fmt.Sprintf("HTTP/2 fingerprint indicates impersonator: %s", result.HTTP2Analysis.ImpersonatorType))
+			}
+		}
+	}
+
+	// Check HTTP-layer (JA4H) header order against what TLS/HTTP2 already
+	// claimed: a known library/bot header-order signature paired with a UA
+	// claiming a real, non-bot browser is the same kind of layer mismatch as
+	// tls-http2-mismatch above, just one layer up the stack.
+	if fp.HTTP != nil && fp.HTTP.JA4H != "" && parsedUA != nil && parsedUA.Browser != "" && !parsedUA.IsBot {
+		if _, clientType, found := GetDatabase().LookupJA4H(fp.HTTP.JA4H); found && clientType == "library" {
+			if engine.Fire(check, "ja4h-client-mismatch") {
+				check.Anomalies = append(check.Anomalies,
+					fmt.Sprintf("UA claims %s but HTTP request header order matches a known library client", parsedUA.Browser))
+			}
 		}
 	}
 
@@ -656,8 +1261,9 @@ func analyzeConsistency(fp *CombinedFingerprint, result *AnalysisResult, userAge
 		}
 
 		if !osMatches && mismatchReason != "" {
-			check.Anomalies = append(check.Anomalies, mismatchReason)
-			check.Score -= 35
+			if engine.Fire(check, "tcp-os-mismatch") {
+				check.Anomalies = append(check.Anomalies, mismatchReason)
+			}
 		} else if tcpOS != "" {
 			check.Details = append(check.Details,
 				fmt.Sprintf("UA OS (%s) consistent with TCP fingerprint (%s, TTL=%d, WindowSize=%d)",
@@ -677,18 +1283,20 @@ func analyzeConsistency(fp *CombinedFingerprint, result *AnalysisResult, userAge
 	// ============ Layer 5: TCP anomalies from collector ============
 	if fp.TCP != nil && len(fp.TCP.Anomalies) > 0 {
 		for _, a := range fp.TCP.Anomalies {
-			check.Anomalies = append(check.Anomalies, a)
-			check.Score -= 10
+			if engine.Fire(check, "tcp-collector-anomaly") {
+				check.Anomalies = append(check.Anomalies, a)
+			}
 		}
 	}
 
 	// ============ Layer 6: TLS Client Type vs UA ============
 	if result.TLSAnalysis.ClientType == "Library" && parsedUA != nil && !parsedUA.IsBot {
 		if parsedUA.Browser != "" {
-			check.Anomalies = append(check.Anomalies,
-				fmt.Sprintf("UA claims to be %s browser but TLS fingerprint indicates HTTP library",
-					parsedUA.Browser))
-			check.Score -= 25
+			if engine.Fire(check, "tls-library-vs-browser-ua") {
+				check.Anomalies = append(check.Anomalies,
+					fmt.Sprintf("UA claims to be %s browser but TLS fingerprint indicates HTTP library",
+						parsedUA.Browser))
+			}
 		}
 	}
 
@@ -697,9 +1305,47 @@ func analyzeConsistency(fp *CombinedFingerprint, result *AnalysisResult, userAge
 		// Mobile devices typically have specific TCP characteristics
 		// Windows TTL (128) with mobile UA is suspicious
 		if fp.TCP.InitialTTL == 128 {
-			check.Anomalies = append(check.Anomalies,
-				"UA claims mobile device but TCP TTL (128) indicates Windows desktop")
-			check.Score -= 20
+			if engine.Fire(check, "mobile-ua-desktop-ttl") {
+				check.Anomalies = append(check.Anomalies,
+					"UA claims mobile device but TCP TTL (128) indicates Windows desktop")
+			}
+		}
+	}
+
+	// ============ Layer 8: Corpus-backed MITM verdict ============
+	// EvaluateMITMFingerprint (see mitm.go) already ran inside analyzeTLS;
+	// fold its verdict in here rather than re-scoring, since it's a more
+	// principled replacement for the old ad-hoc browserScore/libScore tally.
+	switch MITMVerdict(result.TLSAnalysis.MITMVerdict) {
+	case VerdictLikelyMITM:
+		if engine.Fire(check, "mitm-corpus-likely-mitm") {
+			check.Anomalies = append(check.Anomalies, result.TLSAnalysis.MITMDetails[0])
+		}
+	case VerdictMatch:
+		check.Details = append(check.Details, "ClientHello matches the MITM-detection corpus record for the claimed browser/version")
+	}
+
+	// ============ Layer 9: PQ key-exchange vs claimed browser version ============
+	// Chrome has committed a Kyber-hybrid key_share (see pq.go) by default
+	// since version 124 - a UA claiming an older Chrome alongside a PQ
+	// key_share is a spoofing signal (the ClientHello is from a newer
+	// build than the UA string admits to), and a UA claiming Chrome 124+
+	// with no PQ key_share at all is merely notable, not anomalous (it can
+	// be disabled via enterprise policy or flag), so only the log line is
+	// recorded rather than a scored anomaly.
+	if fp.TLS != nil && parsedUA != nil && parsedUA.Browser == "Chrome" && parsedUA.BrowserVersion != "" {
+		hasPQKeyShare := fp.TLS.PQSupport != nil && len(fp.TLS.PQSupport.KeyShareGroups) > 0
+		if major, err := strconv.Atoi(majorVersion(parsedUA.BrowserVersion)); err == nil {
+			switch {
+			case hasPQKeyShare && major < 124:
+				if engine.Fire(check, "pq-keyshare-version-mismatch") {
+					check.Anomalies = append(check.Anomalies,
+						fmt.Sprintf("UA claims Chrome %s (predates Chrome 124's default PQ key exchange) but ClientHello sent a Kyber-hybrid key_share", parsedUA.BrowserVersion))
+				}
+			case !hasPQKeyShare && major >= 124:
+				check.Details = append(check.Details,
+					fmt.Sprintf("UA claims Chrome %s but no PQ key_share was offered - likely disabled via policy/flag", parsedUA.BrowserVersion))
+			}
 		}
 	}
 
@@ -718,6 +1364,264 @@ func analyzeConsistency(fp *CombinedFingerprint, result *AnalysisResult, userAge
 	}
 }
 
+// sfBrandRe matches one "Brand";v="Version" pair inside a Sec-CH-UA*
+// structured-header list (RFC 8941 sf-string followed by a ;v= parameter).
+var sfBrandRe = regexp.MustCompile(`"([^"]*)"\s*;\s*v\s*=\s*"([^"]*)"`)
+
+// headerLookup does a case-insensitive lookup into a raw header map, since
+// callers populate it with whatever casing the client happened to send.
+func headerLookup(headers map[string]string, name string) (string, bool) {
+	if v, ok := headers[name]; ok {
+		return v, true
+	}
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// parseSFString strips the quotes off an RFC 8941 sf-string, e.g. `"Windows"`
+// becomes `Windows`. Returns the input unchanged if it isn't quoted.
+func parseSFString(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// parseSFBoolean parses an RFC 8941 sf-boolean ("?0" / "?1").
+func parseSFBoolean(s string) (bool, bool) {
+	switch strings.TrimSpace(s) {
+	case "?1":
+		return true, true
+	case "?0":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// parseSFBrandList parses a Sec-CH-UA / Sec-CH-UA-Full-Version-List value -
+// an RFC 8941 list of ("Brand";v="Version") pairs - into UABrandVersion
+// entries, e.g. `"Chromium";v="131", "Not_A Brand";v="24"`.
+func parseSFBrandList(value string) []UABrandVersion {
+	matches := sfBrandRe.FindAllStringSubmatch(value, -1)
+	if matches == nil {
+		return nil
+	}
+	brands := make([]UABrandVersion, 0, len(matches))
+	for _, m := range matches {
+		brands = append(brands, UABrandVersion{Brand: m[1], Version: m[2]})
+	}
+	return brands
+}
+
+// parseClientHints builds a ClientHints from the raw request headers. Returns
+// nil if headers is empty or none of the Sec-CH-UA* headers were sent.
+func parseClientHints(headers map[string]string) *ClientHints {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	hints := &ClientHints{}
+
+	if v, ok := headerLookup(headers, "Sec-CH-UA"); ok {
+		hints.Present = true
+		hints.Brands = parseSFBrandList(v)
+	}
+	if v, ok := headerLookup(headers, "Sec-CH-UA-Full-Version-List"); ok {
+		hints.Present = true
+		hints.FullVersionList = parseSFBrandList(v)
+	}
+	if v, ok := headerLookup(headers, "Sec-CH-UA-Mobile"); ok {
+		hints.Present = true
+		if b, valid := parseSFBoolean(v); valid {
+			hints.Mobile = &b
+		}
+	}
+	if v, ok := headerLookup(headers, "Sec-CH-UA-Platform"); ok {
+		hints.Present = true
+		hints.Platform = parseSFString(v)
+	}
+	if v, ok := headerLookup(headers, "Sec-CH-UA-Platform-Version"); ok {
+		hints.Present = true
+		hints.PlatformVersion = parseSFString(v)
+	}
+	if v, ok := headerLookup(headers, "Sec-CH-UA-Arch"); ok {
+		hints.Present = true
+		hints.Arch = parseSFString(v)
+	}
+	if v, ok := headerLookup(headers, "Sec-CH-UA-Bitness"); ok {
+		hints.Present = true
+		hints.Bitness = parseSFString(v)
+	}
+	if v, ok := headerLookup(headers, "Sec-CH-UA-Model"); ok {
+		hints.Present = true
+		hints.Model = parseSFString(v)
+	}
+
+	if !hints.Present {
+		return nil
+	}
+	return hints
+}
+
+// chBrandMajor returns the major version a brand list claims for brand
+// (case-insensitive substring match, e.g. "Chrome" matches "Google Chrome"),
+// and whether that brand was present at all.
+func chBrandMajor(brands []UABrandVersion, brand string) (string, bool) {
+	for _, b := range brands {
+		if strings.Contains(strings.ToLower(b.Brand), strings.ToLower(brand)) {
+			return majorVersion(b.Version), true
+		}
+	}
+	return "", false
+}
+
+// majorVersion returns the leading dot-separated component of a version
+// string, e.g. "131.0.6778.86" -> "131".
+func majorVersion(v string) string {
+	if idx := strings.Index(v, "."); idx != -1 {
+		return v[:idx]
+	}
+	return v
+}
+
+// analyzeClientHints cross-checks User-Agent Client Hints against the legacy
+// UA string and TCP-inferred OS. Real Chromium browsers keep these in lock
+// step; curl-impersonate/tls-client/requests-based impersonators that only
+// clone the legacy UA tend to omit Client Hints entirely or leave them
+// pointing at a different brand/platform/mobile-ness than the UA claims.
+func analyzeClientHints(fp *CombinedFingerprint, result *AnalysisResult) {
+	hints := result.RequestInfo.ClientHints
+	parsedUA := result.RequestInfo.UserAgentParsed
+	check := result.ConsistencyCheck
+
+	if hints == nil {
+		// Chrome/Edge/Opera (Chromium) claimants should send Client Hints; a
+		// Chromium UA with none at all is itself a mild impersonation signal.
+		if parsedUA != nil && !parsedUA.IsBot {
+			switch parsedUA.Browser {
+			case "Chrome", "Edge", "Opera":
+				check.Details = append(check.Details,
+					fmt.Sprintf("UA claims %s but sent no Sec-CH-UA headers", parsedUA.Browser))
+			}
+		}
+		return
+	}
+
+	// Check 1: full-version-list / brands major version vs UA browser version
+	if parsedUA != nil && parsedUA.Browser != "" && parsedUA.BrowserVersion != "" {
+		uaMajor := majorVersion(parsedUA.BrowserVersion)
+		chMajor, chHasBrand := chBrandMajor(hints.FullVersionList, parsedUA.Browser)
+		if !chHasBrand {
+			chMajor, chHasBrand = chBrandMajor(hints.Brands, parsedUA.Browser)
+		}
+		switch {
+		case !chHasBrand && len(hints.Brands) > 0:
+			check.Anomalies = append(check.Anomalies,
+				fmt.Sprintf("UA claims %s but Sec-CH-UA brand list doesn't mention it: %v", parsedUA.Browser, hints.Brands))
+			check.Score -= 20
+		case chHasBrand && chMajor != uaMajor:
+			check.Anomalies = append(check.Anomalies,
+				fmt.Sprintf("UA claims %s %s but Client Hints report major version %s", parsedUA.Browser, uaMajor, chMajor))
+			check.Score -= 20
+		case chHasBrand:
+			check.Details = append(check.Details,
+				fmt.Sprintf("Client Hints brand version (%s) consistent with UA (%s)", chMajor, uaMajor))
+		}
+	}
+
+	// Check 2: Sec-CH-UA-Platform vs UA OS
+	if hints.Platform != "" && parsedUA != nil && parsedUA.OS != "" {
+		chPlatform := strings.ToLower(hints.Platform)
+		uaOS := strings.ToLower(parsedUA.OS)
+		platformMatches := chPlatform == uaOS ||
+			(strings.Contains(chPlatform, "mac") && strings.Contains(uaOS, "mac")) ||
+			(strings.Contains(chPlatform, "windows") && strings.Contains(uaOS, "windows")) ||
+			(strings.Contains(chPlatform, "linux") && (strings.Contains(uaOS, "linux") || strings.Contains(uaOS, "android"))) ||
+			(strings.Contains(chPlatform, "android") && strings.Contains(uaOS, "android")) ||
+			(strings.Contains(chPlatform, "chrome os") && strings.Contains(uaOS, "chromeos"))
+		if !platformMatches {
+			check.Anomalies = append(check.Anomalies,
+				fmt.Sprintf("Sec-CH-UA-Platform says %q but UA claims %s", hints.Platform, parsedUA.OS))
+			check.Score -= 25
+		} else {
+			check.Details = append(check.Details,
+				fmt.Sprintf("Sec-CH-UA-Platform (%s) consistent with UA OS", hints.Platform))
+		}
+	}
+
+	// Check 3: Sec-CH-UA-Mobile vs UA platform and TCP-inferred OS. Real
+	// browsers only send "?1" on genuine mobile hardware.
+	if hints.Mobile != nil {
+		claimsMobile := *hints.Mobile
+		if parsedUA != nil && parsedUA.Platform != "" {
+			uaSaysMobile := parsedUA.Platform == "Mobile"
+			if claimsMobile != uaSaysMobile {
+				check.Anomalies = append(check.Anomalies,
+					fmt.Sprintf("Sec-CH-UA-Mobile=%v but UA platform is %s", claimsMobile, parsedUA.Platform))
+				check.Score -= 15
+			}
+		}
+		if claimsMobile && fp.TCP != nil && fp.TCP.InitialTTL == 128 {
+			check.Anomalies = append(check.Anomalies,
+				"Sec-CH-UA-Mobile=?1 (mobile) but TCP TTL (128) indicates Windows desktop")
+			check.Score -= 15
+		}
+	}
+
+	if check.Score < 0 {
+		check.Score = 0
+	}
+	check.Passed = len(check.Anomalies) == 0
+}
+
+// analyzeVersionPolicy checks the claimed browser/OS version against the
+// active VersionPolicy (see policy.go), flagging outdated/EOL clients and
+// versions newer than anything actually shipped (a common side effect of
+// spoofed or stale UA-override lists). It also cross-checks the UA's claimed
+// version against the version implied by the JA3 database match, since a
+// fixed TLS stack paired with a hand-edited UA string is a classic
+// impersonation tell.
+func analyzeVersionPolicy(result *AnalysisResult) {
+	parsedUA := result.RequestInfo.UserAgentParsed
+	if parsedUA == nil || parsedUA.IsBot {
+		return
+	}
+	check := result.ConsistencyCheck
+
+	p := GetVersionPolicy()
+	if finding, matched := EvaluateVersionPolicy(p, parsedUA.Browser, parsedUA.BrowserVersion, parsedUA.OS, parsedUA.OSVersion); matched {
+		result.VersionFindings = append(result.VersionFindings, *finding)
+		check.Anomalies = append(check.Anomalies, finding.Reason)
+		check.Score -= riskScoreDeduction(finding.Rule.Risk)
+		if check.Score < 0 {
+			check.Score = 0
+		}
+		check.Passed = len(check.Anomalies) == 0
+	}
+
+	// Cross-check: JA3 database's recorded version for this client vs the
+	// version the UA claims. A stale impersonation library often pins a TLS
+	// stack captured from one Chrome release while the caller free-edits the
+	// UA string to claim a newer one.
+	if result.TLSAnalysis.ClientVersion != "" && parsedUA.BrowserVersion != "" {
+		if majorVersion(result.TLSAnalysis.ClientVersion) != majorVersion(parsedUA.BrowserVersion) {
+			check.Anomalies = append(check.Anomalies,
+				fmt.Sprintf("UA claims version %s but JA3 fingerprint matches version %s", parsedUA.BrowserVersion, result.TLSAnalysis.ClientVersion))
+			check.Score -= 15
+			if check.Score < 0 {
+				check.Score = 0
+			}
+			check.Passed = len(check.Anomalies) == 0
+		}
+	}
+}
+
 func generateSummary(result *AnalysisResult, userAgent string) {
 	summary := result.Summary
 	parsedUA := result.RequestInfo.UserAgentParsed
@@ -734,6 +1638,13 @@ func generateSummary(result *AnalysisResult, userAgent string) {
 		summary.DetectedClient = "Unknown"
 	}
 
+	// Server-side stack hint from the favicon corpus lookup (see favicon.go)
+	// is independent of everything above - it's fingerprinting the server
+	// behind the origin that referred the client here, not the client itself.
+	if result.FaviconAnalysis != nil && result.FaviconAnalysis.Match != "" {
+		summary.DetectedClient = fmt.Sprintf("client=%s, server-favicon=%s", summary.DetectedClient, result.FaviconAnalysis.Match)
+	}
+
 	// Determine detected OS - prefer TCP fingerprint over UA
 	if result.TCPAnalysis != nil && result.TCPAnalysis.InferredOS != "" {
 		summary.DetectedOS = result.TCPAnalysis.InferredOS
@@ -793,11 +1704,20 @@ func generateSummary(result *AnalysisResult, userAgent string) {
 
 	summary.IsBot = botSignals >= 2
 
+	// Tor Browser is a legitimate client, not a bot or a spoofed Firefox -
+	// analyzeConsistency already treats its TLS/UA "mismatch" as expected
+	// (see the IsTorBrowser case there), so this only needs to carry the
+	// verdict through to the summary.
+	summary.IsTorBrowser = result.TLSAnalysis.IsTorBrowser
+
 	// Determine if spoofed (trying to look like something else)
-	summary.IsSpoofed = len(result.ConsistencyCheck.Anomalies) > 0 ||
-		(result.HTTP2Analysis != nil && result.HTTP2Analysis.IsImpersonator)
+	summary.IsSpoofed = !summary.IsTorBrowser &&
+		(len(result.ConsistencyCheck.Anomalies) > 0 ||
+			(result.HTTP2Analysis != nil && result.HTTP2Analysis.IsImpersonator))
 
-	// Determine risk level
+	// Determine risk level, against the active ConsistencyRulePack's
+	// thresholds (see consistency_rules.go) rather than a hardcoded 90/60.
+	engine := GetDatabase().GetConsistencyRules()
 	switch {
 	case result.HTTP2Analysis != nil && result.HTTP2Analysis.IsImpersonator:
 		// Impersonator always gets medium or higher
@@ -806,9 +1726,9 @@ func generateSummary(result *AnalysisResult, userAgent string) {
 		} else {
 			summary.RiskLevel = "high"
 		}
-	case result.ConsistencyCheck.Score >= 90 && !summary.IsBot:
+	case result.ConsistencyCheck.Score >= engine.LowRiskMin() && !summary.IsBot:
 		summary.RiskLevel = "low"
-	case result.ConsistencyCheck.Score >= 60:
+	case result.ConsistencyCheck.Score >= engine.MediumRiskMin():
 		summary.RiskLevel = "medium"
 	default:
 		summary.RiskLevel = "high"
@@ -828,6 +1748,10 @@ func generateSummary(result *AnalysisResult, userAgent string) {
 	if result.TLSAnalysis.CipherStrength == "Weak" {
 		summary.Warnings = append(summary.Warnings, "Weak cipher suites detected")
 	}
+	if summary.IsTorBrowser {
+		summary.Warnings = append(summary.Warnings,
+			fmt.Sprintf("Tor Browser detected (Firefox ESR %s) - traffic likely exits the Tor network", result.TLSAnalysis.TorBrowserESR))
+	}
 }
 
 func generateSecurityAdvice(result *AnalysisResult) {
@@ -862,6 +1786,15 @@ func generateSecurityAdvice(result *AnalysisResult) {
 		})
 	}
 
+	if result.Summary.IsTorBrowser {
+		advice.ForDefenders = append(advice.ForDefenders, AdviceItem{
+			Category:    "Tor Exit Detection",
+			Title:       fmt.Sprintf("Tor Browser (ESR %s)", result.TLSAnalysis.TorBrowserESR),
+			Description: "This client is genuine Tor Browser, not a spoofed Firefox - its TLS/UA mismatch is expected, not a spoofing signal. Pair this with the request's source IP against a Tor exit-node list (e.g. the Tor Project's exit list or dan.me.uk's TorNodeList) to decide whether to challenge or allow, rather than penalizing the fingerprint itself.",
+			Priority:    "medium",
+		})
+	}
+
 	if result.Summary.IsSpoofed && !(result.HTTP2Analysis != nil && result.HTTP2Analysis.IsImpersonator) {
 		advice.ForDefenders = append(advice.ForDefenders, AdviceItem{
 			Category:    "Spoofing Detection",
@@ -871,6 +1804,23 @@ func generateSecurityAdvice(result *AnalysisResult) {
 		})
 	}
 
+	for _, finding := range result.VersionFindings {
+		title := "Outdated Client Version"
+		if finding.FutureVersion {
+			title = "Implausible Client Version"
+		}
+		desc := finding.Reason
+		if len(finding.Rule.CVEs) > 0 {
+			desc += fmt.Sprintf(" (%s)", strings.Join(finding.Rule.CVEs, ", "))
+		}
+		advice.ForDefenders = append(advice.ForDefenders, AdviceItem{
+			Category:    "Version Policy",
+			Title:       title,
+			Description: desc,
+			Priority:    finding.Rule.Risk,
+		})
+	}
+
 	if result.ConsistencyCheck.Score < 100 {
 		advice.ForDefenders = append(advice.ForDefenders, AdviceItem{
 			Category:    "Consistency",
@@ -919,10 +1869,15 @@ func generateSecurityAdvice(result *AnalysisResult) {
 
 	// Impersonator-specific advice for pentesters
 	if result.HTTP2Analysis != nil && result.HTTP2Analysis.IsImpersonator {
+		description := "Your curl-impersonate/curl_cffi is detected through HTTP/2 fingerprint. Key issues: (1) pseudo_header_order missing ':path', (2) explicit ENABLE_PUSH=0, (3) missing MAX_CONCURRENT_STREAMS."
+		if len(result.HTTP2Analysis.FieldDiff) > 0 {
+			description = fmt.Sprintf("Detected via HTTP/2 fingerprint, closest real browser is %s. Fields that gave it away: %s",
+				result.HTTP2Analysis.ClosestBrowser, strings.Join(result.HTTP2Analysis.FieldDiff, "; "))
+		}
 		advice.ForPentesters = append(advice.ForPentesters, AdviceItem{
 			Category:    "Warning",
 			Title:       "Impersonator Detected via HTTP/2",
-			Description: "Your curl-impersonate/curl_cffi is detected through HTTP/2 fingerprint. Key issues: (1) pseudo_header_order missing ':path', (2) explicit ENABLE_PUSH=0, (3) missing MAX_CONCURRENT_STREAMS.",
+			Description: description,
 			Priority:    "critical",
 		})
 		advice.ForPentesters = append(advice.ForPentesters, AdviceItem{
@@ -1077,6 +2032,13 @@ func detectClientType(tls *TLSFingerprint, userAgent string) string {
 		libScore += 1
 	}
 
+	// 9. PQ (Kyber 混合) key_share 检测
+	// 目前只有主流浏览器 (Chrome 124+ 及其衍生版本) 会在 key_share 中主动提交
+	// 混合后量子密钥交换，HTTP 库几乎从不实现这个 - 和 GREASE 一样是强信号
+	if tls.PQSupport != nil && len(tls.PQSupport.KeyShareGroups) > 0 {
+		browserScore += 3
+	}
+
 	// 检查 UA 中是否明确标识为库
 	knownLibs := []string{"python", "curl", "go-http", "node", "java", "urllib", "axios", "requests", "httpx", "aiohttp", "scrapy"}
 	if ua := strings.ToLower(userAgent); ua != "" {
@@ -1100,31 +2062,42 @@ func detectClientType(tls *TLSFingerprint, userAgent string) string {
 	return "Unknown"
 }
 
-func analyzeCipherStrength(ciphers []string) string {
-	hasWeak := false
-	hasStrong := false
-
+// analyzeCipherStrength is data-driven off each cipher's IANA "Recommended"
+// column (see iana.go) rather than a hand-maintained substring blocklist:
+// offering only IANA-recommended suites is Strong, offering only
+// not-recommended ones is Weak, and a mix is Medium. A client that commits a
+// Kyber-hybrid key_share (see pq.go) is upgraded from Medium to Strong - a
+// quantum-resistant key exchange is a meaningfully stronger posture than the
+// cipher list alone suggests, even before the rest of its suites are all
+// IANA-recommended.
+func analyzeCipherStrength(tls *TLSFingerprint) string {
+	ciphers := tls.Ciphers
+	if len(ciphers) == 0 {
+		return "Medium"
+	}
+
+	allRecommended := true
+	anyRecommended := false
 	for _, c := range ciphers {
-		cLower := strings.ToLower(c)
-		// Weak ciphers
-		if strings.Contains(cLower, "rc4") || strings.Contains(cLower, "des") ||
-			strings.Contains(cLower, "export") || strings.Contains(cLower, "null") {
-			hasWeak = true
-		}
-		// Strong ciphers
-		if strings.Contains(cLower, "aes_256") || strings.Contains(cLower, "chacha20") ||
-			strings.Contains(cLower, "gcm") {
-			hasStrong = true
+		if c.Recommended {
+			anyRecommended = true
+		} else {
+			allRecommended = false
 		}
 	}
 
-	if hasWeak {
-		return "Weak"
-	}
-	if hasStrong {
+	hasPQKeyShare := tls.PQSupport != nil && len(tls.PQSupport.KeyShareGroups) > 0
+
+	switch {
+	case allRecommended:
 		return "Strong"
+	case anyRecommended && hasPQKeyShare:
+		return "Strong"
+	case anyRecommended:
+		return "Medium"
+	default:
+		return "Weak"
 	}
-	return "Medium"
 }
 
 // BuildSimpleResult 构建简化的 API 响应
@@ -1195,7 +2168,12 @@ func buildClientInfo(result *AnalysisResult) ClientInfo {
 
 	// 实际检测到的
 	detected := ""
-	if result.HTTP2Analysis != nil && result.HTTP2Analysis.IsImpersonator {
+	parsedUA := result.RequestInfo.UserAgentParsed
+	if parsedUA != nil && parsedUA.WebView != nil && parsedUA.WebView.IsWebView && parsedUA.WebView.HostApp != "" {
+		// A named in-app WebView is a more useful answer than the generic
+		// TLS/HTTP2 client match, and prevents it being mislabeled an impersonator.
+		detected = parsedUA.WebView.HostApp + " in-app WebView"
+	} else if result.HTTP2Analysis != nil && result.HTTP2Analysis.IsImpersonator {
 		detected = result.HTTP2Analysis.ImpersonatorType
 	} else if result.TLSAnalysis.ClientName != "" {
 		detected = result.TLSAnalysis.ClientName
@@ -1250,5 +2228,9 @@ func buildFingerprintSummary(result *AnalysisResult) FingerprintSummary {
 		fp.TCPOS = tcp.InferredOS
 	}
 
+	if result.FaviconAnalysis != nil && result.FaviconAnalysis.Detected {
+		fp.FaviconMMH3 = result.FaviconAnalysis.MMH3
+	}
+
 	return fp
 }
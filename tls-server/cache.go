@@ -0,0 +1,152 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// CacheStats reports basic observability counters for a TCPFingerprintCache.
+type CacheStats struct {
+	Size      int   `json:"size"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+}
+
+type cacheEntry struct {
+	key       string
+	fp        *TCPIPFingerprint
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+// TCPFingerprintCache is a thread-safe TTL+LRU cache for TCPIPFingerprint values,
+// replacing the old "wipe everything past 10000 entries" behavior in
+// CleanupOldFingerprints. Entries are evicted either by age (maxAge, on a periodic
+// sweep) or by LRU once the cache exceeds capacity - never all at once.
+type TCPFingerprintCache struct {
+	mu       sync.Mutex
+	capacity int
+	maxAge   time.Duration
+	entries  map[string]*list.Element // key -> element in order (most-recently-used at front)
+	order    *list.List
+
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+// NewTCPFingerprintCache creates a cache with the given LRU capacity and max entry age.
+func NewTCPFingerprintCache(capacity int, maxAge time.Duration) *TCPFingerprintCache {
+	return &TCPFingerprintCache{
+		capacity: capacity,
+		maxAge:   maxAge,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Set inserts or updates the fingerprint for key, refreshing LastSeen and moving it
+// to the front of the LRU order. Evicts the least-recently-used entry if over capacity.
+func (c *TCPFingerprintCache) Set(key string, fp *TCPIPFingerprint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*cacheEntry)
+		entry.fp = fp
+		entry.lastSeen = now
+		c.order.MoveToFront(el)
+		return
+	}
+
+	entry := &cacheEntry{key: key, fp: fp, firstSeen: now, lastSeen: now}
+	el := c.order.PushFront(entry)
+	c.entries[key] = el
+
+	if c.capacity > 0 && len(c.entries) > c.capacity {
+		c.evictLRULocked()
+	}
+}
+
+// Get retrieves the fingerprint for key, bumping LastSeen on every hit (API-compatible
+// read-through behavior for GetTCPFingerprint).
+func (c *TCPFingerprintCache) Get(key string) *TCPIPFingerprint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil
+	}
+	entry := el.Value.(*cacheEntry)
+	entry.lastSeen = time.Now()
+	c.order.MoveToFront(el)
+	c.hits++
+	return entry.fp
+}
+
+// evictLRULocked removes the least-recently-used entry. Caller must hold c.mu.
+func (c *TCPFingerprintCache) evictLRULocked() {
+	el := c.order.Back()
+	if el == nil {
+		return
+	}
+	entry := el.Value.(*cacheEntry)
+	c.order.Remove(el)
+	delete(c.entries, entry.key)
+	c.evictions++
+}
+
+// SweepExpired removes entries whose LastSeen is older than maxAge. Intended to run
+// periodically from a ticker goroutine.
+func (c *TCPFingerprintCache) SweepExpired() {
+	if c.maxAge <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cutoff := time.Now().Add(-c.maxAge)
+	// Walk from the back (oldest-used first); stop once we hit a fresh entry for
+	// typical workloads, but since age != LRU order we must scan fully to be correct.
+	var next *list.Element
+	for el := c.order.Back(); el != nil; el = next {
+		next = el.Prev()
+		entry := el.Value.(*cacheEntry)
+		if entry.lastSeen.Before(cutoff) {
+			c.order.Remove(el)
+			delete(c.entries, entry.key)
+			c.evictions++
+		}
+	}
+}
+
+// Stats returns a point-in-time snapshot of cache counters.
+func (c *TCPFingerprintCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Size:      len(c.entries),
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+}
+
+// Snapshot returns a stable shallow copy of all cached fingerprints, keyed by the
+// same keys used internally, so callers like the JSON API can iterate without
+// holding the cache's write lock.
+func (c *TCPFingerprintCache) Snapshot() map[string]*TCPIPFingerprint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := make(map[string]*TCPIPFingerprint, len(c.entries))
+	for key, el := range c.entries {
+		out[key] = el.Value.(*cacheEntry).fp
+	}
+	return out
+}
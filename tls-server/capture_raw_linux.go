@@ -0,0 +1,119 @@
+//go:build nolibpcap && linux
+// +build nolibpcap,linux
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"unsafe"
+
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// This is the Linux half of the nolibpcap raw-socket capture path (see
+// tcp_nolibpcap.go for the shared BPF-program/packet-parsing logic). It
+// opens an AF_PACKET socket, attaches the assembled classic BPF program with
+// SO_ATTACH_FILTER so filtering happens in the kernel exactly like it does
+// under libpcap, and strips the 14-byte Ethernet header before handing raw IP
+// datagrams to processRawIPPacket.
+
+// linuxRawCapture is the rawCaptureHandle for an AF_PACKET socket.
+type linuxRawCapture struct {
+	fd int
+}
+
+func (c *linuxRawCapture) Close() {
+	unix.Close(c.fd)
+}
+
+// openRawCapture opens an AF_PACKET/SOCK_RAW socket bound to iface (all
+// interfaces if empty), attaches the classic BPF program for mode/port, and
+// starts a background goroutine reading and fingerprinting matching packets.
+func openRawCapture(iface string, port int, mode CaptureMode, cfg CaptureConfig) (rawCaptureHandle, error) {
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, int(htons(unix.ETH_P_ALL)))
+	if err != nil {
+		return nil, fmt.Errorf("socket: %v", err)
+	}
+
+	var ifindex int
+	if iface != "" {
+		ifi, err := net.InterfaceByName(iface)
+		if err != nil {
+			unix.Close(fd)
+			return nil, fmt.Errorf("interface %q: %v", iface, err)
+		}
+		ifindex = ifi.Index
+	}
+
+	addr := &unix.SockaddrLinklayer{Protocol: htons(unix.ETH_P_ALL), Ifindex: ifindex}
+	if err := unix.Bind(fd, addr); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("bind: %v", err)
+	}
+
+	prog, err := classicBPFProgram(mode, port)
+	if err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("build BPF program: %v", err)
+	}
+	raw, err := bpf.Assemble(prog)
+	if err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("assemble BPF program: %v", err)
+	}
+	if err := attachFilter(fd, raw); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("SO_ATTACH_FILTER: %v", err)
+	}
+
+	handle := &linuxRawCapture{fd: fd}
+	go handle.loop(port, mode)
+	return handle, nil
+}
+
+// loop reads Ethernet frames off the socket - the kernel-side filter has
+// already discarded everything but the TCP control packets mode/port want -
+// strips the 14-byte Ethernet header, and hands the IP datagram off.
+func (c *linuxRawCapture) loop(port int, mode CaptureMode) {
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := unix.Recvfrom(c.fd, buf, 0)
+		if err != nil {
+			log.Printf("[TCP] AF_PACKET read error, stopping capture: %v", err)
+			return
+		}
+		if n <= 14 {
+			continue
+		}
+		processRawIPPacket(buf[14:n], mode, port)
+	}
+}
+
+// attachFilter installs raw (a bpf.Assemble'd classic BPF program) on fd via
+// SO_ATTACH_FILTER, the same mechanism tcpdump/libpcap use under the hood.
+func attachFilter(fd int, raw []bpf.RawInstruction) error {
+	sockFilter := make([]unix.SockFilter, len(raw))
+	for i, ins := range raw {
+		sockFilter[i] = unix.SockFilter{
+			Code: ins.Op,
+			Jt:   ins.Jt,
+			Jf:   ins.Jf,
+			K:    ins.K,
+		}
+	}
+	prog := unix.SockFprog{
+		Len:    uint16(len(sockFilter)),
+		Filter: (*unix.SockFilter)(unsafe.Pointer(&sockFilter[0])),
+	}
+	return unix.SetsockoptSockFprog(fd, unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, &prog)
+}
+
+// htons converts a uint16 from host to network byte order, needed because
+// AF_PACKET's protocol field is always network-order regardless of host
+// endianness.
+func htons(v uint16) uint16 {
+	return (v<<8)&0xff00 | v>>8
+}
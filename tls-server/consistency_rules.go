@@ -0,0 +1,105 @@
+package main
+
+// This file turns analyzeConsistency's hardcoded per-layer score deltas
+// into a pluggable ConsistencyRuleEngine: each layer's deduction is now a
+// named, versioned ConsistencyRule with its own category, severity and
+// enable flag, so an operator can ship a stricter pack for a banking site
+// or a looser one for public content without recompiling - same
+// Default*()+optional-JSON-override shape as the JA3/MITM/favicon corpora
+// (see database.go's Load()). ConsistencyAnalysis.RuleFired then carries
+// exactly which rule IDs triggered, for a downstream SIEM to alert on
+// instead of parsing Anomalies strings.
+
+// ConsistencyRule is one named, weighted check analyzeConsistency can fire.
+// Category groups rules for a SIEM/dashboard (the categories below mirror
+// analyzeConsistency's "Layer N" comments); Severity is a human label
+// alongside the numeric Weight, which is what's actually subtracted from
+// ConsistencyAnalysis.Score when the rule fires. A disabled rule never
+// fires - no score hit, no entry in RuleFired, no Anomalies line.
+type ConsistencyRule struct {
+	ID       string `json:"id"`
+	Category string `json:"category"`
+	Severity string `json:"severity"` // "low", "medium", "high", "critical"
+	Weight   int    `json:"weight"`
+	Enabled  bool   `json:"enabled"`
+}
+
+// ConsistencyRulePack is a named, versioned bundle of rules plus the score
+// thresholds generateSummary maps to low/medium/high risk - bundled
+// together since a stricter pack (banking) usually wants both heavier
+// deductions and a higher bar for "low risk".
+type ConsistencyRulePack struct {
+	Name          string            `json:"name"`
+	Rules         []ConsistencyRule `json:"rules"`
+	LowRiskMin    int               `json:"low_risk_min"`
+	MediumRiskMin int               `json:"medium_risk_min"`
+}
+
+// DefaultConsistencyRulePack is "default-v1": the weights and thresholds
+// analyzeConsistency/generateSummary used before this file existed, now
+// named and tunable instead of hardcoded.
+func DefaultConsistencyRulePack() *ConsistencyRulePack {
+	return &ConsistencyRulePack{
+		Name:          "default-v1",
+		LowRiskMin:    90,
+		MediumRiskMin: 60,
+		Rules: []ConsistencyRule{
+			{ID: "ua-tls-browser-mismatch", Category: "ua-tls", Severity: "high", Weight: 25, Enabled: true},
+			{ID: "ua-http2-browser-mismatch", Category: "ua-http2", Severity: "medium", Weight: 20, Enabled: true},
+			{ID: "tls-http2-mismatch", Category: "tls-http2", Severity: "medium", Weight: 20, Enabled: true},
+			{ID: "http2-impersonator", Category: "tls-http2", Severity: "critical", Weight: 30, Enabled: true},
+			{ID: "tcp-os-mismatch", Category: "tcp-ua", Severity: "high", Weight: 35, Enabled: true},
+			{ID: "tcp-collector-anomaly", Category: "tcp", Severity: "low", Weight: 10, Enabled: true},
+			{ID: "tls-library-vs-browser-ua", Category: "tls-ua", Severity: "high", Weight: 25, Enabled: true},
+			{ID: "mobile-ua-desktop-ttl", Category: "tcp-ua", Severity: "medium", Weight: 20, Enabled: true},
+			{ID: "mitm-corpus-likely-mitm", Category: "mitm", Severity: "medium", Weight: 20, Enabled: true},
+			{ID: "pq-keyshare-version-mismatch", Category: "tls-pq", Severity: "low", Weight: 15, Enabled: true},
+			{ID: "ja4h-client-mismatch", Category: "http-tls-http2", Severity: "medium", Weight: 20, Enabled: true},
+		},
+	}
+}
+
+// ConsistencyRuleEngine evaluates a ConsistencyRulePack against
+// analyzeConsistency's findings.
+type ConsistencyRuleEngine struct {
+	pack *ConsistencyRulePack
+	byID map[string]ConsistencyRule
+}
+
+// NewConsistencyRuleEngine indexes pack's rules by ID for Fire. A nil or
+// empty pack still works - every Fire call simply finds nothing and
+// returns false, which is how an operator effectively disables the whole
+// engine without special-casing it elsewhere.
+func NewConsistencyRuleEngine(pack *ConsistencyRulePack) *ConsistencyRuleEngine {
+	if pack == nil {
+		pack = &ConsistencyRulePack{Name: "empty", LowRiskMin: 90, MediumRiskMin: 60}
+	}
+	byID := make(map[string]ConsistencyRule, len(pack.Rules))
+	for _, r := range pack.Rules {
+		byID[r.ID] = r
+	}
+	return &ConsistencyRuleEngine{pack: pack, byID: byID}
+}
+
+// Fire applies ruleID against check if the rule is known and enabled,
+// subtracting its weight from check.Score and recording ruleID in
+// check.RuleFired. Returns whether the rule fired, so a caller only
+// appends its Anomalies/Details line when it actually did.
+func (e *ConsistencyRuleEngine) Fire(check *ConsistencyAnalysis, ruleID string) bool {
+	rule, ok := e.byID[ruleID]
+	if !ok || !rule.Enabled {
+		return false
+	}
+	check.Score -= rule.Weight
+	check.RuleFired = append(check.RuleFired, ruleID)
+	return true
+}
+
+// LowRiskMin and MediumRiskMin expose the active pack's risk thresholds to
+// generateSummary, replacing its previous hardcoded >=90/>=60.
+func (e *ConsistencyRuleEngine) LowRiskMin() int    { return e.pack.LowRiskMin }
+func (e *ConsistencyRuleEngine) MediumRiskMin() int { return e.pack.MediumRiskMin }
+
+// PackName returns the active pack's name (e.g. "default-v1"), for
+// Observations/logging.
+func (e *ConsistencyRuleEngine) PackName() string { return e.pack.Name }
@@ -7,14 +7,63 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 )
 
 // FingerprintDatabase holds all loaded fingerprint data
 type FingerprintDatabase struct {
-	JA3  *JA3Database  `json:"ja3"`
-	JA4  *JA4Database  `json:"ja4"`
-	HTTP2 *HTTP2Database `json:"http2"`
-	mu   sync.RWMutex
+	JA3       *JA3Database     `json:"ja3"`
+	JA4       *JA4Database     `json:"ja4"`
+	HTTP2     *HTTP2Database   `json:"http2"`
+	JA3Corpus []JA3CorpusEntry `json:"ja3_corpus,omitempty"`
+
+	// JA4H holds the HTTP-request fingerprint lookup table ComputeJA4H's
+	// output resolves against (see ja4h.go). Falls back to
+	// DefaultJA4HDatabase() when ./data/ja4h_fingerprints.json isn't present.
+	JA4H *JA4HDatabase `json:"-"`
+
+	// Recog holds Recog-style (metasploit-framework/recog) fingerprint
+	// definitions loaded from ./data/recog/*.xml - see recog.go. This is an
+	// optional, additive source: operators who never populate that directory
+	// get a non-nil but empty index, and LookupJA3/LookupHTTP2/parseUserAgent's
+	// caller keep working off the JSON databases alone.
+	Recog *RecogIndex `json:"-"`
+
+	// MITM holds the (UA family, UA major version) -> expected ClientHello
+	// corpus used by EvaluateMITMFingerprint (see mitm.go) to classify a
+	// request as match/likely_mitm/unknown_ua/bad_header. Falls back to
+	// DefaultMITMDatabase() when ./data/mitm_corpus.json isn't present.
+	MITM MITMDatabase `json:"-"`
+
+	// Favicon maps a MMH3Hash32 hash (see favicon.go) to the known
+	// framework/CMS/CDN it identifies. Falls back to DefaultFaviconCorpus()
+	// when ./data/favicon_corpus.json isn't present.
+	Favicon map[string]FaviconEntry `json:"-"`
+
+	// HTTP2Profiles is the ClassifyHTTP2/DiffAgainstClosestBrowser corpus
+	// (see http2profile.go). Falls back to DefaultHTTP2ProfileCorpus() when
+	// ./data/http2_profile_corpus.json isn't present.
+	HTTP2Profiles []HTTP2Profile `json:"-"`
+
+	// ConsistencyRules is the active ConsistencyRuleEngine (see
+	// consistency_rules.go) analyzeConsistency/generateSummary fire rules
+	// against. Falls back to DefaultConsistencyRulePack() ("default-v1")
+	// when ./data/consistency_rules.json isn't present.
+	ConsistencyRules *ConsistencyRuleEngine `json:"-"`
+
+	// ImpersonatorRules is the compiled rule set
+	// IsImpersonatorByHTTP2Rules evaluates (see rules.go). Sourced from
+	// HTTP2.DetectionRules.ImpersonatorSignals when http2_fingerprints.json
+	// is present and compiles cleanly, falling back to
+	// DefaultImpersonatorRules() otherwise.
+	ImpersonatorRules *ImpersonatorRuleSet `json:"-"`
+
+	mu sync.RWMutex
+
+	// mtimes records the last-seen modification time of each loaded database
+	// file, so StartHotReload can detect edits with a stat poll rather than
+	// pulling in a file-watch dependency just for this.
+	mtimes map[string]time.Time
 }
 
 // JA3Database holds JA3 fingerprint mappings
@@ -54,12 +103,12 @@ type HTTP2Database struct {
 		Libraries    map[string]HTTP2Entry `json:"libraries"`
 	} `json:"fingerprints"`
 	DetectionRules struct {
-		ImpersonatorSignals []struct {
-			Rule        string `json:"rule"`
-			Description string `json:"description"`
-			Weight      int    `json:"weight"`
-			AppliesTo   string `json:"applies_to"`
-		} `json:"impersonator_signals"`
+		// ImpersonatorSignals is the raw rule DSL IsImpersonatorByHTTP2Rules
+		// compiles into FingerprintDatabase.ImpersonatorRules (see rules.go) -
+		// each entry a weighted When/Unless predicate over an
+		// AkamaiFingerprint, replacing what used to be six hardcoded Go
+		// if-blocks.
+		ImpersonatorSignals []ImpersonatorRule `json:"impersonator_signals"`
 		BrowserSignatures map[string]struct {
 			WindowUpdate      int    `json:"window_update"`
 			PseudoHeaderOrder string `json:"pseudo_header_order"`
@@ -127,6 +176,10 @@ func (db *FingerprintDatabase) Load() error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	if db.mtimes == nil {
+		db.mtimes = make(map[string]time.Time)
+	}
+
 	// Find the data directory
 	dataDir := findDataDir()
 
@@ -164,6 +217,24 @@ func (db *FingerprintDatabase) Load() error {
 		log.Printf("[DB] HTTP/2 database not found: %s", http2Path)
 	}
 
+	// Compile the impersonator rule DSL (see rules.go) from whatever
+	// impersonator_signals the HTTP/2 database above carried, falling back
+	// to the built-in baseline when it's absent or fails to compile -same
+	// shape as the corpora below, which never fail Load() outright.
+	db.ImpersonatorRules = nil
+	if db.HTTP2 != nil && len(db.HTTP2.DetectionRules.ImpersonatorSignals) > 0 {
+		rs, errs := CompileImpersonatorRules(db.HTTP2.DetectionRules.ImpersonatorSignals)
+		for _, e := range errs {
+			log.Printf("[DB] Skipping invalid impersonator rule: %v", e)
+		}
+		db.ImpersonatorRules = rs
+	}
+	if db.ImpersonatorRules == nil || len(db.ImpersonatorRules.rules) == 0 {
+		rs, _ := CompileImpersonatorRules(DefaultImpersonatorRules())
+		db.ImpersonatorRules = rs
+		log.Printf("[DB] Using built-in impersonator rule baseline (%d rules)", len(rs.rules))
+	}
+
 	// Load JA4 database
 	ja4Path := filepath.Join(dataDir, "ja4_fingerprints.json")
 	if data, err := os.ReadFile(ja4Path); err == nil {
@@ -180,9 +251,225 @@ func (db *FingerprintDatabase) Load() error {
 		log.Printf("[DB] JA4 database not found: %s", ja4Path)
 	}
 
+	// Load the JA4H (HTTP-request) fingerprint lookup table (see ja4h.go).
+	// Like the corpora below, a missing file just means the built-in
+	// baseline stays active rather than failing the whole Load().
+	ja4hPath := filepath.Join(dataDir, "ja4h_fingerprints.json")
+	if data, err := os.ReadFile(ja4hPath); err == nil {
+		var ja4hDB JA4HDatabase
+		if err := json.Unmarshal(data, &ja4hDB); err == nil {
+			db.JA4H = &ja4hDB
+			log.Printf("[DB] Loaded JA4H database: %d known fingerprints", len(ja4hDB.KnownFingerprints))
+		} else {
+			log.Printf("[DB] Failed to parse JA4H database: %v", err)
+		}
+	} else {
+		if db.JA4H == nil {
+			db.JA4H = DefaultJA4HDatabase()
+		}
+		log.Printf("[DB] JA4H database not found at %s, using built-in baseline", ja4hPath)
+	}
+
+	// Load JA3 fuzzy-match corpus (distilled from corpora such as
+	// trisulnsm/ja3prints and salesforce/ja3 pulls) used by FuzzyMatchJA3
+	// when a ClientHello's exact JA3 hash misses the curated database above.
+	corpusPath := filepath.Join(dataDir, "ja3_corpus.json")
+	if data, err := os.ReadFile(corpusPath); err == nil {
+		var corpus []JA3CorpusEntry
+		if err := json.Unmarshal(data, &corpus); err == nil {
+			db.JA3Corpus = corpus
+			log.Printf("[DB] Loaded JA3 fuzzy-match corpus: %d entries", len(corpus))
+		} else {
+			log.Printf("[DB] Failed to parse JA3 corpus: %v", err)
+		}
+	} else {
+		log.Printf("[DB] JA3 corpus not found: %s", corpusPath)
+	}
+
+	// Load Recog-style XML fingerprints. This is additive to the JSON
+	// databases above, so a missing/empty ./data/recog/ directory is not an
+	// error - it just means LookupJA3Recog/LookupHTTP2Recog/LookupUA always miss.
+	recogDir := filepath.Join(dataDir, "recog")
+	recogIndex, recogFiles, err := LoadRecogDir(recogDir)
+	if err != nil {
+		log.Printf("[DB] Failed to read recog directory %s: %v", recogDir, err)
+		recogIndex = &RecogIndex{}
+	} else {
+		log.Printf("[DB] Loaded recog fingerprints from %s: %s", recogDir, recogIndexSummary(recogIndex))
+	}
+	db.Recog = recogIndex
+
+	// Load the MITM-detection corpus (see mitm.go). Like the JA3 corpus
+	// above, a missing file just means EvaluateMITMFingerprint falls back to
+	// the built-in baseline rather than failing the whole Load().
+	mitmCorpusPath := filepath.Join(dataDir, "mitm_corpus.json")
+	if loaded, err := LoadMITMDatabaseFile(mitmCorpusPath); err == nil {
+		db.MITM = loaded
+		log.Printf("[DB] Loaded MITM-detection corpus from %s", mitmCorpusPath)
+	} else {
+		if db.MITM == nil {
+			db.MITM = DefaultMITMDatabase()
+		}
+		log.Printf("[DB] MITM corpus not found at %s, using built-in baseline", mitmCorpusPath)
+	}
+
+	// Load the favicon-hash corpus (see favicon.go). Like the MITM corpus
+	// above, a missing file just means LookupFavicon always misses rather
+	// than failing the whole Load().
+	faviconCorpusPath := filepath.Join(dataDir, "favicon_corpus.json")
+	if data, err := os.ReadFile(faviconCorpusPath); err == nil {
+		var entries []FaviconEntry
+		if err := json.Unmarshal(data, &entries); err == nil {
+			db.Favicon = indexFaviconCorpus(entries)
+			log.Printf("[DB] Loaded favicon corpus from %s: %d entries", faviconCorpusPath, len(entries))
+		} else {
+			log.Printf("[DB] Failed to parse favicon corpus: %v", err)
+		}
+	} else {
+		if db.Favicon == nil {
+			db.Favicon = indexFaviconCorpus(DefaultFaviconCorpus())
+		}
+		log.Printf("[DB] Favicon corpus not found at %s, using built-in baseline", faviconCorpusPath)
+	}
+
+	// Load the HTTP/2 profile corpus (see http2profile.go). Like the MITM
+	// and favicon corpora above, a missing file just means ClassifyHTTP2
+	// runs against the built-in baseline rather than failing the whole Load().
+	http2ProfileCorpusPath := filepath.Join(dataDir, "http2_profile_corpus.json")
+	if data, err := os.ReadFile(http2ProfileCorpusPath); err == nil {
+		var profiles []HTTP2Profile
+		if err := json.Unmarshal(data, &profiles); err == nil {
+			db.HTTP2Profiles = profiles
+			log.Printf("[DB] Loaded HTTP/2 profile corpus from %s: %d entries", http2ProfileCorpusPath, len(profiles))
+		} else {
+			log.Printf("[DB] Failed to parse HTTP/2 profile corpus: %v", err)
+		}
+	} else {
+		if db.HTTP2Profiles == nil {
+			db.HTTP2Profiles = DefaultHTTP2ProfileCorpus()
+		}
+		log.Printf("[DB] HTTP/2 profile corpus not found at %s, using built-in baseline", http2ProfileCorpusPath)
+	}
+
+	// Load the consistency rule pack (see consistency_rules.go). Like the
+	// corpora above, a missing file just means the built-in "default-v1"
+	// pack stays active rather than failing the whole Load().
+	consistencyRulesPath := filepath.Join(dataDir, "consistency_rules.json")
+	if data, err := os.ReadFile(consistencyRulesPath); err == nil {
+		var pack ConsistencyRulePack
+		if err := json.Unmarshal(data, &pack); err == nil {
+			db.ConsistencyRules = NewConsistencyRuleEngine(&pack)
+			log.Printf("[DB] Loaded consistency rule pack %q from %s: %d rules", pack.Name, consistencyRulesPath, len(pack.Rules))
+		} else {
+			log.Printf("[DB] Failed to parse consistency rule pack: %v", err)
+		}
+	} else {
+		if db.ConsistencyRules == nil {
+			db.ConsistencyRules = NewConsistencyRuleEngine(DefaultConsistencyRulePack())
+		}
+		log.Printf("[DB] Consistency rule pack not found at %s, using built-in baseline", consistencyRulesPath)
+	}
+
+	// Watch the recog directory itself, not just the files found in it this
+	// pass, so dropping in a brand-new XML file (which doesn't change any
+	// already-tracked file's mtime) still triggers a reload.
+	watchPaths := append([]string{ja3Path, http2Path, ja4Path, ja4hPath, corpusPath, recogDir, mitmCorpusPath, faviconCorpusPath, http2ProfileCorpusPath, consistencyRulesPath}, recogFiles...)
+	for _, path := range watchPaths {
+		if info, err := os.Stat(path); err == nil {
+			db.mtimes[path] = info.ModTime()
+		}
+	}
+
 	return nil
 }
 
+// SetMITM installs db as the active MITM-detection corpus, for callers (the
+// -mitm-corpus-url flag in main.go) that fetch one after Load() has already run.
+func (db *FingerprintDatabase) SetMITM(corpus MITMDatabase) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.MITM = corpus
+}
+
+// GetMITM returns the active MITM-detection corpus for EvaluateMITMFingerprint.
+func (db *FingerprintDatabase) GetMITM() MITMDatabase {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.MITM
+}
+
+// indexFaviconCorpus keys a flat FaviconEntry list by its MMH3 hash for
+// LookupFavicon, the same shape NewMapMITMDatabase builds for ClientRecord.
+func indexFaviconCorpus(entries []FaviconEntry) map[string]FaviconEntry {
+	m := make(map[string]FaviconEntry, len(entries))
+	for _, e := range entries {
+		m[e.Hash] = e
+	}
+	return m
+}
+
+// LookupFavicon looks up an MMH3Hash32 hash (see favicon.go) in the
+// favicon corpus.
+func (db *FingerprintDatabase) LookupFavicon(hash string) (FaviconEntry, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	entry, ok := db.Favicon[hash]
+	return entry, ok
+}
+
+// GetHTTP2Profiles returns the active HTTP/2 profile corpus for
+// ClassifyHTTP2/DiffAgainstClosestBrowser (see http2profile.go).
+func (db *FingerprintDatabase) GetHTTP2Profiles() []HTTP2Profile {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.HTTP2Profiles
+}
+
+// GetConsistencyRules returns the active ConsistencyRuleEngine for
+// analyzeConsistency/generateSummary (see consistency_rules.go).
+func (db *FingerprintDatabase) GetConsistencyRules() *ConsistencyRuleEngine {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.ConsistencyRules
+}
+
+// StartHotReload polls the loaded database files every interval and calls
+// Load again when any of their modification times change, so an operator
+// updating ja3_fingerprints.json/ja3_corpus.json/etc. on disk doesn't require
+// a server restart. This is a plain stat poll rather than a file-watch
+// library - good enough for files that change a few times a day.
+func (db *FingerprintDatabase) StartHotReload(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			if db.filesChanged() {
+				log.Printf("[DB] Fingerprint database files changed on disk, reloading...")
+				if err := db.Load(); err != nil {
+					log.Printf("[DB] Reload failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// filesChanged reports whether any previously-loaded database file's mtime
+// has moved since the last Load call.
+func (db *FingerprintDatabase) filesChanged() bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for path, known := range db.mtimes {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(known) {
+			return true
+		}
+	}
+	return false
+}
+
 // findDataDir locates the data directory
 func findDataDir() string {
 	// Try relative to executable
@@ -217,12 +504,16 @@ func findDataDir() string {
 }
 
 // LookupJA3 looks up a JA3 hash in the database
-func (db *FingerprintDatabase) LookupJA3(hash string) (string, string, bool) {
+// LookupJA3 looks up a JA3 hash in the database. The fourth return value is
+// the matched entry's claimed browser version (JA3Entry.Version), when the
+// database curator recorded one - used by analyzeVersionPolicy to cross-check
+// the TLS-fingerprint-implied version against the UA's claimed version.
+func (db *FingerprintDatabase) LookupJA3(hash string) (string, string, string, bool) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
 	if db.JA3 == nil {
-		return "", "", false
+		return "", "", "", false
 	}
 
 	// Check browsers
@@ -234,35 +525,35 @@ func (db *FingerprintDatabase) LookupJA3(hash string) (string, string, bool) {
 		if entry.Version != "" {
 			name += " " + entry.Version
 		}
-		return name, "browser", true
+		return name, "browser", entry.Version, true
 	}
 
 	// Check libraries
 	if entry, ok := db.JA3.Fingerprints.Libraries[hash]; ok {
-		return entry.Name, "library", true
+		return entry.Name, "library", entry.Version, true
 	}
 
 	// Check bots
 	if entry, ok := db.JA3.Fingerprints.Bots[hash]; ok {
-		return entry.Name, "bot", true
+		return entry.Name, "bot", entry.Version, true
 	}
 
 	// Check malware
 	if entry, ok := db.JA3.Fingerprints.Malware[hash]; ok {
-		return entry.Name + " (Malware)", "malware", true
+		return entry.Name + " (Malware)", "malware", entry.Version, true
 	}
 
 	// Check mobile
 	if entry, ok := db.JA3.Fingerprints.Mobile[hash]; ok {
-		return entry.Name, "mobile", true
+		return entry.Name, "mobile", entry.Version, true
 	}
 
 	// Check apps
 	if entry, ok := db.JA3.Fingerprints.Apps[hash]; ok {
-		return entry.Name, "app", true
+		return entry.Name, "app", entry.Version, true
 	}
 
-	return "", "", false
+	return "", "", "", false
 }
 
 // LookupHTTP2 looks up an HTTP/2 fingerprint in the database
@@ -313,108 +604,32 @@ func (db *FingerprintDatabase) GetJA4Description(ja4 string) (string, string, st
 	return "", "", ""
 }
 
-// IsImpersonatorByHTTP2Rules checks HTTP/2 fingerprint against detection rules
-// 核心逻辑: SETTINGS + pseudo_header_order + WINDOW_UPDATE 必须来自同一个浏览器
-// 参考: https://lwthiker.com/networks/2022/06/17/http2-fingerprinting.html
-// 参考: Akamai Black Hat EU 2017 白皮书
+// IsImpersonatorByHTTP2Rules checks an Akamai-format HTTP/2 fingerprint
+// against db.ImpersonatorRules (see rules.go): SETTINGS + WINDOW_UPDATE +
+// pseudo_header_order must all come from the same browser, or enough of the
+// weighted signals below fire to call it an impersonator. pseudoOrder is
+// HTTP2Fingerprint.PseudoHeaderOrder, or "" to fall back to akamai's own
+// fourth field (see parseAkamaiFingerprint).
+// Reference: https://lwthiker.com/networks/2022/06/17/http2-fingerprinting.html
+// Reference: Akamai Black Hat EU 2017 whitepaper
+//
+// This used to be six hardcoded Chrome/Safari/Firefox/curl-impersonate
+// if-blocks; they're now data (DefaultImpersonatorRules, overridable via
+// ./data/http2_fingerprints.json's detection_rules.impersonator_signals).
 func (db *FingerprintDatabase) IsImpersonatorByHTTP2Rules(akamai string, pseudoOrder string) (bool, []string) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
-	var reasons []string
-	signals := 0
-
 	if db.HTTP2 == nil {
-		return false, reasons
+		return false, nil
 	}
 
-	// 解析 Akamai 指纹: SETTINGS|WINDOW_UPDATE|PRIORITY|pseudo_header_order
-	parts := strings.Split(akamai, "|")
-	if len(parts) < 4 {
-		return false, reasons
-	}
-
-	settingsPart := parts[0]
-	windowUpdate := parts[1]
-	// priority := parts[2]  // 暂不使用
-	akamaiPseudo := parts[3]
-
-	if pseudoOrder == "" {
-		pseudoOrder = akamaiPseudo
-	}
-
-	// ========== 浏览器特征定义 ==========
-	// Chrome: SETTINGS=1:65536;2:0;4:6291456;6:262144, WU=15663105, pseudo=m,a,s,p
-	// Firefox: SETTINGS=1:65536;4:131072;5:16384, WU=12517377, pseudo=m,p,a,s
-	// Safari: SETTINGS=2:0;3:100;4:2097152;9:1, WU=10420225, pseudo=m,s,a 或 m,s,p,a
-	// curl-impersonate: 复制 Chrome SETTINGS, 但 pseudo 可能是 m,a,s (缺少 p)
-
-	// 检测 Chrome-like SETTINGS
-	isChromeLikeSettings := strings.Contains(settingsPart, "4:6291456") && strings.Contains(settingsPart, "6:262144")
-	isChromeLikeWU := windowUpdate == "15663105"
-
-	// 检测 Safari-like SETTINGS
-	isSafariLikeSettings := strings.HasPrefix(settingsPart, "2:0") && strings.Contains(settingsPart, "9:1")
-	isSafariLikeWU := windowUpdate == "10420225"
-
-	// 检测 Firefox-like SETTINGS
-	isFirefoxLikeSettings := strings.Contains(settingsPart, "4:131072") && strings.Contains(settingsPart, "5:16384")
-	isFirefoxLikeWU := windowUpdate == "12517377"
-
-	// ========== 组合检测 ==========
-
-	// 情况1: Chrome SETTINGS + Chrome WU，但 pseudo 不是 m,a,s,p
-	if isChromeLikeSettings && isChromeLikeWU {
-		if pseudoOrder != "" && pseudoOrder != "m,a,s,p" {
-			signals += 3
-			reasons = append(reasons,
-				"Chrome SETTINGS+WU but pseudo_header_order='"+pseudoOrder+"' (expected 'm,a,s,p') - likely curl-impersonate")
-		}
-	}
-
-	// 情况2: Chrome SETTINGS，但 WU 不匹配
-	if isChromeLikeSettings && !isChromeLikeWU && windowUpdate != "0" {
-		signals += 1
-		reasons = append(reasons,
-			"Chrome-like SETTINGS but WINDOW_UPDATE="+windowUpdate+" (Chrome uses 15663105)")
-	}
-
-	// 情况3: Safari SETTINGS + Safari WU，但 pseudo 不对
-	if isSafariLikeSettings && isSafariLikeWU {
-		if pseudoOrder != "" && pseudoOrder != "m,s,a" && pseudoOrder != "m,s,p,a" {
-			signals += 3
-			reasons = append(reasons,
-				"Safari SETTINGS+WU but pseudo_header_order='"+pseudoOrder+"' (expected 'm,s,a' or 'm,s,p,a')")
-		}
-	}
-
-	// 情况4: Firefox SETTINGS + Firefox WU，但 pseudo 不是 m,p,a,s
-	if isFirefoxLikeSettings && isFirefoxLikeWU {
-		if pseudoOrder != "" && pseudoOrder != "m,p,a,s" {
-			signals += 3
-			reasons = append(reasons,
-				"Firefox SETTINGS+WU but pseudo_header_order='"+pseudoOrder+"' (expected 'm,p,a,s')")
-		}
-	}
-
-	// 情况5: curl-impersonate 的典型特征
-	// Chrome SETTINGS + pseudo=m,a,s (缺少 :path)
-	if isChromeLikeSettings && pseudoOrder == "m,a,s" {
-		signals += 2
-		reasons = append(reasons, "curl-impersonate signature: Chrome SETTINGS with pseudo='m,a,s' (missing ':path')")
-	}
-
-	// 情况6: 混合特征 - SETTINGS 和 WU 来自不同浏览器
-	if isChromeLikeSettings && isSafariLikeWU {
-		signals += 2
-		reasons = append(reasons, "Mixed fingerprint: Chrome SETTINGS with Safari WINDOW_UPDATE")
-	}
-	if isSafariLikeSettings && isChromeLikeWU {
-		signals += 2
-		reasons = append(reasons, "Mixed fingerprint: Safari SETTINGS with Chrome WINDOW_UPDATE")
+	fp, ok := parseAkamaiFingerprint(akamai, pseudoOrder)
+	if !ok {
+		return false, nil
 	}
 
-	return signals >= 3, reasons
+	return db.ImpersonatorRules.Evaluate(fp, 3)
 }
 
 // GetBrowserSignature returns expected signature for a browser
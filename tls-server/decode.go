@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// syntheticDecodeRemoteAddr is the clientIP passed to AnalyzeFingerprint for
+// hellos submitted via /api/decode, since there's no real connection to read
+// one from.
+const syntheticDecodeRemoteAddr = "decoded-hello:0"
+
+// DecodeClientHelloHex parses a hex-encoded TLS ClientHello - optionally
+// already wrapped in its 5-byte TLS record header, as it would appear in a
+// pcap - using the same parseClientHello used for live connections, and
+// returns it alongside an AnalyzeFingerprint result. This lets /api/decode
+// turn captured or hand-crafted hellos into the same fingerprint/JA3/JA4/
+// analysis JSON a real handshake over the wire would produce.
+func DecodeClientHelloHex(hexStr string, userAgent string, headers map[string]string) map[string]interface{} {
+	hexStr = stripHexWhitespace(hexStr)
+	if hexStr == "" {
+		return map[string]interface{}{"success": false, "error": "missing hello parameter"}
+	}
+
+	data, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return map[string]interface{}{"success": false, "error": fmt.Sprintf("invalid hex: %v", err)}
+	}
+
+	var record []byte
+	switch {
+	case len(data) >= 5 && data[0] == 22: // already has the TLS record header
+		record = data
+	case len(data) >= 4 && data[0] == 1: // bare handshake message (type ClientHello)
+		record = wrapHandshakeRecord(data)
+	default:
+		return map[string]interface{}{"success": false, "error": "unrecognized input: expected a TLS record (starting 0x16) or a ClientHello handshake message (starting 0x01)"}
+	}
+
+	tlsFp, err := parseClientHello(record)
+	if err != nil {
+		return map[string]interface{}{"success": false, "error": fmt.Sprintf("parse error: %v", err)}
+	}
+
+	combined := &CombinedFingerprint{TLS: tlsFp}
+	analysis := AnalyzeFingerprint(combined, syntheticDecodeRemoteAddr, userAgent, headers)
+
+	return map[string]interface{}{
+		"success":     true,
+		"fingerprint": combined,
+		"analysis":    analysis,
+	}
+}
+
+// stripHexWhitespace drops spaces, colons and a leading "0x" - common ways
+// hex is copy-pasted out of pcap tools - so the hello param can be pasted in
+// as-is.
+func stripHexWhitespace(s string) string {
+	if len(s) >= 2 && (s[0:2] == "0x" || s[0:2] == "0X") {
+		s = s[2:]
+	}
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == ' ' || c == ':' || c == '\t' || c == '\n' || c == '\r' {
+			continue
+		}
+		out = append(out, c)
+	}
+	return string(out)
+}
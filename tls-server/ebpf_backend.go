@@ -0,0 +1,112 @@
+//go:build !nolibpcap && linux && ebpf
+// +build !nolibpcap
+// +build linux
+// +build ebpf
+
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/asavie/xdp"
+	"github.com/cilium/ebpf/link"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// ebpfObjPath is the compiled XDP redirect-to-AF_XDP program, generated ahead
+// of time (bpf2go) and loaded at startup rather than compiled on the fly.
+const ebpfObjPath = "/usr/lib/fingerprint-collector/xdp_redirect.o"
+
+// ebpfBackend captures via an AF_XDP socket fed by a minimal XDP program that
+// redirects matching TCP control packets (SYN/SYN-ACK/RST) into the socket's
+// UMEM ring, bypassing the kernel network stack entirely for those packets.
+type ebpfBackend struct {
+	link    link.Link
+	sock    *xdp.Socket
+	program *xdp.Program
+	packets chan gopacket.Packet
+	done    chan struct{}
+}
+
+func newEBPFBackend(iface string, port int, mode CaptureMode, cfg CaptureConfig) (CaptureBackend, error) {
+	if iface == "" {
+		return nil, fmt.Errorf("ebpf backend requires an explicit interface")
+	}
+
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, fmt.Errorf("ebpf: unknown interface %s: %v", iface, err)
+	}
+
+	program, err := xdp.LoadProgram(ebpfObjPath, "xdp_redirect")
+	if err != nil {
+		return nil, fmt.Errorf("ebpf: failed to load %s: %v", ebpfObjPath, err)
+	}
+
+	sock, err := xdp.NewSocket(ifi.Index, 0, nil)
+	if err != nil {
+		program.Close()
+		return nil, fmt.Errorf("ebpf: failed to open AF_XDP socket on %s: %v", iface, err)
+	}
+	if err := program.Register(0, sock.FD()); err != nil {
+		sock.Close()
+		program.Close()
+		return nil, fmt.Errorf("ebpf: failed to register socket in XSKMAP: %v", err)
+	}
+
+	lnk, err := link.AttachXDP(link.XDPOptions{
+		Program:   program.Program,
+		Interface: ifi.Index,
+	})
+	if err != nil {
+		sock.Close()
+		program.Close()
+		return nil, fmt.Errorf("ebpf: failed to attach XDP program to %s: %v", iface, err)
+	}
+
+	b := &ebpfBackend{
+		link:    lnk,
+		sock:    sock,
+		program: program,
+		packets: make(chan gopacket.Packet, 1024),
+		done:    make(chan struct{}),
+	}
+
+	go b.readLoop(mode, port)
+
+	return b, nil
+}
+
+func (b *ebpfBackend) readLoop(mode CaptureMode, port int) {
+	defer close(b.packets)
+	for {
+		select {
+		case <-b.done:
+			return
+		default:
+		}
+
+		n, err := b.sock.Poll(-1)
+		if err != nil || n == 0 {
+			continue
+		}
+		for _, desc := range b.sock.Receive(n) {
+			frame := b.sock.GetFrame(desc)
+			packet := gopacket.NewPacket(frame, layers.LayerTypeEthernet, gopacket.NoCopy)
+			if afpacketMatchesMode(packet, mode, port) {
+				b.packets <- packet
+			}
+		}
+	}
+}
+
+func (b *ebpfBackend) Packets() <-chan gopacket.Packet { return b.packets }
+
+func (b *ebpfBackend) Close() {
+	close(b.done)
+	b.link.Close()
+	b.sock.Close()
+	b.program.Close()
+}
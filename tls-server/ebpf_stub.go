@@ -0,0 +1,15 @@
+//go:build !nolibpcap && (linux || darwin || windows) && (!ebpf || !linux)
+// +build !nolibpcap
+// +build linux darwin windows
+// +build !ebpf !linux
+
+package main
+
+import "fmt"
+
+// newEBPFBackend is a stub unless built with -tags ebpf on Linux: the real
+// backend needs an XDP/eBPF toolchain (bpf2go-generated objects) that we don't
+// want to drag into every build.
+func newEBPFBackend(iface string, port int, mode CaptureMode, cfg CaptureConfig) (CaptureBackend, error) {
+	return nil, fmt.Errorf("ebpf capture backend requires building with -tags ebpf on Linux")
+}
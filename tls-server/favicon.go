@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math/bits"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This file implements Shodan-style favicon fingerprinting: base64-encode a
+// site's favicon bytes (wrapped at 76 chars/line, the same wrapping Python's
+// base64.encodebytes and `openssl base64` both produce) and take the signed
+// 32-bit MurmurHash3 of the result - exactly the recipe behind Shodan's
+// `http.favicon.hash` search filter. A server's favicon is typically the
+// framework/CMS/CDN default, not the client's, so it's an independent signal
+// from everything TLSAnalysis/HTTP2Analysis infer about the client: the
+// summary can read "client=Chrome, server-favicon=Cloudflare-Access" even
+// when the TLS/HTTP2 layers only see the browser.
+
+// FaviconAnalysis is the result of fetching and hashing a site's favicon.
+type FaviconAnalysis struct {
+	Detected      bool     `json:"detected"`
+	URL           string   `json:"url,omitempty"`
+	MMH3          string   `json:"mmh3,omitempty"`
+	SizeBytes     int      `json:"size_bytes,omitempty"`
+	Match         string   `json:"match,omitempty"`          // framework/CMS/CDN name from the corpus, if any
+	MatchCategory string   `json:"match_category,omitempty"` // "framework", "cms", "cdn", "waf", ...
+	Observations  []string `json:"observations,omitempty"`
+}
+
+// faviconRelFirstRe and faviconHrefFirstRe both match a favicon <link> tag,
+// differing only in whether rel= or href= comes first in the tag - HTML
+// doesn't fix an attribute order and real-world markup uses both.
+var (
+	faviconRelFirstRe  = regexp.MustCompile(`(?is)<link[^>]*\brel=["']?(?:shortcut icon|icon)["']?[^>]*\bhref=["']([^"'>]+)["']`)
+	faviconHrefFirstRe = regexp.MustCompile(`(?is)<link[^>]*\bhref=["']([^"'>]+)["'][^>]*\brel=["']?(?:shortcut icon|icon)["']?`)
+)
+
+// FindFaviconURL extracts the href of a <link rel="shortcut icon"|"icon">
+// tag from an HTML document body, or "" if none is present. Callers that
+// have already fetched a page's HTML (e.g. a future crawler-style endpoint)
+// can pass its body here to get the declared favicon path instead of
+// falling back to the conventional /favicon.ico.
+func FindFaviconURL(body string) string {
+	if m := faviconRelFirstRe.FindStringSubmatch(body); m != nil {
+		return m[1]
+	}
+	if m := faviconHrefFirstRe.FindStringSubmatch(body); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// faviconBase64Lines re-encodes data as standard base64, wrapped at 76
+// characters per line with a trailing newline - the wrapping Shodan's
+// recipe hashes instead of the raw unwrapped base64 string.
+func faviconBase64Lines(data []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var b strings.Builder
+	for i := 0; i < len(encoded); i += 76 {
+		end := i + 76
+		if end > len(encoded) {
+			end = len(encoded)
+		}
+		b.WriteString(encoded[i:end])
+		b.WriteByte('\n')
+	}
+	return []byte(b.String())
+}
+
+// MMH3Hash32 computes the signed 32-bit MurmurHash3 (x86_32 variant, seed 0)
+// of data and renders it as a decimal string, matching the sign convention
+// the mmh3 Python package (and therefore Shodan's http.favicon.hash) uses.
+func MMH3Hash32(data []byte) string {
+	return strconv.FormatInt(int64(int32(murmurHash3X86_32(data, 0))), 10)
+}
+
+// murmurHash3X86_32 is the standard public-domain MurmurHash3 x86_32
+// algorithm (Austin Appleby).
+func murmurHash3X86_32(data []byte, seed uint32) uint32 {
+	const c1, c2 = 0xcc9e2d51, 0x1b873593
+
+	h := seed
+	nblocks := len(data) / 4
+	for i := 0; i < nblocks; i++ {
+		k := uint32(data[i*4]) | uint32(data[i*4+1])<<8 | uint32(data[i*4+2])<<16 | uint32(data[i*4+3])<<24
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	var k1 uint32
+	tail := data[nblocks*4:]
+	switch len(tail) {
+	case 3:
+		k1 ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k1 ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k1 ^= uint32(tail[0])
+		k1 *= c1
+		k1 = bits.RotateLeft32(k1, 15)
+		k1 *= c2
+		h ^= k1
+	}
+
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
+
+// FaviconHash computes the Shodan-style http.favicon.hash of raw favicon
+// bytes: base64-encode at 76 chars/line, then MMH3Hash32 the result.
+func FaviconHash(data []byte) string {
+	return MMH3Hash32(faviconBase64Lines(data))
+}
+
+// FaviconEntry is one corpus record: the framework/CMS/CDN a given favicon
+// MMH3 hash is known to belong to.
+type FaviconEntry struct {
+	Hash     string `json:"hash"` // MMH3Hash32 output, e.g. "-1255484254"
+	Name     string `json:"name"`
+	Category string `json:"category,omitempty"` // framework, cms, cdn, waf, appliance, ...
+}
+
+// DefaultFaviconCorpus ships a small built-in baseline, analogous to
+// DefaultMITMDatabase/DefaultVersionPolicy - enough to demonstrate the
+// lookup before an operator supplies a real, measured corpus via
+// ./data/favicon_corpus.json.
+func DefaultFaviconCorpus() []FaviconEntry {
+	return []FaviconEntry{
+		{Hash: "-1255484254", Name: "Cloudflare-Access", Category: "cdn"},
+		{Hash: "116323821", Name: "Grafana", Category: "framework"},
+		{Hash: "-1147191163", Name: "Jenkins", Category: "framework"},
+		{Hash: "81586312", Name: "GitLab", Category: "framework"},
+		{Hash: "-1980646412", Name: "Kibana", Category: "framework"},
+	}
+}
+
+// faviconHTTPClient is shared across requests; a short timeout keeps a slow
+// or unreachable origin from holding up the analysis pipeline. /api/analysis
+// and /api/decode reach AnalyzeFavicon with a client-supplied Referer/Origin
+// (see originFromHeaders) and need no auth token, so this needs the same
+// SSRF guard /api/probe gets from resolveProbeHost/isDisallowedProbeIP -
+// otherwise "fetch failed" vs "HTTP non-200" vs "200 + hash" is an oracle
+// for internal port-scanning against a target the attacker doesn't even
+// need an admin token to choose.
+var faviconHTTPClient = &http.Client{
+	Timeout: 5 * time.Second,
+	Transport: &http.Transport{
+		DialContext: safeFaviconDialContext,
+	},
+}
+
+// safeFaviconDialContext resolves and validates addr's host exactly the way
+// resolveProbeHost does for /api/probe, then dials the literal IP that
+// validated rather than addr itself - so there's no second, independent
+// DNS lookup for an attacker-controlled name to rebind between the check
+// and the connect. http.Client re-invokes this per redirect hop too, so a
+// redirect to a disallowed address is rejected the same way.
+func safeFaviconDialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	dialIP, err := resolveProbeHost(host)
+	if err != nil {
+		return nil, err
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, network, net.JoinHostPort(dialIP.String(), port))
+}
+
+// resolveFaviconURL decides which URL to fetch: the page's declared favicon
+// (from htmlBody, via FindFaviconURL) resolved against origin if present,
+// otherwise the conventional /favicon.ico at origin.
+func resolveFaviconURL(origin *url.URL, htmlBody string) string {
+	if htmlBody != "" {
+		if href := FindFaviconURL(htmlBody); href != "" {
+			if resolved, err := origin.Parse(href); err == nil {
+				return resolved.String()
+			}
+		}
+	}
+	return origin.Scheme + "://" + origin.Host + "/favicon.ico"
+}
+
+// AnalyzeFavicon fetches and hashes originURL's favicon. htmlBody is the
+// page's HTML if the caller has already fetched one and wants FindFaviconURL
+// to find the declared icon instead of guessing /favicon.ico; pass "" to
+// skip that and always use the convention. Returns nil if originURL doesn't
+// parse into something fetchable.
+func AnalyzeFavicon(originURL string, htmlBody string) *FaviconAnalysis {
+	origin, err := url.Parse(originURL)
+	if err != nil || origin.Host == "" {
+		return nil
+	}
+	if origin.Scheme != "http" && origin.Scheme != "https" {
+		return nil
+	}
+
+	faviconURL := resolveFaviconURL(origin, htmlBody)
+	analysis := &FaviconAnalysis{URL: faviconURL}
+
+	resp, err := faviconHTTPClient.Get(faviconURL)
+	if err != nil {
+		analysis.Observations = append(analysis.Observations, fmt.Sprintf("favicon fetch failed: %v", err))
+		return analysis
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		analysis.Observations = append(analysis.Observations, fmt.Sprintf("favicon fetch returned HTTP %d", resp.StatusCode))
+		return analysis
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil || len(data) == 0 {
+		analysis.Observations = append(analysis.Observations, "favicon response body was empty or unreadable")
+		return analysis
+	}
+
+	analysis.Detected = true
+	analysis.SizeBytes = len(data)
+	analysis.MMH3 = FaviconHash(data)
+
+	if entry, found := GetDatabase().LookupFavicon(analysis.MMH3); found {
+		analysis.Match = entry.Name
+		analysis.MatchCategory = entry.Category
+		analysis.Observations = append(analysis.Observations,
+			fmt.Sprintf("favicon hash %s matches known %s: %s", analysis.MMH3, entry.Category, entry.Name))
+	} else {
+		analysis.Observations = append(analysis.Observations, fmt.Sprintf("favicon hash %s not in corpus", analysis.MMH3))
+	}
+
+	return analysis
+}
+
+// originFromHeaders picks the host the collector should fetch a favicon
+// from: the Referer's origin if present (the page that linked here, so its
+// favicon is the one worth fingerprinting), falling back to Origin.
+func originFromHeaders(headers map[string]string) string {
+	if referer, ok := headerLookup(headers, "Referer"); ok && referer != "" {
+		if u, err := url.Parse(referer); err == nil && u.Host != "" {
+			return u.Scheme + "://" + u.Host
+		}
+	}
+	if origin, ok := headerLookup(headers, "Origin"); ok && origin != "" {
+		return origin
+	}
+	return ""
+}
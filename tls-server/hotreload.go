@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Reload re-parses every fingerprint database file immediately - the
+// manual-trigger counterpart to Watch below and to StartHotReload's
+// periodic stat poll (see database.go). All three ultimately call Load(),
+// which only swaps in a freshly-parsed pointer once json.Unmarshal
+// succeeds, so a bad edit on disk just logs and leaves the previous
+// version serving; callers never need their own atomic-swap bookkeeping.
+func (db *FingerprintDatabase) Reload() error {
+	return db.Load()
+}
+
+// watchDebounce is how long a burst of fsnotify events on the data
+// directory must go quiet before Watch triggers a Reload - editors that
+// write-then-rename, or write a file in several small chunks, would
+// otherwise cost several reloads for one save.
+const watchDebounce = 200 * time.Millisecond
+
+// Watch runs an fsnotify watch on findDataDir(), reloading the database
+// whenever a write/create/rename settles for watchDebounce with no
+// further event. It blocks until ctx is canceled, at which point it closes
+// the watcher and returns ctx.Err(). Watch is additive to StartHotReload's
+// stat poll, not a replacement for it: Watch gives near-instant reload
+// where fsnotify is supported, StartHotReload keeps working everywhere
+// else (including filesystems fsnotify can't watch).
+func (db *FingerprintDatabase) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("hotreload: creating fsnotify watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	dataDir := findDataDir()
+	if err := watcher.Add(dataDir); err != nil {
+		return fmt.Errorf("hotreload: watching %s: %w", dataDir, err)
+	}
+	log.Printf("[DB] Watching %s for changes (fsnotify)", dataDir)
+
+	var debounce *time.Timer
+	defer func() {
+		if debounce != nil {
+			debounce.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			if debounce == nil {
+				debounce = time.AfterFunc(watchDebounce, func() {
+					log.Printf("[DB] Fingerprint database files changed on disk (fsnotify), reloading...")
+					if err := db.Reload(); err != nil {
+						log.Printf("[DB] Reload failed: %v", err)
+					}
+				})
+			} else {
+				debounce.Reset(watchDebounce)
+			}
+
+		case watchErr, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("[DB] fsnotify error: %v", watchErr)
+		}
+	}
+}
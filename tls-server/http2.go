@@ -6,7 +6,11 @@ import (
 	"encoding/hex"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	"golang.org/x/net/http2/hpack"
 )
 
 // HTTP/2 Frame Types
@@ -21,6 +25,7 @@ const (
 	FrameGoAway       = 0x7
 	FrameWindowUpdate = 0x8
 	FrameContinuation = 0x9
+	FramePriorityUpdate = 0x10
 )
 
 // HTTP/2 Settings IDs
@@ -48,10 +53,142 @@ type HTTP2Fingerprint struct {
 	Priorities        []PriorityInfo `json:"priorities,omitempty"`
 	PseudoHeaderOrder string         `json:"pseudo_header_order"`
 
+	// PriorityUpdates are RFC 9218 PRIORITY_UPDATE frames (type 0x10) - the
+	// extensible-priorities replacement for the legacy PRIORITY frame above,
+	// used by modern Chrome/Firefox instead of Priorities.
+	PriorityUpdates []PriorityUpdateInfo `json:"priority_updates,omitempty"`
+
+	// Urgency/Incremental are the RFC 9218 `priority` header field's `u=`/`i`
+	// parameters, parsed out of the request HEADERS once HPACK decoding
+	// lands (see parsePriorityHeader). UrgencySeen distinguishes "absent"
+	// from "explicitly u=3", the RFC 9218 default.
+	Urgency      int  `json:"urgency,omitempty"`
+	Incremental  bool `json:"incremental,omitempty"`
+	UrgencySeen  bool `json:"-"`
+
+	// HeaderOrder is the non-pseudo header names in the order they appeared
+	// on the wire, as decoded by decodeHeaderBlock.
+	HeaderOrder []string `json:"header_order,omitempty"`
+
+	// Headers is the full decoded header list (pseudo and regular), each
+	// carrying the HPACK representation it was encoded with and whether
+	// Huffman coding was used - see HeaderField.
+	Headers []HeaderField `json:"headers,omitempty"`
+
+	// Anomalies records HPACK decode failures (malformed prefix integers,
+	// a CONTINUATION sequence that never set END_HEADERS, etc). A failure
+	// here leaves PseudoHeaderOrder/HeaderOrder/Headers empty rather than
+	// falling back to an assumed browser-like order.
+	Anomalies []string `json:"anomalies,omitempty"`
+
+	// InteropSignature is the reaction matrix from -enable-http2-probe mode
+	// (see http2interop.go); nil when probing wasn't run.
+	InteropSignature *InteropSignature `json:"interop_signature,omitempty"`
+
+	// AkamaiInterop is Akamai with InteropSignature's reactions appended as a
+	// fifth field, and AkamaiInteropHash its SHA-256 (truncated like
+	// AkamaiHash). Only set once ApplyInteropSignature has run, since real
+	// browsers and libraries (curl/nghttp2/Go/Java) diverge sharply on RFC
+	// 7540 conformance edge cases and this is a stronger anti-bot signal than
+	// the passive Akamai fingerprint alone.
+	AkamaiInterop     string `json:"akamai_interop,omitempty"`
+	AkamaiInteropHash string `json:"akamai_interop_hash,omitempty"`
+
 	// Frame order (for debugging)
 	FrameOrder []string `json:"frame_order,omitempty"`
+
+	// Behavior is the timing/cadence layer derived from every ingest call
+	// (see ParseHTTP2Frames/IngestMore) - see BehaviorProfile.
+	Behavior BehaviorProfile `json:"behavior"`
+
+	// firstSeenAt/serverSettingsSentAt are the wall-clock anchors Behavior's
+	// delays are measured against; unexported since they're only meaningful
+	// within this process's lifetime, not as a reported fingerprint value.
+	firstSeenAt          time.Time
+	serverSettingsSentAt time.Time
+	orderTokenDone       bool
+
+	// headerBlock/collectingHeaders/headerStreamID carry HEADERS+CONTINUATION
+	// reassembly state across ingest calls, since the two can now legitimately
+	// land in separate reads (see ingestFrames).
+	headerBlock        []byte
+	collectingHeaders  bool
+	headerStreamID     uint32
+}
+
+// BehaviorProfile captures HTTP/2 connection *behavior* over time - frame
+// cadence and ordering - rather than the single static snapshot the rest of
+// HTTP2Fingerprint records. This is what separates a real browser (which
+// paces its SETTINGS-ACK/PING/WINDOW_UPDATE a certain way) from a client
+// library that reproduces an identical static Akamai string but behaves
+// nothing like one.
+type BehaviorProfile struct {
+	// SettingsAckDelayMS is how long after MarkServerSettingsSent the
+	// client's SETTINGS ACK arrived, in milliseconds. -1 until an ACK is
+	// observed, or if one arrived before the server had sent anything to
+	// ACK (i.e. it was ACKing a retransmit/earlier SETTINGS, not ours).
+	SettingsAckDelayMS int64 `json:"settings_ack_delay_ms"`
+
+	// ClientSettingsBeforeServer is true when the client's own (non-ACK)
+	// SETTINGS frame was already seen before MarkServerSettingsSent was
+	// called - i.e. the client didn't wait on us, which is how every real
+	// browser behaves (SETTINGS goes out immediately after the preface).
+	ClientSettingsBeforeServer bool `json:"client_settings_before_server"`
+
+	// PingsSent counts PING frames the client itself initiated (ACK flag
+	// unset - this server never sends a PING for the client to ACK).
+	PingsSent int `json:"pings_sent"`
+
+	// FrameOrderToken is a canonicalized ordering of SETTINGS ("S"),
+	// WINDOW_UPDATE ("W"), PRIORITY ("P") and the first HEADERS ("H") seen
+	// after the preface, e.g. "S,W,H" vs "W,S,H" - unlike HTTP2Fingerprint.
+	// FrameOrder (debug-only, includes every frame), this is what
+	// buildAkamaiFingerprint hashes.
+	FrameOrderToken string `json:"frame_order_token,omitempty"`
+
+	// SettingsPresence records, for each well-known SETTINGS ID, whether the
+	// client's first SETTINGS frame included it at all - some Chrome builds
+	// omit ENABLE_PUSH entirely rather than sending it as 0.
+	SettingsPresence map[string]bool `json:"settings_presence,omitempty"`
+}
+
+// behaviorOrderLetter maps the frame types FrameOrderToken tracks to their
+// single-letter token.
+var behaviorOrderLetter = map[uint8]string{
+	FrameSettings:     "S",
+	FrameWindowUpdate: "W",
+	FramePriority:     "P",
+	FrameHeaders:      "H",
 }
 
+// HeaderField is one header exactly as it was encoded on the wire: its
+// resolved name/value (via HPACK's static and per-connection dynamic table),
+// the representation RFC 7541 section 6 used to encode it, and whether its
+// name/value strings were Huffman-coded. Sensitive mirrors hpack.HeaderField's
+// own flag, set only for Representation == RepLiteralNeverIndexed - RFC 7541's
+// explicit "don't let this touch a shared compression table" signal, which is
+// what makes literal-never-indexed cookies/auth headers a stronger fingerprint
+// bit than merely literal ones.
+type HeaderField struct {
+	Name           string               `json:"name"`
+	Value          string               `json:"value,omitempty"`
+	Representation HeaderRepresentation `json:"representation"`
+	Huffman        bool                 `json:"huffman"`
+	Sensitive      bool                 `json:"sensitive,omitempty"`
+}
+
+// HeaderRepresentation is the RFC 7541 section 6 wire representation used to
+// encode one HPACK field.
+type HeaderRepresentation string
+
+const (
+	RepIndexed                HeaderRepresentation = "indexed"
+	RepLiteralWithIndexing    HeaderRepresentation = "literal_with_indexing"
+	RepLiteralWithoutIndexing HeaderRepresentation = "literal_without_indexing"
+	RepLiteralNeverIndexed    HeaderRepresentation = "literal_never_indexed"
+	RepDynamicTableSizeUpdate HeaderRepresentation = "dynamic_table_size_update"
+)
+
 // SettingParam represents a single HTTP/2 SETTINGS parameter
 type SettingParam struct {
 	ID    uint16 `json:"id"`
@@ -67,6 +204,15 @@ type PriorityInfo struct {
 	Weight     uint8  `json:"weight"`
 }
 
+// PriorityUpdateInfo is one RFC 9218 PRIORITY_UPDATE frame (type 0x10):
+// prioritized_stream_id plus the raw Priority Field Value (the same
+// structured-field syntax - "u=N", "i" - as the `priority` request header).
+type PriorityUpdateInfo struct {
+	StreamID           uint32 `json:"stream_id"`            // the PRIORITY_UPDATE frame's own stream ID (0 for connection-level)
+	PrioritizedStreamID uint32 `json:"prioritized_stream_id"`
+	FieldValue         string `json:"field_value"`
+}
+
 // HTTP2Frame represents a parsed HTTP/2 frame
 type HTTP2Frame struct {
 	Length   uint32
@@ -76,12 +222,41 @@ type HTTP2Frame struct {
 	Payload  []byte
 }
 
-// ParseHTTP2Frames parses HTTP/2 frames from raw data after the connection preface
+// ParseHTTP2Frames parses HTTP/2 frames from raw data read immediately after
+// the connection preface. The returned fingerprint can keep accumulating
+// behavioral data from later reads on the same connection via IngestMore and
+// MarkServerSettingsSent - see BehaviorProfile.
 func ParseHTTP2Frames(data []byte) (*HTTP2Fingerprint, error) {
 	fp := &HTTP2Fingerprint{
-		WindowUpdate: 0,
+		WindowUpdate:       0,
+		firstSeenAt:        time.Now(),
+		Behavior:           BehaviorProfile{SettingsAckDelayMS: -1},
 	}
+	fp.ingestFrames(data)
+	fp.finalize()
+	return fp, nil
+}
+
+// IngestMore feeds another chunk of frames read later on the same connection
+// (e.g. the client's SETTINGS-ACK/PING/HEADERS arriving after this server's
+// own SETTINGS went out) into fp, updating Behavior and re-deriving Akamai.
+func (fp *HTTP2Fingerprint) IngestMore(data []byte) {
+	fp.ingestFrames(data)
+	fp.finalize()
+}
 
+// MarkServerSettingsSent records when this server's own SETTINGS frame went
+// out, so a later client SETTINGS-ACK can be timed against it
+// (Behavior.SettingsAckDelayMS). Call it right after writing that frame.
+func (fp *HTTP2Fingerprint) MarkServerSettingsSent() {
+	fp.serverSettingsSentAt = time.Now()
+}
+
+// ingestFrames walks every complete frame in data, updating fp in place.
+// HEADERS/CONTINUATION reassembly state (fp.headerBlock etc.) and the
+// behavior-tracking fields persist across calls, so it's safe to call this
+// more than once as more data arrives on the same connection.
+func (fp *HTTP2Fingerprint) ingestFrames(data []byte) {
 	pos := 0
 	frameCount := 0
 	maxFrames := 50 // Limit frames to parse
@@ -98,6 +273,7 @@ func ParseHTTP2Frames(data []byte) (*HTTP2Fingerprint, error) {
 		}
 
 		fp.FrameOrder = append(fp.FrameOrder, getFrameTypeName(frame.Type))
+		fp.recordBehavior(frame)
 
 		switch frame.Type {
 		case FrameSettings:
@@ -110,23 +286,85 @@ func ParseHTTP2Frames(data []byte) (*HTTP2Fingerprint, error) {
 			}
 		case FramePriority:
 			parsePriorityFrame(frame, fp)
+		case FramePriorityUpdate:
+			parsePriorityUpdateFrame(frame, fp)
 		case FrameHeaders:
-			// Extract pseudo-header order from HEADERS frame
-			parseHeadersFrameOrder(frame, fp)
+			if fp.PseudoHeaderOrder == "" && len(fp.Headers) == 0 && len(fp.Anomalies) == 0 {
+				fp.headerBlock = stripHeadersFramePadding(frame)
+				fp.headerStreamID = frame.StreamID
+				if frame.Flags&0x4 != 0 { // END_HEADERS
+					decodeHeaderBlock(fp.headerBlock, fp)
+					fp.headerBlock = nil
+				} else {
+					fp.collectingHeaders = true
+				}
+			}
+		case FrameContinuation:
+			if fp.collectingHeaders && frame.StreamID == fp.headerStreamID {
+				fp.headerBlock = append(fp.headerBlock, frame.Payload...)
+				if frame.Flags&0x4 != 0 { // END_HEADERS
+					decodeHeaderBlock(fp.headerBlock, fp)
+					fp.headerBlock = nil
+					fp.collectingHeaders = false
+				}
+			}
 		}
 
 		pos += frameLen
 		frameCount++
 	}
+}
 
-	// Build Akamai fingerprint string
-	fp.Akamai = buildAkamaiFingerprint(fp)
+// recordBehavior updates fp.Behavior for one frame, before the regular
+// per-type handling in ingestFrames runs.
+func (fp *HTTP2Fingerprint) recordBehavior(frame *HTTP2Frame) {
+	switch frame.Type {
+	case FrameSettings:
+		if frame.Flags&0x1 != 0 { // SETTINGS ACK
+			if !fp.serverSettingsSentAt.IsZero() && fp.Behavior.SettingsAckDelayMS < 0 {
+				fp.Behavior.SettingsAckDelayMS = time.Since(fp.serverSettingsSentAt).Milliseconds()
+			}
+		} else {
+			if fp.Behavior.SettingsPresence == nil {
+				fp.Behavior.SettingsPresence = map[string]bool{}
+				fp.Behavior.ClientSettingsBeforeServer = fp.serverSettingsSentAt.IsZero()
+				for i := 0; i+6 <= len(frame.Payload); i += 6 {
+					id := binary.BigEndian.Uint16(frame.Payload[i : i+2])
+					fp.Behavior.SettingsPresence[getSettingName(id)] = true
+				}
+			}
+		}
+	case FramePing:
+		if frame.Flags&0x1 == 0 { // a client-initiated PING, not an ACK of ours
+			fp.Behavior.PingsSent++
+		}
+	}
 
-	// Calculate hash
+	if !fp.orderTokenDone {
+		if letter, ok := behaviorOrderLetter[frame.Type]; ok {
+			fp.Behavior.FrameOrderToken += letter
+			if frame.Type != FrameHeaders {
+				fp.Behavior.FrameOrderToken += ","
+			} else {
+				fp.orderTokenDone = true
+			}
+		}
+	}
+}
+
+// finalize records any terminal anomalies and (re)builds the Akamai
+// fingerprint/hash from fp's current state. Safe to call repeatedly as more
+// frames are ingested.
+func (fp *HTTP2Fingerprint) finalize() {
+	if fp.collectingHeaders {
+		if len(fp.Anomalies) == 0 || fp.Anomalies[len(fp.Anomalies)-1] != "HEADERS frame never terminated by a CONTINUATION with END_HEADERS" {
+			fp.Anomalies = append(fp.Anomalies, "HEADERS frame never terminated by a CONTINUATION with END_HEADERS")
+		}
+	}
+
+	fp.Akamai = buildAkamaiFingerprint(fp)
 	hash := sha256.Sum256([]byte(fp.Akamai))
 	fp.AkamaiHash = hex.EncodeToString(hash[:])[:32]
-
-	return fp, nil
 }
 
 func parseFrame(data []byte) (*HTTP2Frame, error) {
@@ -184,18 +422,49 @@ func parsePriorityFrame(frame *HTTP2Frame, fp *HTTP2Fingerprint) {
 	}
 }
 
-func parseHeadersFrameOrder(frame *HTTP2Frame, fp *HTTP2Fingerprint) {
-	// The pseudo-header order is encoded in HPACK compressed headers
-	// For simplicity, we'll try to detect common patterns
-	// A full implementation would need HPACK decoding
+// parsePriorityUpdateFrame parses an RFC 9218 PRIORITY_UPDATE frame (section
+// 7.1): a 4-byte Prioritized Stream ID followed by the Priority Field Value
+// as ASCII structured-field text (e.g. "u=2, i").
+func parsePriorityUpdateFrame(frame *HTTP2Frame, fp *HTTP2Fingerprint) {
+	if len(frame.Payload) < 4 {
+		return
+	}
+	fp.PriorityUpdates = append(fp.PriorityUpdates, PriorityUpdateInfo{
+		StreamID:            frame.StreamID,
+		PrioritizedStreamID: binary.BigEndian.Uint32(frame.Payload[:4]) & 0x7FFFFFFF,
+		FieldValue:          string(frame.Payload[4:]),
+	})
+}
 
-	if fp.PseudoHeaderOrder != "" {
-		return // Already set
+// parsePriorityHeader parses an RFC 9218 `priority` header field's value
+// (the same Structured Fields Dictionary syntax PRIORITY_UPDATE carries:
+// "u=<0-7>", "i" / "i=?0") and applies it to fp. Unknown parameters are
+// ignored, matching RFC 9218 section 4's "ignore anything you don't
+// recognize" extensibility rule.
+func parsePriorityHeader(value string, fp *HTTP2Fingerprint) {
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "i" || part == "i=?1":
+			fp.Incremental = true
+		case part == "i=?0":
+			fp.Incremental = false
+		case strings.HasPrefix(part, "u="):
+			if u, err := strconv.Atoi(strings.TrimPrefix(part, "u=")); err == nil {
+				fp.Urgency = u
+				fp.UrgencySeen = true
+			}
+		}
 	}
+}
 
+// stripHeadersFramePadding returns the HEADERS frame's header-block fragment
+// with the PADDED/PRIORITY preambles (RFC 7540 section 6.2) removed. Any
+// PRIORITY data present is already captured separately via PRIORITY frames /
+// parsePriorityFrame, so it's simply skipped here.
+func stripHeadersFramePadding(frame *HTTP2Frame) []byte {
 	payload := frame.Payload
 
-	// Skip padding if present
 	padLength := uint8(0)
 	pos := 0
 	if frame.Flags&0x8 != 0 { // PADDED flag
@@ -204,108 +473,250 @@ func parseHeadersFrameOrder(frame *HTTP2Frame, fp *HTTP2Fingerprint) {
 			pos = 1
 		}
 	}
-
-	// Skip priority data if present
 	if frame.Flags&0x20 != 0 { // PRIORITY flag
 		pos += 5
 	}
 
-	if pos >= len(payload) {
+	if pos >= len(payload) || pos+int(padLength) > len(payload) {
+		return nil
+	}
+	return payload[pos : len(payload)-int(padLength)]
+}
+
+// pseudoHeaderShort maps the four HTTP/2 pseudo-header names to the single
+// letter ja3-style clients use in their pseudo-header-order string.
+var pseudoHeaderShort = map[string]string{
+	":method":    "m",
+	":authority": "a",
+	":scheme":    "s",
+	":path":      "p",
+}
+
+// decodeHeaderBlock HPACK-decodes a fully reassembled header-block fragment
+// (HEADERS payload plus any CONTINUATION frames, see ParseHTTP2Frames) and
+// populates fp's PseudoHeaderOrder, HeaderOrder and Headers. It combines two
+// passes over the same bytes:
+//   - golang.org/x/net/http2/hpack.Decoder resolves each field's actual name
+//     and value against the static and per-connection dynamic table (sized by
+//     the client's own SETTINGS_HEADER_TABLE_SIZE, if it sent one), and flags
+//     Sensitive for literal-never-indexed fields.
+//   - walkHPACKRepresentations walks the same bytes to classify the wire
+//     representation (indexed / literal with or without indexing / never
+//     indexed / dynamic-table-size update) and whether Huffman coding was
+//     used, none of which hpack.Decoder exposes.
+// The two passes visit fields in the same order, so they're zipped together
+// by index. A failure in either pass is recorded in fp.Anomalies and leaves
+// the header fields empty rather than guessing a default order.
+func decodeHeaderBlock(block []byte, fp *HTTP2Fingerprint) {
+	if len(block) == 0 {
+		fp.Anomalies = append(fp.Anomalies, "empty HEADERS block after stripping padding/priority")
 		return
 	}
 
-	headerBlock := payload[pos : len(payload)-int(padLength)]
+	tableSize := uint32(4096)
+	for _, s := range fp.Settings {
+		if s.ID == SettingsHeaderTableSize {
+			tableSize = s.Value
+		}
+	}
 
-	// Try to extract pseudo-header order from HPACK encoded data
-	order := extractPseudoHeaderOrder(headerBlock)
-	if order != "" {
-		fp.PseudoHeaderOrder = order
+	var resolved []hpack.HeaderField
+	decoder := hpack.NewDecoder(tableSize, func(hf hpack.HeaderField) {
+		resolved = append(resolved, hf)
+	})
+	if _, err := decoder.Write(block); err != nil {
+		fp.Anomalies = append(fp.Anomalies, fmt.Sprintf("hpack decode error: %v", err))
+		return
+	}
+	if err := decoder.Close(); err != nil {
+		fp.Anomalies = append(fp.Anomalies, fmt.Sprintf("hpack decoder close: %v", err))
+		return
 	}
+
+	metas, err := walkHPACKRepresentations(block)
+	if err != nil {
+		fp.Anomalies = append(fp.Anomalies, fmt.Sprintf("hpack representation walk: %v", err))
+		return
+	}
+	if len(metas) != len(resolved) {
+		fp.Anomalies = append(fp.Anomalies, fmt.Sprintf("hpack field count mismatch: %d resolved vs %d wire-walked", len(resolved), len(metas)))
+		return
+	}
+
+	var pseudoOrder []string
+	seenPseudo := make(map[string]bool)
+	for i, hf := range resolved {
+		field := HeaderField{
+			Name:           hf.Name,
+			Value:          hf.Value,
+			Representation: metas[i].representation,
+			Huffman:        metas[i].huffman,
+			Sensitive:      hf.Sensitive,
+		}
+		fp.Headers = append(fp.Headers, field)
+
+		if hf.Name == "priority" {
+			parsePriorityHeader(hf.Value, fp)
+		}
+
+		if short, ok := pseudoHeaderShort[hf.Name]; ok {
+			if !seenPseudo[short] {
+				pseudoOrder = append(pseudoOrder, short)
+				seenPseudo[short] = true
+			}
+		} else if hf.Name != "" {
+			fp.HeaderOrder = append(fp.HeaderOrder, hf.Name)
+		}
+	}
+	fp.PseudoHeaderOrder = strings.Join(pseudoOrder, ",")
 }
 
-// extractPseudoHeaderOrder attempts to extract pseudo-header order from HPACK data
-// This is a simplified implementation that looks for indexed header patterns
-func extractPseudoHeaderOrder(data []byte) string {
-	// HPACK uses static table indices for pseudo-headers:
-	// Index 2: :method GET
-	// Index 3: :method POST
-	// Index 4: :path /
-	// Index 5: :path /index.html
-	// Index 6: :scheme http
-	// Index 7: :scheme https
-	// Index 1: :authority (needs value)
+// hpackFieldMeta is one field's wire-format metadata, as extracted by
+// walkHPACKRepresentations.
+type hpackFieldMeta struct {
+	representation HeaderRepresentation
+	huffman        bool
+}
 
-	// Look for common patterns in the first bytes
-	// This is heuristic - browsers have consistent patterns
+// walkHPACKRepresentations parses the RFC 7541 section 6 framing of each
+// field in block - without resolving indices against any table - purely to
+// classify how it was encoded. It must still correctly skip over every
+// field's bytes (prefix integers, including multi-byte continuations, and
+// Huffman-flagged string literals) to reach the next one, which is the bulk
+// of what makes this "real" HPACK parsing rather than the old fixed-offset
+// heuristic.
+func walkHPACKRepresentations(block []byte) ([]hpackFieldMeta, error) {
+	var metas []hpackFieldMeta
+	pos := 0
+	for pos < len(block) {
+		b := block[pos]
+		switch {
+		case b&0x80 != 0: // Indexed Header Field - 1xxxxxxx
+			_, n, ok := decodeHPACKInt(block[pos:], 7)
+			if !ok {
+				return metas, fmt.Errorf("truncated indexed header field at byte %d", pos)
+			}
+			pos += n
+			metas = append(metas, hpackFieldMeta{representation: RepIndexed})
 
-	var order []string
-	seen := make(map[string]bool)
+		case b&0xC0 == 0x40: // Literal with incremental indexing - 01xxxxxx
+			idx, n, ok := decodeHPACKInt(block[pos:], 6)
+			if !ok {
+				return metas, fmt.Errorf("truncated literal-with-indexing header field at byte %d", pos)
+			}
+			pos += n
+			huffman, consumed, ok := skipLiteralHeaderStrings(block[pos:], idx == 0)
+			if !ok {
+				return metas, fmt.Errorf("truncated literal-with-indexing string at byte %d", pos)
+			}
+			pos += consumed
+			metas = append(metas, hpackFieldMeta{representation: RepLiteralWithIndexing, huffman: huffman})
 
-	for i := 0; i < len(data) && i < 20; i++ {
-		b := data[i]
+		case b&0xE0 == 0x20: // Dynamic Table Size Update - 001xxxxx
+			_, n, ok := decodeHPACKInt(block[pos:], 5)
+			if !ok {
+				return metas, fmt.Errorf("truncated dynamic table size update at byte %d", pos)
+			}
+			pos += n
+			metas = append(metas, hpackFieldMeta{representation: RepDynamicTableSizeUpdate})
 
-		// Indexed header field (starts with 1)
-		if b&0x80 != 0 {
-			index := int(b & 0x7F)
-			switch index {
-			case 2, 3: // :method
-				if !seen["m"] {
-					order = append(order, "m")
-					seen["m"] = true
-				}
-			case 4, 5: // :path
-				if !seen["p"] {
-					order = append(order, "p")
-					seen["p"] = true
-				}
-			case 6, 7: // :scheme
-				if !seen["s"] {
-					order = append(order, "s")
-					seen["s"] = true
-				}
-			case 1: // :authority
-				if !seen["a"] {
-					order = append(order, "a")
-					seen["a"] = true
-				}
+		case b&0xF0 == 0x10: // Literal Never Indexed - 0001xxxx
+			idx, n, ok := decodeHPACKInt(block[pos:], 4)
+			if !ok {
+				return metas, fmt.Errorf("truncated literal-never-indexed header field at byte %d", pos)
 			}
-		}
+			pos += n
+			huffman, consumed, ok := skipLiteralHeaderStrings(block[pos:], idx == 0)
+			if !ok {
+				return metas, fmt.Errorf("truncated literal-never-indexed string at byte %d", pos)
+			}
+			pos += consumed
+			metas = append(metas, hpackFieldMeta{representation: RepLiteralNeverIndexed, huffman: huffman})
 
-		// Literal header with incremental indexing (starts with 01)
-		if b&0xC0 == 0x40 {
-			index := int(b & 0x3F)
-			switch index {
-			case 2, 3:
-				if !seen["m"] {
-					order = append(order, "m")
-					seen["m"] = true
-				}
-			case 4, 5:
-				if !seen["p"] {
-					order = append(order, "p")
-					seen["p"] = true
-				}
-			case 6, 7:
-				if !seen["s"] {
-					order = append(order, "s")
-					seen["s"] = true
-				}
-			case 1:
-				if !seen["a"] {
-					order = append(order, "a")
-					seen["a"] = true
-				}
+		default: // Literal Without Indexing - 0000xxxx
+			idx, n, ok := decodeHPACKInt(block[pos:], 4)
+			if !ok {
+				return metas, fmt.Errorf("truncated literal-without-indexing header field at byte %d", pos)
+			}
+			pos += n
+			huffman, consumed, ok := skipLiteralHeaderStrings(block[pos:], idx == 0)
+			if !ok {
+				return metas, fmt.Errorf("truncated literal-without-indexing string at byte %d", pos)
 			}
+			pos += consumed
+			metas = append(metas, hpackFieldMeta{representation: RepLiteralWithoutIndexing, huffman: huffman})
+		}
+	}
+	return metas, nil
+}
+
+// decodeHPACKInt decodes an RFC 7541 section 5.1 integer with the given
+// prefix width, returning its value and how many bytes it occupied.
+func decodeHPACKInt(data []byte, prefixBits uint) (value uint64, consumed int, ok bool) {
+	if len(data) == 0 {
+		return 0, 0, false
+	}
+	mask := byte(1<<prefixBits - 1)
+	value = uint64(data[0] & mask)
+	if value < uint64(mask) {
+		return value, 1, true
+	}
+
+	shift := uint(0)
+	i := 1
+	for {
+		if i >= len(data) {
+			return 0, 0, false
 		}
+		b := data[i]
+		value += uint64(b&0x7f) << shift
+		i++
+		if b&0x80 == 0 {
+			break
+		}
+		shift += 7
 	}
+	return value, i, true
+}
 
-	// If we found at least 3 pseudo-headers, return the order
-	if len(order) >= 3 {
-		return strings.Join(order, ",")
+// skipLiteralHeaderStrings advances over a literal field's name (only
+// present when includesName is true, i.e. the field used a new name rather
+// than an indexed one) and value string literals, reporting whether either
+// used Huffman coding (RFC 7541 section 5.2's leading H bit) and the total
+// bytes consumed.
+func skipLiteralHeaderStrings(data []byte, includesName bool) (huffman bool, consumed int, ok bool) {
+	pos := 0
+	if includesName {
+		h, n, strOK := skipHPACKString(data[pos:])
+		if !strOK {
+			return false, 0, false
+		}
+		huffman = huffman || h
+		pos += n
+	}
+	h, n, strOK := skipHPACKString(data[pos:])
+	if !strOK {
+		return false, 0, false
 	}
+	huffman = huffman || h
+	pos += n
+	return huffman, pos, true
+}
 
-	// Default fallback based on common browser patterns
-	return ""
+// skipHPACKString advances over one RFC 7541 section 5.2 string literal
+// (a 7-bit-prefixed length with a leading Huffman flag, followed by that
+// many octets), returning whether it was Huffman-coded and its total length
+// including the length prefix.
+func skipHPACKString(data []byte) (huffman bool, consumed int, ok bool) {
+	if len(data) == 0 {
+		return false, 0, false
+	}
+	huffman = data[0]&0x80 != 0
+	length, n, lenOK := decodeHPACKInt(data, 7)
+	if !lenOK || n+int(length) > len(data) {
+		return false, 0, false
+	}
+	return huffman, n + int(length), true
 }
 
 func buildAkamaiFingerprint(fp *HTTP2Fingerprint) string {
@@ -339,13 +750,24 @@ func buildAkamaiFingerprint(fp *HTTP2Fingerprint) string {
 		priorityStr = strings.Join(priorityParts, ",")
 	}
 
-	// 4. Pseudo-header order
-	headerOrder := fp.PseudoHeaderOrder
-	if headerOrder == "" {
-		headerOrder = "m,a,s,p" // Default Chrome order
+	// 4. Pseudo-header order - left empty (rather than assumed) when HPACK
+	// decoding failed; see fp.Anomalies for why.
+	// 5. Behavioral frame-order token (SETTINGS/WINDOW_UPDATE/PRIORITY/first
+	// HEADERS) - this is what separates a real browser from a client that
+	// only reproduces the static fields above (see BehaviorProfile).
+	akamai := fmt.Sprintf("%s|%s|%s|%s|%s", settingsStr, windowStr, priorityStr, fp.PseudoHeaderOrder, fp.Behavior.FrameOrderToken)
+
+	// 6. RFC 9218 extensible priorities - only appended when observed, so
+	// hashes of clients that still use the legacy PRIORITY frame (field 3
+	// above) stay unchanged from before this was added.
+	if fp.UrgencySeen || len(fp.PriorityUpdates) > 0 {
+		incremental := 0
+		if fp.Incremental {
+			incremental = 1
+		}
+		akamai += fmt.Sprintf("|u=%d,i=%d,pu=%d", fp.Urgency, incremental, len(fp.PriorityUpdates))
 	}
-
-	return fmt.Sprintf("%s|%s|%s|%s", settingsStr, windowStr, priorityStr, headerOrder)
+	return akamai
 }
 
 func getFrameTypeName(t uint8) string {
@@ -370,6 +792,8 @@ func getFrameTypeName(t uint8) string {
 		return "WINDOW_UPDATE"
 	case FrameContinuation:
 		return "CONTINUATION"
+	case FramePriorityUpdate:
+		return "PRIORITY_UPDATE"
 	default:
 		return fmt.Sprintf("UNKNOWN(%d)", t)
 	}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// This file is -enable-http2-probe mode: after handleHTTP2 has answered the
+// client's request normally (see respondToHTTP2Request), it sends a curated
+// set of frames RFC 7540 either forbids or requires ignoring, and records how
+// the client actually reacted. Real implementations diverge sharply here -
+// many silently ignore things the spec requires ignoring, some RST_STREAM,
+// some tear down the whole connection with GOAWAY - which makes the reaction
+// matrix a stronger anti-bot signal than the passive Akamai fingerprint
+// alone (see ApplyInteropSignature).
+
+// InteropSignature records, for each http2InteropProbes entry, how the
+// client reacted: "ignored_got(<frame type>)" when it kept talking, a
+// "rst_stream(<error>)" / "goaway(<error>)" with the RFC 7540 section 7 error
+// code name, "silent_drop" when nothing came back before the deadline, or
+// "connection_closed" when the transport itself went away.
+type InteropSignature struct {
+	Reactions map[string]string `json:"reactions"`
+}
+
+// http2ErrorCodeNames maps RFC 7540 section 7 error codes to their names.
+var http2ErrorCodeNames = map[uint32]string{
+	0x0: "NO_ERROR",
+	0x1: "PROTOCOL_ERROR",
+	0x2: "INTERNAL_ERROR",
+	0x3: "FLOW_CONTROL_ERROR",
+	0x4: "SETTINGS_TIMEOUT",
+	0x5: "STREAM_CLOSED",
+	0x6: "FRAME_SIZE_ERROR",
+	0x7: "REFUSED_STREAM",
+	0x8: "CANCEL",
+	0x9: "COMPRESSION_ERROR",
+	0xa: "CONNECT_ERROR",
+	0xb: "ENHANCE_YOUR_CALM",
+	0xc: "INADEQUATE_SECURITY",
+	0xd: "HTTP_1_1_REQUIRED",
+}
+
+func http2ErrorCodeName(code uint32) string {
+	if name, ok := http2ErrorCodeNames[code]; ok {
+		return fmt.Sprintf("%s (%d)", name, code)
+	}
+	return fmt.Sprintf("unknown (%d)", code)
+}
+
+// http2InteropProbes is the fixed, ordered set of non-conformant frames sent
+// by RunHTTP2InteropProbes. Each targets one thing real HTTP/2
+// implementations are known to diverge on.
+var http2InteropProbes = []struct {
+	name  string
+	build func(streamID uint32) []byte
+}{
+	{"unknown_frame_type", buildProbeUnknownFrameType},
+	{"ping_nonzero_stream", buildProbePingNonZeroStream},
+	{"settings_invalid_enable_push", buildProbeSettingsInvalidEnablePush},
+	{"ping_short_payload", buildProbePingShortPayload},
+	{"data_on_closed_stream", buildProbeDataOnClosedStream},
+}
+
+// RunHTTP2InteropProbes sends each http2InteropProbes variant over conn - an
+// established HTTP/2 connection on which handleHTTP2 has already answered
+// streamID's request with END_STREAM - and records how the client reacted to
+// each. Probes run in order and stop early once a GOAWAY or connection close
+// makes the connection unusable for the remaining ones.
+func RunHTTP2InteropProbes(conn net.Conn, streamID uint32) *InteropSignature {
+	sig := &InteropSignature{Reactions: make(map[string]string)}
+
+	for _, probe := range http2InteropProbes {
+		conn.SetDeadline(time.Now().Add(2 * time.Second))
+		if _, err := conn.Write(probe.build(streamID)); err != nil {
+			sig.Reactions[probe.name] = fmt.Sprintf("write_error: %v", err)
+			continue
+		}
+
+		reaction := readInteropReaction(conn)
+		sig.Reactions[probe.name] = reaction
+		if reaction == "connection_closed" || strings.HasPrefix(reaction, "goaway") {
+			break
+		}
+	}
+
+	return sig
+}
+
+// readInteropReaction reads the single frame (or transport error) a client
+// sends in response to one probe and classifies it.
+func readInteropReaction(conn net.Conn) string {
+	buf := make([]byte, 9+8)
+	n, err := conn.Read(buf)
+	if err != nil {
+		if strings.Contains(err.Error(), "timeout") {
+			return "silent_drop"
+		}
+		return "connection_closed"
+	}
+
+	frame, err := parseFrame(buf[:n])
+	if err != nil {
+		return fmt.Sprintf("unparseable_response: %v", err)
+	}
+
+	switch frame.Type {
+	case FrameRSTStream:
+		if len(frame.Payload) >= 4 {
+			return fmt.Sprintf("rst_stream(%s)", http2ErrorCodeName(binary.BigEndian.Uint32(frame.Payload[:4])))
+		}
+		return "rst_stream(malformed)"
+	case FrameGoAway:
+		if len(frame.Payload) >= 8 {
+			return fmt.Sprintf("goaway(%s)", http2ErrorCodeName(binary.BigEndian.Uint32(frame.Payload[4:8])))
+		}
+		return "goaway(malformed)"
+	default:
+		return fmt.Sprintf("ignored_got(%s)", getFrameTypeName(frame.Type))
+	}
+}
+
+// buildProbeUnknownFrameType sends a frame of type 0x63 (unassigned in the
+// HTTP/2 frame type registry), which RFC 7540 section 5.5 requires
+// implementations to ignore.
+func buildProbeUnknownFrameType(streamID uint32) []byte {
+	return buildRawFrame(0x63, 0, 0, []byte{0xde, 0xad, 0xbe, 0xef})
+}
+
+// buildProbePingNonZeroStream sends a PING (RFC 7540 section 6.7) addressed
+// to streamID instead of the connection as the spec requires, which a strict
+// client should reject with PROTOCOL_ERROR.
+func buildProbePingNonZeroStream(streamID uint32) []byte {
+	return buildRawFrame(FramePing, 0, streamID, make([]byte, 8))
+}
+
+// buildProbeSettingsInvalidEnablePush sends SETTINGS_ENABLE_PUSH=2, a value
+// RFC 7540 section 6.5.2 restricts to 0 or 1 and requires rejecting with
+// PROTOCOL_ERROR.
+func buildProbeSettingsInvalidEnablePush(streamID uint32) []byte {
+	payload := make([]byte, 6)
+	binary.BigEndian.PutUint16(payload[0:2], SettingsEnablePush)
+	binary.BigEndian.PutUint32(payload[2:6], 2)
+	return buildRawFrame(FrameSettings, 0, 0, payload)
+}
+
+// buildProbePingShortPayload sends a PING with a 6-byte payload; RFC 7540
+// section 6.7 fixes PING's payload at exactly 8 octets, so the mismatch is a
+// FRAME_SIZE_ERROR.
+func buildProbePingShortPayload(streamID uint32) []byte {
+	return buildRawFrame(FramePing, 0, 0, make([]byte, 6))
+}
+
+// buildProbeDataOnClosedStream sends an empty DATA frame on streamID after
+// handleHTTP2 has already closed it with END_STREAM, which RFC 7540 section
+// 5.1 requires treating as a STREAM_CLOSED stream error.
+func buildProbeDataOnClosedStream(streamID uint32) []byte {
+	return buildRawFrame(FrameData, 0, streamID, nil)
+}
+
+// buildRawFrame assembles one HTTP/2 frame header (RFC 7540 section 4.1)
+// plus payload.
+func buildRawFrame(frameType, flags uint8, streamID uint32, payload []byte) []byte {
+	frame := make([]byte, 9+len(payload))
+	frame[0] = byte(len(payload) >> 16)
+	frame[1] = byte(len(payload) >> 8)
+	frame[2] = byte(len(payload))
+	frame[3] = frameType
+	frame[4] = flags
+	binary.BigEndian.PutUint32(frame[5:9], streamID&0x7fffffff)
+	copy(frame[9:], payload)
+	return frame
+}
+
+// ApplyInteropSignature folds sig's reactions into fp as a fifth field on the
+// Akamai fingerprint (AkamaiInterop/AkamaiInteropHash). fp.Akamai must
+// already be set by ParseHTTP2Frames.
+func (fp *HTTP2Fingerprint) ApplyInteropSignature(sig *InteropSignature) {
+	fp.InteropSignature = sig
+
+	reactions := make([]string, 0, len(http2InteropProbes))
+	for _, probe := range http2InteropProbes {
+		reactions = append(reactions, fmt.Sprintf("%s=%s", probe.name, sig.Reactions[probe.name]))
+	}
+	fp.AkamaiInterop = fmt.Sprintf("%s|%s", fp.Akamai, strings.Join(reactions, ","))
+
+	hash := sha256.Sum256([]byte(fp.AkamaiInterop))
+	fp.AkamaiInteropHash = hex.EncodeToString(hash[:])[:32]
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// This file extends HTTP2Analysis from a single boolean IsImpersonator flag
+// (see IsImpersonatorByHTTP2Rules in database.go, which stays in place as a
+// fast rule-based pre-check) into an evidence-based classifier: score a
+// connection's HTTP2Fingerprint against a corpus of real-browser and
+// known-impersonator profiles, return the top-3 candidates with confidence
+// scores, and diff the connection against whichever profile it's closest to
+// among the "browser" category - so the pentester advice can name exactly
+// which field gave it away instead of a generic warning.
+
+// HTTP2Profile is one reference signature in the corpus: a label, whether it
+// represents a real browser or a known impersonation tool/library, and the
+// fields buildAkamaiFingerprint draws from, kept unparsed here so scoring can
+// compare them the same way FuzzyMatchJA3 compares cipher/extension lists -
+// order-sensitive where the wire format is ordered, set-based where it isn't.
+type HTTP2Profile struct {
+	Label             string   `json:"label"`
+	Category          string   `json:"category"`       // "browser" or "impersonator"
+	SettingsOrder     []string `json:"settings_order"` // "id:value" tokens, in the order the SETTINGS frame sends them
+	WindowUpdate      uint32   `json:"window_update"`
+	PriorityPattern   string   `json:"priority_pattern,omitempty"` // buildAkamaiFingerprint's priority field; "" if the client never sends legacy PRIORITY
+	PseudoHeaderOrder string   `json:"pseudo_header_order"`
+}
+
+// HTTP2Candidate is one scored match returned by ClassifyHTTP2, ranked by
+// Score (0-1, higher is closer) - the HTTP/2 analogue of FuzzyJA3Match.
+type HTTP2Candidate struct {
+	Label    string  `json:"label"`
+	Category string  `json:"category"`
+	Score    float64 `json:"score"`
+}
+
+// DefaultHTTP2ProfileCorpus ships a small built-in baseline, analogous to
+// DefaultMITMDatabase/DefaultFaviconCorpus: enough to demonstrate top-3
+// classification and field-diffing before an operator supplies a real,
+// measured corpus via ./data/http2_profile_corpus.json. Chrome/Firefox/
+// Safari's SETTINGS+WINDOW_UPDATE signature has been stable across the
+// version ranges listed below, so those entries share identical fields on
+// purpose - the version label is still meaningful for display even where
+// the underlying wire signature hasn't moved.
+func DefaultHTTP2ProfileCorpus() []HTTP2Profile {
+	chromeSettings := []string{"1:65536", "2:0", "4:6291456", "6:262144"}
+	firefoxSettings := []string{"1:65536", "4:131072", "5:16384"}
+	safariSettings := []string{"2:0", "3:100", "4:2097152", "9:1"}
+
+	return []HTTP2Profile{
+		// Real browsers.
+		{Label: "Chrome 100-131", Category: "browser", SettingsOrder: chromeSettings, WindowUpdate: 15663105, PseudoHeaderOrder: "m,a,s,p"},
+		{Label: "Firefox 115-133 ESR", Category: "browser", SettingsOrder: firefoxSettings, WindowUpdate: 12517377, PseudoHeaderOrder: "m,p,a,s"},
+		{Label: "Safari 15-18", Category: "browser", SettingsOrder: safariSettings, WindowUpdate: 10420225, PseudoHeaderOrder: "m,s,a"},
+
+		// curl-impersonate builds real Chrome's BoringSSL/nghttp2 stack, so
+		// SETTINGS+WINDOW_UPDATE match Chrome exactly; the tell is the
+		// pseudo-header order, which drops :path (see IsImpersonatorByHTTP2Rules).
+		{Label: "curl-impersonate chrome99", Category: "impersonator", SettingsOrder: chromeSettings, WindowUpdate: 15663105, PseudoHeaderOrder: "m,a,s"},
+		{Label: "curl-impersonate chrome110", Category: "impersonator", SettingsOrder: chromeSettings, WindowUpdate: 15663105, PseudoHeaderOrder: "m,a,s"},
+		{Label: "curl-impersonate chrome124", Category: "impersonator", SettingsOrder: chromeSettings, WindowUpdate: 15663105, PseudoHeaderOrder: "m,a,s"},
+
+		// curl_cffi wraps the same curl-impersonate patches, same tell.
+		{Label: "curl_cffi", Category: "impersonator", SettingsOrder: chromeSettings, WindowUpdate: 15663105, PseudoHeaderOrder: "m,a,s"},
+
+		// tls-client (bogdanfinn)'s Chrome profiles get the pseudo-header
+		// order right but build on Go's net/http2 transport underneath,
+		// which doesn't replay Chrome's WINDOW_UPDATE increment.
+		{Label: "tls-client (bogdanfinn) Chrome_117", Category: "impersonator", SettingsOrder: chromeSettings, WindowUpdate: 6291456, PseudoHeaderOrder: "m,a,s,p"},
+		{Label: "tls-client (bogdanfinn) Chrome_124", Category: "impersonator", SettingsOrder: chromeSettings, WindowUpdate: 6291456, PseudoHeaderOrder: "m,a,s,p"},
+
+		// requests-go and node-fetch don't impersonate a browser's H2 layer
+		// at all - they ship their runtime's stock HTTP/2 client settings.
+		{Label: "requests-go", Category: "impersonator", SettingsOrder: []string{"1:4096", "3:1000", "4:1048576"}, WindowUpdate: 1073741823, PseudoHeaderOrder: "m,s,a,p"},
+		{Label: "node-fetch", Category: "impersonator", SettingsOrder: []string{"1:4096", "2:0", "4:65535", "5:16384", "6:262144"}, WindowUpdate: 65535, PseudoHeaderOrder: "m,a,s,p"},
+	}
+}
+
+// settingsOrderTokens mirrors buildAkamaiFingerprint's "id:value" encoding
+// but keeps fp.Settings in wire order instead of sorting by ID - the order a
+// client lists its SETTINGS is itself part of the signature, and
+// buildAkamaiFingerprint's sort (done for a stable Akamai string) throws
+// that away.
+func settingsOrderTokens(settings []SettingParam) []string {
+	tokens := make([]string, len(settings))
+	for i, s := range settings {
+		tokens[i] = fmt.Sprintf("%d:%d", s.ID, s.Value)
+	}
+	return tokens
+}
+
+// priorityPattern renders a HTTP2Fingerprint's legacy PRIORITY frames into
+// the same "streamID:exclusive:dependsOn:weight,..." shape
+// buildAkamaiFingerprint uses, so it can be compared against a profile's
+// PriorityPattern with plain string equality.
+func priorityPattern(priorities []PriorityInfo) string {
+	if len(priorities) == 0 {
+		return ""
+	}
+	parts := make([]string, len(priorities))
+	for i, p := range priorities {
+		parts[i] = fmt.Sprintf("%d:%d:%d:%d", p.StreamID, p.Exclusive, p.DependsOn, p.Weight)
+	}
+	return strings.Join(parts, ",")
+}
+
+// scoreHTTP2Profile weights the ordered SETTINGS list most heavily - it's
+// the hardest field for an impersonation library to get both the content
+// and the order of right - then pseudo-header order, then exact
+// WINDOW_UPDATE/priority-pattern bonuses. Same shape as FuzzyMatchJA3's
+// weighting of ciphers over extensions over ALPN/sig-alg.
+func scoreHTTP2Profile(fp *HTTP2Fingerprint, profile HTTP2Profile) float64 {
+	score := lcsRatio(settingsOrderTokens(fp.Settings), profile.SettingsOrder) * 0.5
+	score += lcsRatio(strings.Split(fp.PseudoHeaderOrder, ","), strings.Split(profile.PseudoHeaderOrder, ",")) * 0.3
+
+	if fp.WindowUpdate == profile.WindowUpdate {
+		score += 0.1
+	}
+	if priorityPattern(fp.Priorities) == profile.PriorityPattern {
+		score += 0.1
+	}
+	return score
+}
+
+// ClassifyHTTP2 scores fp against every profile in corpus and returns the
+// topK highest-scoring candidates, highest score first - the HTTP/2
+// analogue of FuzzyMatchJA3.
+func ClassifyHTTP2(fp *HTTP2Fingerprint, corpus []HTTP2Profile, topK int) []HTTP2Candidate {
+	if len(corpus) == 0 || topK <= 0 {
+		return nil
+	}
+
+	candidates := make([]HTTP2Candidate, 0, len(corpus))
+	for _, profile := range corpus {
+		candidates = append(candidates, HTTP2Candidate{
+			Label:    profile.Label,
+			Category: profile.Category,
+			Score:    scoreHTTP2Profile(fp, profile),
+		})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+	if len(candidates) > topK {
+		candidates = candidates[:topK]
+	}
+	return candidates
+}
+
+// DiffAgainstClosestBrowser finds the highest-scoring "browser"-category
+// profile in corpus - independent of whichever category actually won
+// ClassifyHTTP2 - and reports exactly which buildAkamaiFingerprint fields
+// diverge from it. This is the per-field detail ClassifyHTTP2's scores
+// alone don't spell out, and what turns a generic "impersonator detected"
+// warning into actionable pentester guidance.
+func DiffAgainstClosestBrowser(fp *HTTP2Fingerprint, corpus []HTTP2Profile) (string, []string) {
+	var closest HTTP2Profile
+	best := -1.0
+	for _, profile := range corpus {
+		if profile.Category != "browser" {
+			continue
+		}
+		if score := scoreHTTP2Profile(fp, profile); score > best {
+			best = score
+			closest = profile
+		}
+	}
+	if best < 0 {
+		return "", nil
+	}
+
+	var diffs []string
+	if gotSettings := settingsOrderTokens(fp.Settings); !stringSlicesEqual(gotSettings, closest.SettingsOrder) {
+		diffs = append(diffs, fmt.Sprintf("SETTINGS order/values: got %s, %s sends %s",
+			strings.Join(gotSettings, ";"), closest.Label, strings.Join(closest.SettingsOrder, ";")))
+	}
+	if fp.WindowUpdate != closest.WindowUpdate {
+		diffs = append(diffs, fmt.Sprintf("WINDOW_UPDATE: got %d, %s sends %d", fp.WindowUpdate, closest.Label, closest.WindowUpdate))
+	}
+	if fp.PseudoHeaderOrder != closest.PseudoHeaderOrder {
+		diffs = append(diffs, fmt.Sprintf("pseudo-header order: got %q, %s sends %q", fp.PseudoHeaderOrder, closest.Label, closest.PseudoHeaderOrder))
+	}
+	if got := priorityPattern(fp.Priorities); got != closest.PriorityPattern {
+		diffs = append(diffs, fmt.Sprintf("PRIORITY pattern: got %q, %s sends %q", got, closest.Label, closest.PriorityPattern))
+	}
+	return closest.Label, diffs
+}
@@ -0,0 +1,136 @@
+package main
+
+//go:generate ./scripts/fetch-iana-csv.sh
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+)
+
+//go:embed data/iana/tls-parameters-4.csv
+var cipherRegistryCSV string
+
+//go:embed data/iana/tls-extensiontype-values-1.csv
+var extensionRegistryCSV string
+
+//go:embed data/iana/tls-parameters-8.csv
+var groupRegistryCSV string
+
+//go:embed data/iana/tls-signaturescheme.csv
+var sigAlgRegistryCSV string
+
+// IANAEntry is one row of an IANA TLS registry, looked up by 16-bit
+// parameter value: a cipher suite, extension type, supported group, or
+// signature scheme. Recommended mirrors that registry's own "Recommended"
+// column (RFC 8447 section 3 and successors) - "N" covers everything from RC4 and
+// 3DES down to perfectly functional but non-forward-secret RSA key exchange,
+// which is what makes it useful as a data-driven weak/deprecated signal
+// instead of a hand-maintained substring blocklist.
+type IANAEntry struct {
+	Value       uint16 `json:"value"`
+	Name        string `json:"name"`
+	Recommended bool   `json:"recommended"`
+	Reference   string `json:"reference,omitempty"`
+}
+
+// String returns Name, so an IANAEntry can stand in wherever only the name
+// was wanted (log lines, etc).
+func (e IANAEntry) String() string {
+	return e.Name
+}
+
+var (
+	cipherRegistry    = parseIANARegistry("tls-parameters-4.csv", cipherRegistryCSV)
+	extensionRegistry = parseIANARegistry("tls-extensiontype-values-1.csv", extensionRegistryCSV)
+	groupRegistry     = parseIANARegistry("tls-parameters-8.csv", groupRegistryCSV)
+	sigAlgRegistry    = parseIANARegistry("tls-signaturescheme.csv", sigAlgRegistryCSV)
+)
+
+// parseIANARegistry parses one of the embedded CSVs (as fetched by
+// scripts/fetch-iana-csv.sh) into value -> IANAEntry. All four registries
+// share the same shape closely enough to share one parser: a header row,
+// then Value, Description/Name, [other columns...], Recommended, Reference
+// as the last two columns.
+func parseIANARegistry(name, data string) map[uint16]IANAEntry {
+	r := csv.NewReader(strings.NewReader(data))
+	r.FieldsPerRecord = -1
+
+	registry := make(map[uint16]IANAEntry)
+	header := true
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("iana: parsing %s: %v", name, err)
+			break
+		}
+		if header {
+			header = false
+			continue
+		}
+		if len(record) < 4 {
+			continue
+		}
+
+		value, err := strconv.ParseUint(strings.TrimSpace(record[0]), 0, 16)
+		if err != nil {
+			continue // registries have reserved/range rows like "0x0A0A-0x?A?A,Grease,..."
+		}
+
+		entry := IANAEntry{
+			Value:       uint16(value),
+			Name:        strings.TrimSpace(record[1]),
+			Recommended: strings.EqualFold(strings.TrimSpace(record[len(record)-2]), "Y"),
+			Reference:   strings.TrimSpace(record[len(record)-1]),
+		}
+		registry[entry.Value] = entry
+	}
+	return registry
+}
+
+// lookupIANA returns registry[value], or a synthetic entry carrying just the
+// raw value formatted as hex if the registry has nothing for it (an unknown
+// or not-yet-assigned codepoint).
+func lookupIANA(registry map[uint16]IANAEntry, value uint16) IANAEntry {
+	if entry, ok := registry[value]; ok {
+		return entry
+	}
+	return IANAEntry{Value: value, Name: fmt.Sprintf("0x%04x", value)}
+}
+
+func getCipherName(cs uint16) IANAEntry {
+	if isGREASE(cs) {
+		return IANAEntry{Value: cs, Name: fmt.Sprintf("TLS_GREASE (0x%04X)", cs)}
+	}
+	return lookupIANA(cipherRegistry, cs)
+}
+
+func getExtensionName(ext uint16) IANAEntry {
+	if isGREASE(ext) {
+		return IANAEntry{Value: ext, Name: fmt.Sprintf("TLS_GREASE (0x%04x)", ext)}
+	}
+	return lookupIANA(extensionRegistry, ext)
+}
+
+func getGroupName(g uint16) IANAEntry {
+	if isGREASE(g) {
+		return IANAEntry{Value: g, Name: fmt.Sprintf("TLS_GREASE (0x%04X)", g)}
+	}
+	// Kyber-hybrid PQ groups (see pq.go) predate IANA registration, so check
+	// them before falling back to groupRegistry's raw-hex default.
+	if name, ok := pqHybridGroups[g]; ok {
+		return IANAEntry{Value: g, Name: name}
+	}
+	return lookupIANA(groupRegistry, g)
+}
+
+func getSigAlgName(alg uint16) IANAEntry {
+	return lookupIANA(sigAlgRegistry, alg)
+}
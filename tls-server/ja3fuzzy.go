@@ -0,0 +1,180 @@
+package main
+
+import "strings"
+
+// fuzzyJA3ConfidentThreshold is the minimum FuzzyMatchJA3 score at which
+// analyzeTLS treats the top candidate as an attribution rather than just a
+// suggestion alongside "Unknown".
+const fuzzyJA3ConfidentThreshold = 0.9
+
+// JA3CorpusEntry is one reference ClientHello in the fuzzy-match corpus:
+// a label plus the parsed fields FuzzyMatchJA3 compares against, distilled
+// from raw-JA3-string corpora like trisulnsm/ja3prints or salesforce/ja3
+// pulls (those only carry the MD5 and the raw "ssl,ciphers,ext,curves,fmt"
+// string; the cipher/extension/ALPN/sig-alg lists here are derived from it
+// at import time so scoring doesn't need to re-parse that string per lookup).
+type JA3CorpusEntry struct {
+	Label               string   `json:"label"`
+	CiphersHex          []string `json:"ciphers_hex"`
+	ExtensionsHex       []string `json:"extensions_hex"`
+	ALPN                []string `json:"alpn,omitempty"`
+	SignatureAlgorithms []string `json:"signature_algorithms,omitempty"`
+}
+
+// FuzzyJA3Match is one candidate returned by FuzzyMatchJA3, ranked by Score
+// (0-1, higher is a closer match).
+type FuzzyJA3Match struct {
+	Label string  `json:"label"`
+	Score float64 `json:"score"`
+}
+
+// FuzzyMatchJA3 scores tls against every corpus entry and returns the topK
+// highest-scoring candidates, for ClientHellos whose exact JA3 hash isn't in
+// the curated database - e.g. Chrome 120 with one extension reordered still
+// attributes to "Chrome 120" instead of "unknown". Scoring combines:
+//   - longest-common-subsequence ratio over the cipher list (order matters)
+//   - Jaccard similarity over the extension set, GREASE values stripped
+//   - a bonus when ALPN and signature_algorithms ordering match exactly
+func (db *FingerprintDatabase) FuzzyMatchJA3(tls *TLSFingerprint, topK int) []FuzzyJA3Match {
+	db.mu.RLock()
+	corpus := db.JA3Corpus
+	db.mu.RUnlock()
+
+	if len(corpus) == 0 || topK <= 0 {
+		return nil
+	}
+
+	extensions := stripGREASEHex(tls.ExtensionsHex)
+
+	matches := make([]FuzzyJA3Match, 0, len(corpus))
+	for _, entry := range corpus {
+		score := lcsRatio(tls.CiphersHex, entry.CiphersHex)*0.5 +
+			jaccard(extensions, stripGREASEHex(entry.ExtensionsHex))*0.4
+
+		if stringSlicesEqual(tls.ALPN, entry.ALPN) {
+			score += 0.05
+		}
+		if stringSlicesEqual(sigAlgNames(tls.SignatureAlgorithms), entry.SignatureAlgorithms) {
+			score += 0.05
+		}
+
+		matches = append(matches, FuzzyJA3Match{Label: entry.Label, Score: score})
+	}
+
+	sortMatchesDescending(matches)
+	if len(matches) > topK {
+		matches = matches[:topK]
+	}
+	return matches
+}
+
+func sortMatchesDescending(matches []FuzzyJA3Match) {
+	for i := 1; i < len(matches); i++ {
+		for j := i; j > 0 && matches[j].Score > matches[j-1].Score; j-- {
+			matches[j], matches[j-1] = matches[j-1], matches[j]
+		}
+	}
+}
+
+// lcsRatio returns the longest-common-subsequence length between a and b,
+// normalized by the length of the longer list, so identical order-preserving
+// subsequences score near 1 even when one list has extra entries spliced in.
+func lcsRatio(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				curr[j] = prev[j-1] + 1
+			} else if prev[j] >= curr[j-1] {
+				curr[j] = prev[j]
+			} else {
+				curr[j] = curr[j-1]
+			}
+		}
+		prev, curr = curr, prev
+	}
+
+	longest := len(a)
+	if len(b) > longest {
+		longest = len(b)
+	}
+	return float64(prev[len(b)]) / float64(longest)
+}
+
+// jaccard returns |a ∩ b| / |a ∪ b| over two string sets.
+func jaccard(a, b []string) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+
+	set := make(map[string]bool, len(a))
+	for _, v := range a {
+		set[v] = true
+	}
+	union := make(map[string]bool, len(a)+len(b))
+	for _, v := range a {
+		union[v] = true
+	}
+	intersection := 0
+	for _, v := range b {
+		if set[v] {
+			intersection++
+		}
+		union[v] = true
+	}
+	if len(union) == 0 {
+		return 1
+	}
+	return float64(intersection) / float64(len(union))
+}
+
+// stripGREASEHex removes GREASE values (e.g. "0x0a0a") from a hex-string list.
+func stripGREASEHex(hexVals []string) []string {
+	out := make([]string, 0, len(hexVals))
+	for _, h := range hexVals {
+		trimmed := strings.TrimPrefix(strings.ToLower(h), "0x")
+		var v uint64
+		for _, c := range []byte(trimmed) {
+			v <<= 4
+			switch {
+			case c >= '0' && c <= '9':
+				v |= uint64(c - '0')
+			case c >= 'a' && c <= 'f':
+				v |= uint64(c-'a') + 10
+			}
+		}
+		if isGREASE(uint16(v)) {
+			continue
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
+// sigAlgNames extracts the plain registry names out of a TLSFingerprint's
+// SignatureAlgorithms, so they can be compared against a JA3CorpusEntry's
+// (which only ever carried the name, not the full IANAEntry).
+func sigAlgNames(algs []IANAEntry) []string {
+	names := make([]string, len(algs))
+	for i, alg := range algs {
+		names[i] = alg.Name
+	}
+	return names
+}
+
+func stringSlicesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
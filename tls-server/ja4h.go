@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// This file adds the JA4HDatabase lookup table JA4H (HTTP-request)
+// fingerprints resolve against, analogous to JA4Database's KnownFingerprints,
+// plus ComputeJA4H: a second JA4H builder alongside buildJA4H (ja4plus.go).
+// buildJA4H fingerprints the raw request text handleHTTP already parses off
+// the wire, preserving the client's real header order, and is what feeds
+// CombinedFingerprint.HTTP.JA4H/LookupJA4H live - so DefaultJA4HDatabase's
+// baseline is keyed with it. ComputeJA4H instead takes a parsed
+// http.Header/[]*http.Cookie, for callers that only have a *http.Request to
+// work with (tests, a future reverse-proxy ingestion point); since
+// http.Header is an unordered map it falls back to a sorted header-name
+// list rather than wire order, and it adds a fourth segment hashing cookie
+// name=value pairs that buildJA4H doesn't carry. Like buildJA4S/buildJA4X
+// (ja4plus.go), it has no live call site yet.
+type JA4HDatabase struct {
+	Description       string   `json:"description"`
+	Sources           []string `json:"sources"`
+	LastUpdated       string   `json:"last_updated"`
+	KnownFingerprints map[string]struct {
+		Name       string `json:"name"`
+		ClientType string `json:"client_type"`
+	} `json:"known_fingerprints"`
+}
+
+// DefaultJA4HDatabase ships a small built-in baseline, analogous to
+// DefaultMITMDatabase/DefaultFaviconCorpus: a handful of low-header-count
+// clients (curl, python-requests, Go's net/http) whose buildJA4H output is
+// stable enough to hardcode, pending an operator-supplied
+// ./data/ja4h_fingerprints.json with a measured corpus.
+func DefaultJA4HDatabase() *JA4HDatabase {
+	entries := map[string]struct {
+		Name       string `json:"name"`
+		ClientType string `json:"client_type"`
+	}{
+		buildJA4H("GET", "HTTP/1.1", []string{"Host", "User-Agent", "Accept"}, nil, ""): {
+			Name: "curl", ClientType: "library",
+		},
+		buildJA4H("GET", "HTTP/1.1", []string{"Host", "User-Agent", "Accept-Encoding", "Accept", "Connection"}, nil, ""): {
+			Name: "python-requests", ClientType: "library",
+		},
+		buildJA4H("GET", "HTTP/1.1", []string{"Host", "User-Agent", "Accept-Encoding"}, nil, ""): {
+			Name: "Go-http-client", ClientType: "library",
+		},
+	}
+	return &JA4HDatabase{
+		Description:       "Built-in baseline JA4H (HTTP-request) fingerprints",
+		LastUpdated:       "2026",
+		KnownFingerprints: entries,
+	}
+}
+
+// LookupJA4H looks up a buildJA4H/ComputeJA4H hash in the active JA4H database.
+func (db *FingerprintDatabase) LookupJA4H(hash string) (name, clientType string, ok bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.JA4H == nil {
+		return "", "", false
+	}
+	entry, found := db.JA4H.KnownFingerprints[hash]
+	if !found {
+		return "", "", false
+	}
+	return entry.Name, entry.ClientType, true
+}
+
+// ja4hVersionCode maps an HTTP version string to JA4H's two-digit code, the
+// same mapping buildJA4H uses.
+func ja4hVersionCode(version string) string {
+	switch strings.TrimSpace(version) {
+	case "HTTP/1.0":
+		return "10"
+	case "HTTP/2", "HTTP/2.0":
+		return "20"
+	default:
+		return "11"
+	}
+}
+
+// ComputeJA4H computes a JA4H-style hash from a parsed http.Header and
+// cookie list: method+version+cookie flag+referer flag+header count+
+// Accept-Language prefix, then three 12-hex-char truncated SHA-256 segments
+// - header names, cookie names, and cookie name=value pairs - joined with
+// underscores. headers is walked in sorted-name order (see file comment for
+// why), cookies in the order given.
+func ComputeJA4H(method, version string, headers http.Header, cookies []*http.Cookie, acceptLang string) string {
+	m := strings.ToLower(method)
+	if len(m) > 2 {
+		m = m[:2]
+	}
+	for len(m) < 2 {
+		m += "0"
+	}
+
+	cookieFlag := "n"
+	if len(cookies) > 0 {
+		cookieFlag = "c"
+	}
+
+	refererFlag := "n"
+	var headerNames []string
+	for name := range headers {
+		switch strings.ToLower(name) {
+		case "cookie", "referer":
+			continue
+		}
+		headerNames = append(headerNames, name)
+	}
+	if headers.Get("Referer") != "" {
+		refererFlag = "r"
+	}
+	sort.Strings(headerNames)
+
+	headerCount := fmt.Sprintf("%02d", min(len(headerNames), 99))
+
+	lang := "00"
+	if primary := strings.TrimSpace(strings.Split(acceptLang, ",")[0]); primary != "" {
+		lower := strings.ToLower(primary)
+		if len(lower) >= 2 {
+			lang = lower[:2]
+		} else {
+			lang = lower + "0"
+		}
+	}
+
+	prefix := m + ja4hVersionCode(version) + cookieFlag + refererFlag + headerCount + lang
+
+	headerHash := sha256.Sum256([]byte(strings.Join(headerNames, ",")))
+	headerPart := hex.EncodeToString(headerHash[:])[:12]
+
+	cookiePart := strings.Repeat("0", 12)
+	pairPart := strings.Repeat("0", 12)
+	if len(cookies) > 0 {
+		names := make([]string, len(cookies))
+		pairs := make([]string, len(cookies))
+		for i, c := range cookies {
+			names[i] = c.Name
+			pairs[i] = c.Name + "=" + c.Value
+		}
+		nameHash := sha256.Sum256([]byte(strings.Join(names, ",")))
+		cookiePart = hex.EncodeToString(nameHash[:])[:12]
+		pairHash := sha256.Sum256([]byte(strings.Join(pairs, ",")))
+		pairPart = hex.EncodeToString(pairHash[:])[:12]
+	}
+
+	return prefix + "_" + headerPart + "_" + cookiePart + "_" + pairPart
+}
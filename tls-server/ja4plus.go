@@ -0,0 +1,198 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This file holds the rest of the JA4+ family (https://github.com/FoxIO-LLC/ja4)
+// alongside buildJA4/buildJA4R in main.go: JA4S (ServerHello), JA4H (HTTP
+// request), JA4T (TCP), JA4X (X.509) and JA4L (latency). Each follows the
+// same "good enough to cluster on, not a byte-exact spec implementation"
+// approach as buildJA4/buildJA4R.
+
+// HTTPFingerprint holds the JA4H fingerprint of a client's HTTP request,
+// populated by handleHTTP once a request line and headers have been parsed.
+type HTTPFingerprint struct {
+	JA4H string `json:"ja4h"`
+}
+
+// buildJA4S fingerprints a ServerHello the way JA4 fingerprints a
+// ClientHello: protocol, negotiated version, ALPN and a hash of the
+// extensions in the order the server sent them. Unlike the other builders
+// here it has no live call site yet - this server only ever plays the TLS
+// server role, so there's no ServerHello to observe until something here
+// acts as a TLS client (the outbound uTLS probe mode is the planned first
+// caller).
+func buildJA4S(version uint16, cipher uint16, extensions []uint16, alpn string) string {
+	proto := "t"
+	ver := "00"
+	switch version {
+	case 0x0304:
+		ver = "13"
+	case 0x0303:
+		ver = "12"
+	case 0x0302:
+		ver = "11"
+	case 0x0301:
+		ver = "10"
+	}
+
+	alpnFirst := "00"
+	if len(alpn) >= 2 {
+		alpnFirst = alpn[:2]
+	} else if alpn != "" {
+		alpnFirst = alpn
+	}
+
+	extCount := fmt.Sprintf("%02d", min(len(extensions), 99))
+	part1 := proto + ver + extCount + alpnFirst
+
+	cipherHex := fmt.Sprintf("%04x", cipher)
+
+	extStr := joinUint16Hex(extensions, ",")
+	extHash := sha256.Sum256([]byte(extStr))
+	extPart := hex.EncodeToString(extHash[:])[:12]
+
+	return part1 + "_" + cipherHex + "_" + extPart
+}
+
+// buildJA4H fingerprints an HTTP request: method, version, cookie/referer
+// presence, header count and Accept-Language make up the readable prefix;
+// a hash of the header names (in the order the client sent them, cookie and
+// referer excluded since their presence is already flagged) and a hash of
+// the cookie names make up the rest. headerNames and cookieNames are both
+// expected in on-the-wire order; cookieNames is sorted here the way the JA4
+// spec sorts cookie fields before hashing.
+func buildJA4H(method, httpVersion string, headerNames []string, cookieNames []string, acceptLanguage string) string {
+	m := strings.ToLower(method)
+	if len(m) > 2 {
+		m = m[:2]
+	}
+	for len(m) < 2 {
+		m += "0"
+	}
+
+	ver := "11"
+	switch strings.TrimSpace(httpVersion) {
+	case "HTTP/1.0":
+		ver = "10"
+	case "HTTP/2", "HTTP/2.0":
+		ver = "20"
+	}
+
+	cookieFlag := "n"
+	refererFlag := "n"
+	var filteredHeaders []string
+	for _, h := range headerNames {
+		switch strings.ToLower(h) {
+		case "cookie":
+			cookieFlag = "c"
+			continue
+		case "referer":
+			refererFlag = "r"
+			continue
+		}
+		filteredHeaders = append(filteredHeaders, h)
+	}
+
+	headerCount := fmt.Sprintf("%02d", min(len(filteredHeaders), 99))
+
+	lang := "0000"
+	if al := strings.ToLower(strings.ReplaceAll(acceptLanguage, "-", "")); al != "" {
+		if len(al) >= 4 {
+			lang = al[:4]
+		} else {
+			lang = al + strings.Repeat("0", 4-len(al))
+		}
+	}
+
+	prefix := "h" + m + ver + cookieFlag + refererFlag + headerCount + lang
+
+	headerHash := sha256.Sum256([]byte(strings.Join(filteredHeaders, ",")))
+	headerPart := hex.EncodeToString(headerHash[:])[:12]
+
+	cookiePart := strings.Repeat("0", 12)
+	if len(cookieNames) > 0 {
+		sortedCookies := make([]string, len(cookieNames))
+		copy(sortedCookies, cookieNames)
+		sort.Strings(sortedCookies)
+		cookieHash := sha256.Sum256([]byte(strings.Join(sortedCookies, ",")))
+		cookiePart = hex.EncodeToString(cookieHash[:])[:12]
+	}
+
+	return prefix + "_" + headerPart + "_" + cookiePart
+}
+
+// buildJA4T fingerprints the TCP handshake a client's SYN carried: window
+// size, the TCP option kinds in the order they appeared, MSS and window
+// scale. It only runs off a SYN actually captured off the wire (see tcp.go),
+// so it needs whatever CaptureBackend is configured (libpcap/AF_PACKET/eBPF,
+// see capture_backend.go) to be working; with none available, or when all we
+// ever saw was a SYN-ACK/RST, TCPIPFingerprint.JA4T is simply left empty.
+func buildJA4T(windowSize int, options []TCPOption, mss int, windowScale int) string {
+	kinds := make([]string, len(options))
+	for i, opt := range options {
+		kinds[i] = strconv.Itoa(opt.Kind)
+	}
+	return fmt.Sprintf("%d_%s_%d_%d", windowSize, strings.Join(kinds, "-"), mss, windowScale)
+}
+
+// buildJA4X fingerprints an X.509 certificate: a hash of the issuer RDN
+// sequence, a hash of the subject RDN sequence, and a hash of the
+// certificate's extension OIDs in order. There's no live call site for it
+// yet - this server doesn't request a client certificate (see
+// tls.Config.ClientAuth in main.go), so there are no peer certs to fingerprint
+// until mTLS support is added; it's also usable directly against a PEM/DER
+// cert supplied some other way (e.g. a future /api/decode-cert endpoint).
+func buildJA4X(cert *x509.Certificate) string {
+	issuerHash := sha256.Sum256([]byte(cert.Issuer.ToRDNSequence().String()))
+	issuerPart := hex.EncodeToString(issuerHash[:])[:12]
+
+	subjectHash := sha256.Sum256([]byte(cert.Subject.ToRDNSequence().String()))
+	subjectPart := hex.EncodeToString(subjectHash[:])[:12]
+
+	oids := make([]string, len(cert.Extensions))
+	for i, ext := range cert.Extensions {
+		oids[i] = ext.Id.String()
+	}
+	extHash := sha256.Sum256([]byte(strings.Join(oids, ",")))
+	extPart := hex.EncodeToString(extHash[:])[:12]
+
+	return issuerPart + "_" + subjectPart + "_" + extPart
+}
+
+// ja4lBuckets are the latency ranges JA4L groups an RTT into - roughly
+// same-datacenter, same-region, and cross-region/continent. The exact
+// boundaries don't matter as much as clients consistently landing in the
+// same bucket across reconnects.
+var ja4lBuckets = []struct {
+	max   time.Duration
+	label string
+}{
+	{1 * time.Millisecond, "dc"},
+	{10 * time.Millisecond, "region"},
+	{50 * time.Millisecond, "continent"},
+	{1<<63 - 1, "intercontinental"},
+}
+
+// buildJA4L buckets a round-trip time (the TLS handshake Finished RTT this
+// server already measures for the native TLS 1.3 path - see
+// TLSFingerprint.FinishedRTTMs in main.go) into a coarse latency class, so
+// two connections with near-identical but not bit-identical RTTs still
+// cluster together.
+func buildJA4L(rtt time.Duration) string {
+	micros := rtt.Microseconds()
+	for _, b := range ja4lBuckets {
+		if rtt <= b.max {
+			return fmt.Sprintf("%s_%dus", b.label, micros)
+		}
+	}
+	return fmt.Sprintf("intercontinental_%dus", micros)
+}
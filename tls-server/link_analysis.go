@@ -0,0 +1,68 @@
+package main
+
+// LinkAnalysis reports p0f-style MTU/tunnel inference derived from the TCP MSS,
+// IP version, and option layout of a single fingerprint.
+type LinkAnalysis struct {
+	InferredMTU     int    `json:"inferred_mtu"`
+	LinkType        string `json:"link_type"` // ethernet/pppoe/ipsec-vpn/wireguard/tunnel-unknown/...
+	TunnelSuspected bool   `json:"tunnel_suspected"`
+}
+
+// Well-known tunnel/VPN/dialup MTUs, keyed by the MTU value p0f-style analysis
+// expects to see (MSS + 40, or +60 for IPv6 with common options).
+var knownTunnelMTUs = map[int]string{
+	1400: "pppoe",
+	1380: "ipsec-vpn",
+	1360: "wireguard",
+	1420: "wireguard",
+	1280: "ipv6-min",
+	576:  "dialup",
+}
+
+const ethernetMTU = 1500
+
+// analyzeLinkFromMSS infers the link MTU and type from the fingerprint's MSS,
+// IP version, and option set. Missing SACK_PERM combined with a non-standard
+// MSS is treated as a strong tunnel hint even when the MTU doesn't match one
+// of the well-known values above.
+func analyzeLinkFromMSS(fp *TCPIPFingerprint) *LinkAnalysis {
+	if fp.MSS == 0 {
+		return nil
+	}
+
+	overhead := 40
+	if fp.IPVersion == 6 {
+		overhead = 60
+	}
+	mtu := fp.MSS + overhead
+
+	link := &LinkAnalysis{InferredMTU: mtu}
+
+	if linkType, known := knownTunnelMTUs[mtu]; known {
+		link.LinkType = linkType
+		link.TunnelSuspected = true
+		return link
+	}
+
+	if mtu == ethernetMTU {
+		link.LinkType = "ethernet"
+		return link
+	}
+
+	hasSACKPerm := false
+	for _, opt := range fp.Options {
+		if opt.Kind == TCPOptionKindSACKPerm {
+			hasSACKPerm = true
+			break
+		}
+	}
+
+	if !hasSACKPerm && mtu != ethernetMTU {
+		link.LinkType = "tunnel-unknown"
+		link.TunnelSuspected = true
+		return link
+	}
+
+	link.LinkType = "ethernet"
+	return link
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"crypto/md5"
 	"crypto/sha256"
+	"crypto/subtle"
 	"crypto/tls"
 	"encoding/binary"
 	"encoding/hex"
@@ -11,6 +13,7 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
@@ -20,88 +23,9 @@ import (
 	"golang.org/x/net/http2/hpack"
 )
 
-// TLS Extension names
-var extensionNames = map[uint16]string{
-	0:     "server_name",
-	1:     "max_fragment_length",
-	5:     "status_request",
-	10:    "supported_groups",
-	11:    "ec_point_formats",
-	13:    "signature_algorithms",
-	14:    "use_srtp",
-	15:    "heartbeat",
-	16:    "application_layer_protocol_negotiation",
-	17:    "signed_certificate_timestamp",
-	18:    "client_certificate_type",
-	19:    "server_certificate_type",
-	20:    "padding",
-	21:    "encrypt_then_mac",
-	22:    "extended_master_secret",
-	23:    "extended_master_secret",
-	27:    "compress_certificate",
-	28:    "record_size_limit",
-	35:    "session_ticket",
-	41:    "pre_shared_key",
-	42:    "early_data",
-	43:    "supported_versions",
-	44:    "cookie",
-	45:    "psk_key_exchange_modes",
-	47:    "certificate_authorities",
-	48:    "oid_filters",
-	49:    "post_handshake_auth",
-	50:    "signature_algorithms_cert",
-	51:    "key_share",
-	17513: "application_settings",
-	65037: "encrypted_client_hello",
-	65281: "renegotiation_info",
-}
-
-// Cipher suite names
-var cipherNames = map[uint16]string{
-	0x1301: "TLS_AES_128_GCM_SHA256",
-	0x1302: "TLS_AES_256_GCM_SHA384",
-	0x1303: "TLS_CHACHA20_POLY1305_SHA256",
-	0xc02b: "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
-	0xc02f: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
-	0xc02c: "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
-	0xc030: "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
-	0xcca9: "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
-	0xcca8: "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
-	0xc013: "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA",
-	0xc014: "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA",
-	0x009c: "TLS_RSA_WITH_AES_128_GCM_SHA256",
-	0x009d: "TLS_RSA_WITH_AES_256_GCM_SHA384",
-	0x002f: "TLS_RSA_WITH_AES_128_CBC_SHA",
-	0x0035: "TLS_RSA_WITH_AES_256_CBC_SHA",
-}
-
-// Supported groups names
-var groupNames = map[uint16]string{
-	23:   "secp256r1",
-	24:   "secp384r1",
-	25:   "secp521r1",
-	29:   "x25519",
-	30:   "x448",
-	256:  "ffdhe2048",
-	257:  "ffdhe3072",
-	258:  "ffdhe4096",
-	4588: "X25519MLKEM768",
-}
-
-// Signature algorithms
-var sigAlgNames = map[uint16]string{
-	0x0401: "rsa_pkcs1_sha256",
-	0x0501: "rsa_pkcs1_sha384",
-	0x0601: "rsa_pkcs1_sha512",
-	0x0403: "ecdsa_secp256r1_sha256",
-	0x0503: "ecdsa_secp384r1_sha384",
-	0x0603: "ecdsa_secp521r1_sha512",
-	0x0804: "rsa_pss_rsae_sha256",
-	0x0805: "rsa_pss_rsae_sha384",
-	0x0806: "rsa_pss_rsae_sha512",
-	0x0807: "ed25519",
-	0x0808: "ed448",
-}
+// Cipher/extension/group/signature-scheme names are looked up from the IANA
+// registries embedded in iana.go (getCipherName/getExtensionName/
+// getGroupName/getSigAlgName) rather than hand-maintained here.
 
 // Complete TLS Fingerprint
 type TLSFingerprint struct {
@@ -109,39 +33,76 @@ type TLSFingerprint struct {
 	JA3     string `json:"ja3"`
 	JA3Hash string `json:"ja3_hash"`
 
+	// JA3N: JA3 with its extension list sorted, so an extension reorder
+	// (which otherwise changes JA3's hash with no meaningful fingerprint
+	// change) doesn't move the hash.
+	JA3N     string `json:"ja3n"`
+	JA3NHash string `json:"ja3n_hash"`
+
 	// JA4
 	JA4  string `json:"ja4"`
 	JA4R string `json:"ja4_r,omitempty"`
 
 	// Raw data
-	TLSVersionRecord     string   `json:"tls_version_record"`
-	TLSVersionNegotiated string   `json:"tls_version_negotiated,omitempty"`
-	ClientRandom         string   `json:"client_random"`
-	SessionID            string   `json:"session_id"`
-	Ciphers              []string `json:"ciphers"`
-	CiphersHex           []string `json:"ciphers_hex"`
+	TLSVersionRecord     string          `json:"tls_version_record"`
+	TLSVersionNegotiated string          `json:"tls_version_negotiated,omitempty"`
+	ClientRandom         string          `json:"client_random"`
+	SessionID            string          `json:"session_id"`
+	Ciphers              []IANAEntry     `json:"ciphers"`
+	CiphersHex           []string        `json:"ciphers_hex"`
 	Extensions           []ExtensionInfo `json:"extensions"`
-	ExtensionsHex        []string `json:"extensions_hex"`
-	SupportedGroups      []string `json:"supported_groups"`
-	ECPointFormats       []string `json:"ec_point_formats"`
-	SignatureAlgorithms  []string `json:"signature_algorithms"`
-	ALPN                 []string `json:"alpn"`
-	SupportedVersions    []string `json:"supported_versions"`
-	SNI                  string   `json:"sni"`
-	CompressMethods      []uint8  `json:"compress_methods"`
+	ExtensionsHex        []string        `json:"extensions_hex"`
+	SupportedGroups      []IANAEntry     `json:"supported_groups"`
+	ECPointFormats       []string        `json:"ec_point_formats"`
+	SignatureAlgorithms  []IANAEntry     `json:"signature_algorithms"`
+	ALPN                 []string        `json:"alpn"`
+	SupportedVersions    []string        `json:"supported_versions"`
+	SNI                  string          `json:"sni"`
+	CompressMethods      []uint8         `json:"compress_methods"`
+
+	// CertCompressionAlgs are the CertificateCompressionAlgorithm values from
+	// the compress_certificate extension (RFC 8879, type 27) - a growing
+	// identification signal since e.g. Chrome advertises brotli and Firefox
+	// doesn't.
+	CertCompressionAlgs []uint16 `json:"cert_compression_algs,omitempty"`
+
+	// ClientKeyShares maps key_share group (hex ID, e.g. "0x001d" for X25519) to
+	// the client's raw public key bytes (hex), used by the native TLS 1.3
+	// handshake path to compute the ECDHE shared secret.
+	ClientKeyShares map[string]string `json:"client_key_shares,omitempty"`
+
+	// PQSupport records any Kyber-hybrid post-quantum key-exchange groups
+	// seen in supported_groups/key_share (see pq.go) - nil when none were
+	// advertised, which is still true of most traffic today.
+	PQSupport *PQKeyExchangeInfo `json:"pq_support,omitempty"`
+
+	// Fields populated by the native TLS handshake (see tlsstack.go) when it
+	// completes the handshake itself instead of handing off to crypto/tls.
+	FinishedRTTMs       float64    `json:"finished_rtt_ms,omitempty"`
+	SentAlert           string     `json:"sent_alert,omitempty"`
+	ResumptionMode      string     `json:"resumption_mode,omitempty"`
+	NegotiatedSigScheme *IANAEntry `json:"negotiated_sig_scheme,omitempty"`
+
+	// JA4L: FinishedRTTMs above, bucketed (see buildJA4L in ja4plus.go)
+	JA4L string `json:"ja4l,omitempty"`
 }
 
-// CombinedFingerprint holds TLS, HTTP/2, and TCP/IP fingerprints
+// CombinedFingerprint holds TLS, HTTP/2, TCP/IP and HTTP fingerprints
 type CombinedFingerprint struct {
-	TLS   *TLSFingerprint   `json:"tls"`
-	HTTP2 *HTTP2Fingerprint `json:"http2,omitempty"`
-	TCP   *TCPIPFingerprint `json:"tcp,omitempty"`
+	TLS      *TLSFingerprint   `json:"tls"`
+	HTTP2    *HTTP2Fingerprint `json:"http2,omitempty"`
+	HTTP     *HTTPFingerprint  `json:"http,omitempty"`
+	TCP      *TCPIPFingerprint `json:"tcp,omitempty"`
+	QUIC     *QUICFingerprint  `json:"quic,omitempty"`
+	Behavior *TLSBehavior      `json:"tls_behavior,omitempty"`
 }
 
 type ExtensionInfo struct {
-	Name string      `json:"name"`
-	ID   uint16      `json:"id"`
-	Data interface{} `json:"data,omitempty"`
+	Name        string      `json:"name"`
+	ID          uint16      `json:"id"`
+	Recommended bool        `json:"recommended"`
+	Reference   string      `json:"reference,omitempty"`
+	Data        interface{} `json:"data,omitempty"`
 }
 
 // Store
@@ -150,6 +111,26 @@ var (
 	storeMutex       sync.RWMutex
 )
 
+// adminToken gates /api/probe, /api/policy's POST, and /admin/reload (see
+// isAuthorizedAdmin) - it's set once from -admin-token in main() before the
+// listener starts, so it needs no mutex. Left empty, those endpoints refuse
+// every request rather than being left open.
+var adminToken string
+
+// isAuthorizedAdmin reports whether request carries a token= parameter
+// matching -admin-token. An empty adminToken always returns false: leaving
+// the flag unset disables the gated endpoints instead of leaving them open
+// to any caller, since all three let a client rewrite live server state
+// (SetVersionPolicy, a forced database Reload) or reach arbitrary network
+// targets (ReplayFingerprint).
+func isAuthorizedAdmin(method, fullPath, request string) bool {
+	if adminToken == "" {
+		return false
+	}
+	given := requestParam(method, fullPath, request, "token")
+	return subtle.ConstantTimeCompare([]byte(given), []byte(adminToken)) == 1
+}
+
 func main() {
 	// 命令行参数
 	port := flag.Int("port", 8443, "服务监听端口")
@@ -158,11 +139,52 @@ func main() {
 	host := flag.String("host", "0.0.0.0", "监听地址")
 	iface := flag.String("iface", "", "网络接口名称 (如 en0, eth0)，留空自动检测")
 	disableTCP := flag.Bool("disable-tcp", false, "禁用 TCP/IP 指纹采集")
+	disableQUIC := flag.Bool("disable-quic", false, "禁用 QUIC/HTTP3 Initial 包指纹采集")
+	p0fDBPath := flag.String("p0f-db", "", "p0f.fp 格式的 TCP/IP 签名数据库路径，留空则使用内置启发式规则")
+	captureBackend := flag.String("capture-backend", "libpcap", "TCP 抓包后端: libpcap / afpacket / ebpf")
+	captureRingMB := flag.Int("capture-ring-mb", 0, "afpacket 后端的环形缓冲区大小 (MB)，0 使用默认值")
+	captureFanoutWorkers := flag.Int("capture-fanout-workers", 0, "afpacket 后端共享 PACKET_FANOUT_HASH 的 worker 数量，0 使用默认值")
+	enableProbe := flag.Bool("enable-probe", false, "启用主动 TLS 探测模式：发送畸形 ServerHello 并记录客户端返回的 Alert，而非正常握手")
+	enableHTTP2Probe := flag.Bool("enable-http2-probe", false, "启用 HTTP/2 一致性探测模式：在正常响应之后发送一组不合规帧，并记录客户端的反应（忽略/RST_STREAM/GOAWAY/连接关闭）")
+	serverProfilePath := flag.String("server-profile", "", "ServerProfile JSON 文件路径，用于让服务端的 TLS/HTTP2 响应模仿指定目标；留空使用内置默认行为")
+	versionPolicyPath := flag.String("version-policy", "", "客户端浏览器/系统最低版本安全策略 JSON 文件路径，留空使用内置默认策略；可通过 /api/policy 接口热重载")
+	mitmCorpusURL := flag.String("mitm-corpus-url", "", "远程 MITM 检测指纹库 URL，启动时通过 HTTP(S) 拉取覆盖 ./data/mitm_corpus.json 与内置基线；留空则不拉取")
+	syncOnce := flag.Bool("sync", false, "根据 ./data/sources.json 拉取并合并一次远程 JA3/JA4/HTTP2 指纹源，完成后立即退出，不启动服务")
+	syncInterval := flag.Duration("sync-interval", DefaultSyncInterval, "后台自动同步 ./data/sources.json 中远程指纹源的间隔")
+	adminTokenFlag := flag.String("admin-token", "", "/api/probe、/api/policy (POST)、/admin/reload 等管理接口所需的共享密钥 (通过 ?token= 或表单字段传递)；留空则禁用这些接口")
 	flag.Parse()
 
+	adminToken = *adminTokenFlag
+	if adminToken == "" {
+		log.Println("[WARN] -admin-token not set: /api/probe, /api/policy (POST), and /admin/reload are disabled")
+	}
+
 	// Initialize fingerprint database
 	log.Println("Loading fingerprint databases...")
-	GetDatabase()
+
+	if *syncOnce {
+		if err := GetDatabase().Sync(context.Background()); err != nil {
+			log.Fatalf("Sync failed: %v", err)
+		}
+		log.Println("Sync complete")
+		return
+	}
+
+	GetDatabase().StartHotReload(30 * time.Second)
+	go func() {
+		if err := GetDatabase().Watch(context.Background()); err != nil {
+			log.Printf("[WARNING] fsnotify watch disabled, relying on the stat-poll hot reload above: %v", err)
+		}
+	}()
+	GetDatabase().StartSync(*syncInterval)
+
+	if *p0fDBPath != "" {
+		if err := LoadP0fDatabase(*p0fDBPath); err != nil {
+			log.Printf("[WARNING] Failed to load p0f database: %v", err)
+		} else {
+			log.Printf("[DB] Loaded p0f signature database from %s", *p0fDBPath)
+		}
+	}
 
 	// Load certificate
 	cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
@@ -170,14 +192,55 @@ func main() {
 		log.Fatalf("Failed to load certificate: %v", err)
 	}
 
+	serverProfile := DefaultServerProfile()
+	if *serverProfilePath != "" {
+		if loaded, err := LoadServerProfile(*serverProfilePath); err != nil {
+			log.Printf("[WARNING] Failed to load server profile: %v, using default", err)
+		} else {
+			serverProfile = loaded
+		}
+	}
+
+	if *versionPolicyPath != "" {
+		if loaded, err := LoadVersionPolicy(*versionPolicyPath); err != nil {
+			log.Printf("[WARNING] Failed to load version policy: %v, using built-in default", err)
+		} else {
+			SetVersionPolicy(loaded, *versionPolicyPath)
+		}
+	}
+
+	if *mitmCorpusURL != "" {
+		if loaded, err := LoadMITMDatabaseURL(*mitmCorpusURL); err != nil {
+			log.Printf("[WARNING] Failed to fetch MITM corpus from %s: %v, keeping file/built-in corpus", *mitmCorpusURL, err)
+		} else {
+			GetDatabase().SetMITM(loaded)
+			log.Printf("[DB] Loaded MITM-detection corpus from %s", *mitmCorpusURL)
+		}
+	}
+
 	// Start TCP/IP fingerprint capture (requires root/sudo)
 	if !*disableTCP {
-		if err := StartTCPCapture(*iface, *port); err != nil {
+		captureCfg := CaptureConfig{
+			Backend:       *captureBackend,
+			RingSizeMB:    *captureRingMB,
+			FanoutWorkers: *captureFanoutWorkers,
+		}
+		if err := StartTCPCaptureWithConfig(*iface, *port, CaptureModeSYN, captureCfg); err != nil {
 			log.Printf("[WARNING] TCP fingerprint capture disabled: %v", err)
 			log.Printf("[WARNING] Run with sudo for TCP/IP fingerprinting, or use -disable-tcp flag")
 		} else {
-			// Start cleanup goroutine
+			// Start cleanup goroutines
 			CleanupOldFingerprints(30 * time.Minute)
+			CleanupTSHistory(30 * time.Minute)
+		}
+	}
+
+	// Start QUIC/HTTP3 Initial packet fingerprinting (UDP, same port as TLS)
+	if !*disableQUIC {
+		if err := StartQUICListener(fmt.Sprintf("%s:%d", *host, *port)); err != nil {
+			log.Printf("[WARNING] QUIC fingerprint capture disabled: %v", err)
+		} else {
+			CleanupQUICReassembly(5 * time.Minute)
 		}
 	}
 
@@ -197,25 +260,27 @@ func main() {
 			log.Printf("Accept error: %v", err)
 			continue
 		}
-		go handleConnection(conn, &cert)
+		go handleConnection(conn, &cert, *enableProbe, *enableHTTP2Probe, serverProfile)
 	}
 }
 
-func handleConnection(conn net.Conn, cert *tls.Certificate) {
+func handleConnection(conn net.Conn, cert *tls.Certificate, probeMode bool, http2ProbeMode bool, profile *ServerProfile) {
 	defer conn.Close()
 
 	// Set read deadline
 	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
 
-	// Read ClientHello - 需要读取完整数据，Chrome的ClientHello可能很大
-	buf := make([]byte, 16384)
-	n, err := conn.Read(buf)
+	// Read the full ClientHello handshake message regardless of how TCP or
+	// the record layer split it up - PQ key shares like X25519MLKEM768 push
+	// real Chrome ClientHellos past 4KB, often across several reads and
+	// occasionally across more than one TLS record.
+	handshakeMsg, err := NewTLSRecordReader(conn).ReadHandshakeMessage()
 	if err != nil {
 		log.Printf("Read error: %v", err)
 		return
 	}
 
-	clientHelloData := buf[:n]
+	clientHelloData := wrapHandshakeRecord(handshakeMsg)
 	remoteAddr := conn.RemoteAddr().String()
 
 	// Parse ClientHello for TLS fingerprint
@@ -242,12 +307,58 @@ func handleConnection(conn net.Conn, cert *tls.Certificate) {
 		log.Printf("TCP fingerprint for %s: TTL=%d, OS=%s", clientIP, tcpFp.TTL, tcpFp.InferredOS)
 	}
 
+	// In -enable-probe mode we never do a real handshake: we reply with one
+	// deliberately malformed ServerHello from probeSequence and record the
+	// Alert the client tears the connection down with. Each connection only
+	// yields one probe (the client is gone once it sends its Alert), so the
+	// matrix in combined.Behavior fills in across the client's reconnects.
+	if probeMode {
+		combined.Behavior = runActiveProbe(conn, clientIP)
+		storeMutex.Lock()
+		fingerprintStore[remoteAddr] = combined
+		fingerprintStore[clientIP] = combined
+		storeMutex.Unlock()
+		log.Printf("Active TLS probe for %s: %+v", remoteAddr, combined.Behavior.Probes)
+		return
+	}
+
+	// Clients offering TLS 1.3 + X25519 get our own handshake implementation
+	// instead of crypto/tls, so we can observe Finished timing, resumption,
+	// and client-sent alerts (see tlsstack.go). Once we've started writing
+	// our own ServerHello, bytes already consumed from conn can't be handed
+	// back to the replayConn/crypto/tls fallback below, so a failure here
+	// just ends the connection rather than retrying the legacy path.
+	if nativeHandshakeApplicable(tlsFp) {
+		appConn, negotiatedProto, err := runNativeTLS13Handshake(conn, cert, clientHelloData, tlsFp, TLSHandshakeHooks{}, profile)
+		if err != nil {
+			log.Printf("Native TLS 1.3 handshake error: %v", err)
+			return
+		}
+		defer appConn.Close()
+
+		log.Printf("Native TLS 1.3 handshake complete for %s: finished_rtt=%.1fms alpn=%s", remoteAddr, tlsFp.FinishedRTTMs, negotiatedProto)
+
+		if negotiatedProto == "h2" {
+			handleHTTP2(appConn, remoteAddr, combined, profile, http2ProbeMode)
+		} else {
+			storeMutex.Lock()
+			fingerprintStore[remoteAddr] = combined
+			fingerprintStore[clientIP] = combined
+			storeMutex.Unlock()
+			handleHTTP(appConn, remoteAddr)
+		}
+		return
+	}
+
 	// Create TLS config with HTTP/2 support
 	tlsConfig := &tls.Config{
 		Certificates: []tls.Certificate{*cert},
 		MinVersion:   tls.VersionTLS12,
 		MaxVersion:   tls.VersionTLS13,
-		NextProtos:   []string{"h2", "http/1.1"}, // Enable HTTP/2
+		NextProtos:   profile.ALPNPreference, // Enable HTTP/2
+	}
+	if len(profile.CipherPreference) > 0 {
+		tlsConfig.CipherSuites = profile.CipherPreference
 	}
 
 	// Create a wrapper that replays the ClientHello data
@@ -274,7 +385,7 @@ func handleConnection(conn net.Conn, cert *tls.Certificate) {
 
 	if isHTTP2 {
 		// Handle HTTP/2 connection with fingerprinting
-		handleHTTP2(tlsConn, remoteAddr, combined)
+		handleHTTP2(tlsConn, remoteAddr, combined, profile, http2ProbeMode)
 	} else {
 		// Store fingerprint (HTTP/1.1, no HTTP/2 fingerprint)
 		storeMutex.Lock()
@@ -308,7 +419,7 @@ func (c *replayConn) Read(b []byte) (int, error) {
 }
 
 // handleHTTP2 handles HTTP/2 connections with fingerprint extraction
-func handleHTTP2(conn net.Conn, remoteAddr string, combined *CombinedFingerprint) {
+func handleHTTP2(conn net.Conn, remoteAddr string, combined *CombinedFingerprint, profile *ServerProfile, probeMode bool) {
 	defer conn.Close()
 
 	conn.SetReadDeadline(time.Now().Add(30 * time.Second))
@@ -350,13 +461,24 @@ func handleHTTP2(conn net.Conn, remoteAddr string, combined *CombinedFingerprint
 
 	// Now we need to respond as an HTTP/2 server
 	// Send SETTINGS frame (server settings)
-	serverSettings := buildServerSettingsFrame()
+	serverSettings := buildServerSettingsFrame(profile)
 	conn.Write(serverSettings)
+	if http2Fp != nil {
+		// Anchors BehaviorProfile.SettingsAckDelayMS once the client's own
+		// SETTINGS-ACK shows up in a later read (see IngestMore below).
+		http2Fp.MarkServerSettingsSent()
+	}
 
 	// Send SETTINGS ACK for client's SETTINGS
 	settingsAck := buildSettingsAckFrame()
 	conn.Write(settingsAck)
 
+	// Some real-world servers follow their SETTINGS with an immediate
+	// connection-level WINDOW_UPDATE; mimic that only if profile asks for it.
+	if profile.WindowUpdateIncrement > 0 {
+		conn.Write(buildWindowUpdateFrame(0, profile.WindowUpdateIncrement))
+	}
+
 	// Read more data (HEADERS frame with actual request)
 	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
 
@@ -367,22 +489,39 @@ func handleHTTP2(conn net.Conn, remoteAddr string, combined *CombinedFingerprint
 		n2, err := conn.Read(buf)
 		if err == nil && n2 > 0 {
 			headerData = buf[:n2]
+			if http2Fp != nil {
+				// This read is where a client's SETTINGS-ACK/PING for our
+				// SETTINGS actually shows up - feed it back into the
+				// fingerprint's BehaviorProfile instead of only using it to
+				// build the HTTP response.
+				http2Fp.IngestMore(headerData)
+			}
 		}
 	}
 
 	// Find and respond to HEADERS frame
-	respondToHTTP2Request(conn, headerData, combined, remoteAddr)
+	streamID := respondToHTTP2Request(conn, headerData, combined, remoteAddr)
+
+	// In -enable-http2-probe mode, follow the real response with a curated
+	// set of non-conformant frames and fold the client's reactions into the
+	// stored fingerprint (see http2interop.go).
+	if probeMode && combined.HTTP2 != nil {
+		sig := RunHTTP2InteropProbes(conn, streamID)
+		combined.HTTP2.ApplyInteropSignature(sig)
+		log.Printf("HTTP/2 interop probe for %s: %+v", remoteAddr, sig.Reactions)
+	}
 }
 
-// buildServerSettingsFrame creates a SETTINGS frame for server
-func buildServerSettingsFrame() []byte {
-	// SETTINGS frame with some default values
+// buildServerSettingsFrame creates a SETTINGS frame for server, emitting
+// profile.HTTP2Settings verbatim and in order so a ServerProfile can mimic a
+// specific target's SETTINGS fingerprint rather than this server's own.
+func buildServerSettingsFrame(profile *ServerProfile) []byte {
 	// Format: Length(3) + Type(1) + Flags(1) + StreamID(4) + Payload
-	settings := []byte{
-		// SETTINGS_MAX_CONCURRENT_STREAMS = 100
-		0x00, 0x03, 0x00, 0x00, 0x00, 0x64,
-		// SETTINGS_INITIAL_WINDOW_SIZE = 65535
-		0x00, 0x04, 0x00, 0x00, 0xff, 0xff,
+	settings := make([]byte, 0, 6*len(profile.HTTP2Settings))
+	for _, param := range profile.HTTP2Settings {
+		settings = append(settings,
+			byte(param.ID>>8), byte(param.ID),
+			byte(param.Value>>24), byte(param.Value>>16), byte(param.Value>>8), byte(param.Value))
 	}
 
 	frame := make([]byte, 9+len(settings))
@@ -415,6 +554,17 @@ func buildSettingsAckFrame() []byte {
 	}
 }
 
+// buildWindowUpdateFrame creates a WINDOW_UPDATE frame for streamID with the
+// given window size increment.
+func buildWindowUpdateFrame(streamID uint32, increment uint32) []byte {
+	frame := make([]byte, 9+4)
+	frame[2] = 4 // Length = 4
+	frame[3] = FrameWindowUpdate
+	binary.BigEndian.PutUint32(frame[5:9], streamID)
+	binary.BigEndian.PutUint32(frame[9:13], increment&0x7fffffff)
+	return frame
+}
+
 // containsHeadersFrame checks if data contains a HEADERS frame
 func containsHeadersFrame(data []byte) bool {
 	pos := 0
@@ -434,12 +584,15 @@ func containsHeadersFrame(data []byte) bool {
 	return false
 }
 
-// respondToHTTP2Request sends an HTTP/2 response
-func respondToHTTP2Request(conn net.Conn, data []byte, combined *CombinedFingerprint, remoteAddr string) {
+// respondToHTTP2Request sends an HTTP/2 response and returns the stream ID it
+// answered on, so callers in -enable-http2-probe mode know which stream is
+// now closed (see RunHTTP2InteropProbes).
+func respondToHTTP2Request(conn net.Conn, data []byte, combined *CombinedFingerprint, remoteAddr string) uint32 {
 	// Find the stream ID and path from HEADERS frame
 	streamID := uint32(1) // Default to stream 1
 	path := "/"
 	userAgent := ""
+	var chHeaders map[string]string
 
 	pos := 0
 	for pos+9 <= len(data) {
@@ -469,7 +622,7 @@ func respondToHTTP2Request(conn net.Conn, data []byte, combined *CombinedFingerp
 				// Extract actual HPACK data
 				if payloadOffset < len(headerPayload)-padLen {
 					hpackData := headerPayload[payloadOffset : len(headerPayload)-padLen]
-					path, userAgent = extractHTTP2Path(hpackData)
+					path, userAgent, chHeaders = extractHTTP2Path(hpackData)
 				}
 			}
 			break
@@ -487,7 +640,7 @@ func respondToHTTP2Request(conn net.Conn, data []byte, combined *CombinedFingerp
 	if strings.Contains(path, "/api/analysis") {
 		// Return analysis (简化格式)
 		host, _, _ := net.SplitHostPort(remoteAddr)
-		analysis := AnalyzeFingerprint(combined, host, userAgent)
+		analysis := AnalyzeFingerprint(combined, host, userAgent, chHeaders)
 		includeDetails := strings.Contains(path, "details=true")
 		simpleResult := BuildSimpleResult(analysis, includeDetails)
 		jsonBody, _ = json.MarshalIndent(simpleResult, "", "  ")
@@ -507,30 +660,40 @@ func respondToHTTP2Request(conn net.Conn, data []byte, combined *CombinedFingerp
 	// Send DATA frame with response body
 	dataFrame := buildHTTP2DataFrame(streamID, jsonBody)
 	conn.Write(dataFrame)
+
+	return streamID
 }
 
-// extractHTTP2Path uses proper HPACK decoding to extract the :path header
-func extractHTTP2Path(headerPayload []byte) (string, string) {
+// extractHTTP2Path uses proper HPACK decoding to extract the :path and
+// user-agent headers, plus any Sec-CH-UA* Client Hints headers (returned as a
+// nil map if none were sent).
+func extractHTTP2Path(headerPayload []byte) (string, string, map[string]string) {
 	path := "/"
 	userAgent := ""
+	var chHeaders map[string]string
 
 	// Use proper HPACK decoder
 	decoder := hpack.NewDecoder(4096, nil)
 	headers, err := decoder.DecodeFull(headerPayload)
 	if err != nil {
-		return path, userAgent
+		return path, userAgent, chHeaders
 	}
 
 	for _, hf := range headers {
-		switch hf.Name {
-		case ":path":
+		switch {
+		case hf.Name == ":path":
 			path = hf.Value
-		case "user-agent":
+		case hf.Name == "user-agent":
 			userAgent = hf.Value
+		case strings.HasPrefix(hf.Name, "sec-ch-ua"), hf.Name == "referer", hf.Name == "origin":
+			if chHeaders == nil {
+				chHeaders = make(map[string]string)
+			}
+			chHeaders[hf.Name] = hf.Value
 		}
 	}
 
-	return path, userAgent
+	return path, userAgent, chHeaders
 }
 
 // buildHTTP2HeadersFrame builds a HEADERS frame with status 200
@@ -727,9 +890,12 @@ func parseClientHello(data []byte) (*TLSFingerprint, error) {
 			}
 			extData := clientHello[pos : pos+extLen]
 
+			extEntry := getExtensionName(extType)
 			extInfo := ExtensionInfo{
-				ID:   extType,
-				Name: getExtensionName(extType),
+				ID:          extType,
+				Name:        extEntry.Name,
+				Recommended: extEntry.Recommended,
+				Reference:   extEntry.Reference,
 			}
 
 			// Parse specific extensions
@@ -784,6 +950,37 @@ func parseClientHello(data []byte) (*TLSFingerprint, error) {
 					}
 					extInfo.Data = fp.ALPN
 				}
+			case 27: // compress_certificate (RFC 8879)
+				if len(extData) >= 1 {
+					algsLen := int(extData[0])
+					var names []string
+					for i := 1; i < 1+algsLen && i+1 < len(extData); i += 2 {
+						alg := binary.BigEndian.Uint16(extData[i : i+2])
+						fp.CertCompressionAlgs = append(fp.CertCompressionAlgs, alg)
+						names = append(names, getCertCompressionName(alg))
+					}
+					extInfo.Data = names
+				}
+			case 0x39: // quic_transport_parameters (QUIC/HTTP3 clients only)
+				extInfo.Data = hex.EncodeToString(extData)
+			case 51: // key_share
+				if len(extData) >= 2 {
+					listLen := int(binary.BigEndian.Uint16(extData[0:2]))
+					i := 2
+					for i+4 <= 2+listLen && i+4 <= len(extData) {
+						group := binary.BigEndian.Uint16(extData[i : i+2])
+						keyLen := int(binary.BigEndian.Uint16(extData[i+2 : i+4]))
+						i += 4
+						if i+keyLen > len(extData) {
+							break
+						}
+						if fp.ClientKeyShares == nil {
+							fp.ClientKeyShares = make(map[string]string)
+						}
+						fp.ClientKeyShares[fmt.Sprintf("0x%04x", group)] = hex.EncodeToString(extData[i : i+keyLen])
+						i += keyLen
+					}
+				}
 			case 43: // supported_versions
 				if len(extData) >= 1 {
 					versionsLen := int(extData[0])
@@ -821,6 +1018,21 @@ func parseClientHello(data []byte) (*TLSFingerprint, error) {
 	hash := md5.Sum([]byte(fp.JA3))
 	fp.JA3Hash = hex.EncodeToString(hash[:])
 
+	// Build JA3N (extensions sorted)
+	sortedExtensions := make([]uint16, len(extensions))
+	copy(sortedExtensions, extensions)
+	sort.Slice(sortedExtensions, func(i, j int) bool { return sortedExtensions[i] < sortedExtensions[j] })
+	ja3nParts := []string{
+		fmt.Sprintf("%d", clientVersion),
+		joinUint16(cipherSuites, "-"),
+		joinUint16(sortedExtensions, "-"),
+		joinUint16(supportedGroups, "-"),
+		joinUint8(ecPointFormats, "-"),
+	}
+	fp.JA3N = strings.Join(ja3nParts, ",")
+	ja3nHash := md5.Sum([]byte(fp.JA3N))
+	fp.JA3NHash = hex.EncodeToString(ja3nHash[:])
+
 	// Build JA4
 	fp.JA4 = buildJA4(clientVersion, supportedVersions, fp.SNI, cipherSuites, extensions, fp.ALPN, signatureAlgorithms)
 
@@ -832,6 +1044,8 @@ func parseClientHello(data []byte) (*TLSFingerprint, error) {
 		fp.TLSVersionNegotiated = getVersionName(supportedVersions[0])
 	}
 
+	fp.PQSupport = detectPQSupport(fp.SupportedGroups, fp.ClientKeyShares)
+
 	return fp, nil
 }
 
@@ -987,6 +1201,7 @@ func handleHTTP(conn net.Conn, remoteAddr string) {
 		return
 	}
 
+	method := parts[0]
 	fullPath := parts[1]
 	path := fullPath
 	if idx := strings.Index(path, "?"); idx != -1 {
@@ -995,13 +1210,46 @@ func handleHTTP(conn net.Conn, remoteAddr string) {
 
 	// Extract User-Agent header
 	userAgent := ""
+	acceptLanguage := ""
+	chHeaders := make(map[string]string)
+	var headerNames []string
+	var cookieNames []string
 	for _, line := range lines {
-		if strings.HasPrefix(strings.ToLower(line), "user-agent:") {
-			userAgent = strings.TrimSpace(line[11:])
-			break
+		if line == "" {
+			break // end of headers, don't go parsing the body as more headers
+		}
+		lower := strings.ToLower(line)
+		switch {
+		case strings.HasPrefix(lower, "user-agent:"):
+			userAgent = strings.TrimSpace(line[len("user-agent:"):])
+		case strings.HasPrefix(lower, "accept-language:"):
+			acceptLanguage = strings.TrimSpace(line[len("accept-language:"):])
+		case strings.HasPrefix(lower, "sec-ch-ua"), strings.HasPrefix(lower, "referer:"), strings.HasPrefix(lower, "origin:"):
+			if colonIdx := strings.Index(line, ":"); colonIdx != -1 {
+				chHeaders[strings.TrimSpace(line[:colonIdx])] = strings.TrimSpace(line[colonIdx+1:])
+			}
+		}
+
+		if colonIdx := strings.Index(line, ":"); colonIdx != -1 {
+			name := strings.TrimSpace(line[:colonIdx])
+			headerNames = append(headerNames, name)
+			if strings.EqualFold(name, "cookie") {
+				for _, pair := range strings.Split(line[colonIdx+1:], ";") {
+					if eq := strings.Index(pair, "="); eq != -1 {
+						cookieNames = append(cookieNames, strings.TrimSpace(pair[:eq]))
+					}
+				}
+			}
 		}
 	}
 
+	httpVersion := ""
+	if len(parts) >= 3 {
+		httpVersion = parts[2]
+	}
+	ja4h := buildJA4H(method, httpVersion, headerNames, cookieNames, acceptLanguage)
+	recordHTTPFingerprint(remoteAddr, ja4h)
+
 	var response string
 	var body []byte
 
@@ -1031,6 +1279,10 @@ func handleHTTP(conn net.Conn, remoteAddr string) {
         <li><a href="/api/fingerprint">/api/fingerprint</a> - Get your raw TLS/HTTP2/TCP fingerprint</li>
         <li><a href="/api/analysis">/api/analysis</a> - <strong>Get full security analysis with conclusions</strong></li>
         <li><a href="/api/all">/api/all</a> - Get all stored fingerprints</li>
+        <li><a href="/api/decode?hello=">/api/decode?hello=&lt;hex&gt;</a> - Decode a hex-encoded ClientHello (GET query or POST form) without a live connection</li>
+        <li>/api/probe?target=host:port&amp;ip=&lt;stored client ip&gt; (or &amp;ja3=&lt;hash&gt;)&amp;token=&lt;admin token&gt; - Replay a previously-captured fingerprint's ClientHello against target and report its JA4S/JA4X (requires -admin-token; rejects loopback/private/link-local targets)</li>
+        <li>/api/policy - GET the active browser/OS version security policy (no auth); POST ?token=&lt;admin token&gt; with a VersionPolicy JSON body to replace it, or POST ?token=&lt;admin token&gt;&amp;reload=true to re-read it from -version-policy</li>
+        <li>/admin/reload?token=&lt;admin token&gt; - Force an immediate re-read of the JA3/HTTP2/JA4/corpus JSON files, without waiting for the stat-poll or fsnotify hot reload (requires -admin-token)</li>
     </ul>
     <h2>Security Analysis</h2>
     <p>The <code>/api/analysis</code> endpoint provides:</p>
@@ -1057,7 +1309,7 @@ func handleHTTP(conn net.Conn, remoteAddr string) {
 		includeDetails := strings.Contains(fullPath, "details=true")
 
 		if fp != nil {
-			analysis := AnalyzeFingerprint(fp, host, userAgent)
+			analysis := AnalyzeFingerprint(fp, host, userAgent, chHeaders)
 			simpleResult := BuildSimpleResult(analysis, includeDetails)
 			body, _ = json.MarshalIndent(simpleResult, "", "  ")
 		} else {
@@ -1104,6 +1356,84 @@ func handleHTTP(conn net.Conn, remoteAddr string) {
 		storeMutex.RUnlock()
 		response = fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: application/json; charset=utf-8\r\nContent-Length: %d\r\nAccess-Control-Allow-Origin: *\r\n\r\n", len(body))
 
+	case path == "/api/decode":
+		helloParam := requestParam(method, fullPath, request, "hello")
+		result := DecodeClientHelloHex(helloParam, userAgent, chHeaders)
+		body, _ = json.MarshalIndent(result, "", "  ")
+		response = fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: application/json; charset=utf-8\r\nContent-Length: %d\r\nAccess-Control-Allow-Origin: *\r\n\r\n", len(body))
+
+	case path == "/api/probe":
+		var result interface{}
+		if !isAuthorizedAdmin(method, fullPath, request) {
+			result = map[string]interface{}{"error": "unauthorized: missing or invalid token"}
+			body, _ = json.MarshalIndent(result, "", "  ")
+			response = fmt.Sprintf("HTTP/1.1 403 Forbidden\r\nContent-Type: application/json; charset=utf-8\r\nContent-Length: %d\r\nAccess-Control-Allow-Origin: *\r\n\r\n", len(body))
+			break
+		}
+
+		target := requestParam(method, fullPath, request, "target")
+		ipParam := requestParam(method, fullPath, request, "ip")
+		ja3Param := requestParam(method, fullPath, request, "ja3")
+
+		if target == "" {
+			result = map[string]interface{}{"error": "missing target=host:port"}
+		} else if fp := lookupStoredTLSFingerprint(ipParam, ja3Param); fp != nil {
+			result = ReplayFingerprint(target, fp)
+		} else {
+			result = map[string]interface{}{"error": "no stored fingerprint found for that ip/ja3"}
+		}
+		body, _ = json.MarshalIndent(result, "", "  ")
+		response = fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: application/json; charset=utf-8\r\nContent-Length: %d\r\nAccess-Control-Allow-Origin: *\r\n\r\n", len(body))
+
+	case path == "/api/policy":
+		if method == "POST" && !isAuthorizedAdmin(method, fullPath, request) {
+			body, _ = json.MarshalIndent(map[string]interface{}{"error": "unauthorized: missing or invalid token"}, "", "  ")
+			response = fmt.Sprintf("HTTP/1.1 403 Forbidden\r\nContent-Type: application/json; charset=utf-8\r\nContent-Length: %d\r\nAccess-Control-Allow-Origin: *\r\n\r\n", len(body))
+			break
+		}
+
+		var result interface{}
+		switch {
+		case method == "POST" && requestParam(method, fullPath, request, "reload") != "":
+			if reloaded, err := ReloadVersionPolicyFromDisk(); err != nil {
+				result = map[string]interface{}{"error": err.Error()}
+			} else {
+				result = reloaded
+			}
+		case method == "POST":
+			bodyText := ""
+			if idx := strings.Index(request, "\r\n\r\n"); idx != -1 {
+				bodyText = strings.TrimSpace(request[idx+4:])
+			}
+			var p VersionPolicy
+			if err := json.Unmarshal([]byte(bodyText), &p); err != nil {
+				result = map[string]interface{}{"error": fmt.Sprintf("invalid version policy JSON: %v", err)}
+			} else {
+				SetVersionPolicy(&p, "")
+				result = &p
+			}
+		default:
+			result = GetVersionPolicy()
+		}
+		body, _ = json.MarshalIndent(result, "", "  ")
+		response = fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: application/json; charset=utf-8\r\nContent-Length: %d\r\nAccess-Control-Allow-Origin: *\r\n\r\n", len(body))
+
+	case path == "/admin/reload":
+		if !isAuthorizedAdmin(method, fullPath, request) {
+			body, _ = json.MarshalIndent(map[string]interface{}{"error": "unauthorized: missing or invalid token"}, "", "  ")
+			response = fmt.Sprintf("HTTP/1.1 403 Forbidden\r\nContent-Type: application/json; charset=utf-8\r\nContent-Length: %d\r\nAccess-Control-Allow-Origin: *\r\n\r\n", len(body))
+			break
+		}
+
+		var result interface{}
+		if err := GetDatabase().Reload(); err != nil {
+			result = map[string]interface{}{"success": false, "error": err.Error()}
+		} else {
+			result = map[string]interface{}{"success": true}
+		}
+		body, _ = json.MarshalIndent(result, "", "  ")
+		response = fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: application/json; charset=utf-8\r\nContent-Length: %d\r\nAccess-Control-Allow-Origin: *\r\n\r\n", len(body))
+
 	default:
 		body = []byte("Not Found")
 		response = fmt.Sprintf("HTTP/1.1 404 Not Found\r\nContent-Type: text/plain\r\nContent-Length: %d\r\nAccess-Control-Allow-Origin: *\r\n\r\n", len(body))
@@ -1113,44 +1443,91 @@ func handleHTTP(conn net.Conn, remoteAddr string) {
 	conn.Write(body)
 }
 
-// Helper functions
-func isGREASE(val uint16) bool {
-	// GREASE values: 0x0a0a, 0x1a1a, 0x2a2a, etc.
-	return (val&0x0f0f) == 0x0a0a
-}
+// recordHTTPFingerprint attaches a JA4H result to whichever CombinedFingerprint
+// this connection's TLS/TCP fingerprints were already stored under, the same
+// way /api/analysis and /api/fingerprint look fingerprints back up: by full
+// remoteAddr first, falling back to the bare host (no TLS fingerprint means
+// there's nothing to attach to, e.g. a plaintext health check).
+func recordHTTPFingerprint(remoteAddr, ja4h string) {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
 
-func getCipherName(cs uint16) string {
-	if isGREASE(cs) {
-		return fmt.Sprintf("TLS_GREASE (0x%04X)", cs)
+	fp := fingerprintStore[remoteAddr]
+	if fp == nil {
+		host, _, _ := net.SplitHostPort(remoteAddr)
+		fp = fingerprintStore[host]
 	}
-	if name, ok := cipherNames[cs]; ok {
-		return name
+	if fp != nil {
+		fp.HTTP = &HTTPFingerprint{JA4H: ja4h}
 	}
-	return fmt.Sprintf("0x%04X", cs)
 }
 
-func getExtensionName(ext uint16) string {
-	if isGREASE(ext) {
-		return fmt.Sprintf("TLS_GREASE (0x%04x)", ext)
+// lookupStoredTLSFingerprint resolves /api/probe's ip/ja3 parameters to a
+// previously-captured TLSFingerprint. ip is looked up directly against
+// fingerprintStore; ja3 scans the store for a matching JA3Hash, since a JA3
+// hash on its own can't be reversed back into the cipher/extension lists
+// ReplayFingerprint needs - only a ClientHello this server actually parsed
+// has those. ip takes priority when both are given.
+func lookupStoredTLSFingerprint(ip, ja3Hash string) *TLSFingerprint {
+	storeMutex.RLock()
+	defer storeMutex.RUnlock()
+
+	if ip != "" {
+		if fp := fingerprintStore[ip]; fp != nil && fp.TLS != nil {
+			return fp.TLS
+		}
+		return nil
 	}
-	if name, ok := extensionNames[ext]; ok {
-		return fmt.Sprintf("%s (%d)", name, ext)
+	if ja3Hash != "" {
+		for _, fp := range fingerprintStore {
+			if fp.TLS != nil && fp.TLS.JA3Hash == ja3Hash {
+				return fp.TLS
+			}
+		}
 	}
-	return fmt.Sprintf("unknown (%d)", ext)
+	return nil
 }
 
-func getGroupName(g uint16) string {
-	if isGREASE(g) {
-		return fmt.Sprintf("TLS_GREASE (0x%04X)", g)
+// requestParam reads a named parameter from either the query string (GET
+// /api/decode?hello=...) or an application/x-www-form-urlencoded POST body
+// (POST /api/decode with hello=... in the body), preferring the query string
+// if both are present.
+func requestParam(method, fullPath, rawRequest, name string) string {
+	if idx := strings.Index(fullPath, "?"); idx != -1 {
+		if query, err := url.ParseQuery(fullPath[idx+1:]); err == nil {
+			if v := query.Get(name); v != "" {
+				return v
+			}
+		}
 	}
-	if name, ok := groupNames[g]; ok {
-		return fmt.Sprintf("%s (%d)", name, g)
+
+	if method == "POST" {
+		if idx := strings.Index(rawRequest, "\r\n\r\n"); idx != -1 {
+			if form, err := url.ParseQuery(strings.TrimSpace(rawRequest[idx+4:])); err == nil {
+				return form.Get(name)
+			}
+		}
 	}
-	return fmt.Sprintf("0x%04X", g)
+
+	return ""
+}
+
+// Helper functions
+func isGREASE(val uint16) bool {
+	// GREASE values: 0x0a0a, 0x1a1a, 0x2a2a, etc.
+	return (val&0x0f0f) == 0x0a0a
+}
+
+// certCompressionNames maps CertificateCompressionAlgorithm values (RFC 8879
+// section 4) to their registered names.
+var certCompressionNames = map[uint16]string{
+	1: "zlib",
+	2: "brotli",
+	3: "zstd",
 }
 
-func getSigAlgName(alg uint16) string {
-	if name, ok := sigAlgNames[alg]; ok {
+func getCertCompressionName(alg uint16) string {
+	if name, ok := certCompressionNames[alg]; ok {
 		return name
 	}
 	return fmt.Sprintf("0x%04x", alg)
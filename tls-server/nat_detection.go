@@ -0,0 +1,345 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// tsSample is a single observed TCP timestamp reading
+type tsSample struct {
+	observedAt time.Time
+	tsval      uint32
+}
+
+const tsHistoryCapacity = 32 // ring buffer size per source IP
+
+// tsHistory is a fixed-size ring buffer of recent TSval samples for one source IP
+type tsHistory struct {
+	samples []tsSample // append-only until capacity, then slides
+}
+
+func (h *tsHistory) add(s tsSample) {
+	h.samples = append(h.samples, s)
+	if len(h.samples) > tsHistoryCapacity {
+		h.samples = h.samples[len(h.samples)-tsHistoryCapacity:]
+	}
+}
+
+// NATAnalysis reports clock-skew-based NAT/multi-host detection for a source IP's
+// TCP timestamp stream.
+type NATAnalysis struct {
+	NATSuspected     bool    `json:"nat_suspected"`
+	EstimatedHosts   int     `json:"estimated_hosts,omitempty"`
+	ClockRateHz      float64 `json:"clock_rate_hz,omitempty"`
+	OffsetSpreadSecs float64 `json:"offset_spread_secs,omitempty"`
+	TimestampReset   bool    `json:"timestamp_reset,omitempty"`
+}
+
+// tsHistoryCacheCapacity / tsHistoryCacheMaxAge bound the TTL+LRU cache of per-source-IP
+// timestamp histories, giving it the same eviction treatment TCPFingerprintCache (cache.go)
+// gives the main TCP/IP fingerprint store - without it, every distinct client IP that ever
+// completes a handshake adds a permanent entry that's never reclaimed.
+const (
+	tsHistoryCacheCapacity = 10000
+	tsHistoryCacheMaxAge   = 30 * time.Minute
+)
+
+// tsHistoryCacheEntry is one source IP's ring buffer plus its LRU/TTL bookkeeping.
+type tsHistoryCacheEntry struct {
+	key      string
+	hist     *tsHistory
+	lastSeen time.Time
+}
+
+// tsHistoryCache is a thread-safe TTL+LRU cache of tsHistory values keyed by source IP,
+// structured like TCPFingerprintCache in cache.go: LRU eviction inline on every getOrCreate
+// once over capacity, age-based eviction on a periodic SweepExpired sweep.
+type tsHistoryCache struct {
+	mu        sync.Mutex
+	capacity  int
+	maxAge    time.Duration
+	entries   map[string]*list.Element
+	order     *list.List
+	evictions int64
+}
+
+// newTSHistoryCache creates a cache with the given LRU capacity and max entry age.
+func newTSHistoryCache(capacity int, maxAge time.Duration) *tsHistoryCache {
+	return &tsHistoryCache{
+		capacity: capacity,
+		maxAge:   maxAge,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// getOrCreate returns key's tsHistory, creating one if absent, and moves it to the front
+// of the LRU order. Evicts the least-recently-used entry if over capacity.
+func (c *tsHistoryCache) getOrCreate(key string) *tsHistory {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*tsHistoryCacheEntry)
+		entry.lastSeen = now
+		c.order.MoveToFront(el)
+		return entry.hist
+	}
+
+	entry := &tsHistoryCacheEntry{key: key, hist: &tsHistory{}, lastSeen: now}
+	el := c.order.PushFront(entry)
+	c.entries[key] = el
+
+	if c.capacity > 0 && len(c.entries) > c.capacity {
+		c.evictLRULocked()
+	}
+	return entry.hist
+}
+
+// evictLRULocked removes the least-recently-used entry. Caller must hold c.mu.
+func (c *tsHistoryCache) evictLRULocked() {
+	el := c.order.Back()
+	if el == nil {
+		return
+	}
+	entry := el.Value.(*tsHistoryCacheEntry)
+	c.order.Remove(el)
+	delete(c.entries, entry.key)
+	c.evictions++
+}
+
+// SweepExpired removes entries whose lastSeen is older than maxAge. Intended to run
+// periodically from a ticker goroutine, same as TCPFingerprintCache.SweepExpired.
+func (c *tsHistoryCache) SweepExpired() {
+	if c.maxAge <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cutoff := time.Now().Add(-c.maxAge)
+	var next *list.Element
+	for el := c.order.Back(); el != nil; el = next {
+		next = el.Prev()
+		entry := el.Value.(*tsHistoryCacheEntry)
+		if entry.lastSeen.Before(cutoff) {
+			c.order.Remove(el)
+			delete(c.entries, entry.key)
+			c.evictions++
+		}
+	}
+}
+
+// Size returns the number of source IPs currently tracked.
+func (c *tsHistoryCache) Size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Evictions returns the running count of entries evicted by LRU or TTL.
+func (c *tsHistoryCache) Evictions() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.evictions
+}
+
+var tsHistoryStore = newTSHistoryCache(tsHistoryCacheCapacity, tsHistoryCacheMaxAge)
+
+// CleanupTSHistory periodically sweeps entries older than maxAge from tsHistoryStore,
+// mirroring CleanupOldFingerprints in tcp.go for the main TCP/IP fingerprint cache.
+func CleanupTSHistory(maxAge time.Duration) {
+	ticker := time.NewTicker(maxAge / 2)
+	go func() {
+		for range ticker.C {
+			before := tsHistoryStore.Evictions()
+			tsHistoryStore.SweepExpired()
+			after := tsHistoryStore.Evictions()
+			if after > before {
+				log.Printf("[NAT] Swept %d expired timestamp-history entr(ies), cache size now %d",
+					after-before, tsHistoryStore.Size())
+			}
+		}
+	}()
+}
+
+// analyzeTSvalForNAT records a new TSval observation for srcIP and returns a NAT
+// analysis based on the clock-skew (slope + offset) of the accumulated history.
+func analyzeTSvalForNAT(srcIP string, tsval uint32, now time.Time) *NATAnalysis {
+	h := tsHistoryStore.getOrCreate(srcIP)
+
+	result := &NATAnalysis{}
+
+	if len(h.samples) > 0 {
+		last := h.samples[len(h.samples)-1]
+		// TSval should be monotonically non-decreasing (mod 2^32 wraparound aside).
+		// A backward jump without a long enough gap to explain a reboot is suspicious.
+		if tsval < last.tsval && now.Sub(last.observedAt) < 24*time.Hour {
+			result.TimestampReset = true
+		}
+	}
+
+	h.add(tsSample{observedAt: now, tsval: tsval})
+
+	if len(h.samples) < 6 {
+		// Not enough data yet to fit a reliable regression/clustering
+		return result
+	}
+
+	rate, offsets := fitClockRate(h.samples)
+	result.ClockRateHz = rate
+
+	clusters := clusterOffsets(offsets, 2)
+	if len(clusters) >= 2 {
+		spread := clusters[len(clusters)-1].center - clusters[0].center
+		result.OffsetSpreadSecs = spread
+		// A multi-second divergence in extrapolated t=0 offset between two clean
+		// clusters of samples is a strong sign that distinct devices (behind the
+		// same source IP, i.e. NAT) are contributing timestamps.
+		if spread > 2.0 && clusters[0].size >= 2 && clusters[1].size >= 2 {
+			result.NATSuspected = true
+			result.EstimatedHosts = len(clusters)
+		}
+	}
+
+	return result
+}
+
+// fitClockRate performs a simple linear regression of tsval against elapsed wall-clock
+// seconds (least squares), returning the fitted rate (Hz) and the per-sample residual
+// offsets (extrapolated t=0 intercept in seconds) used for clustering.
+func fitClockRate(samples []tsSample) (rateHz float64, offsets []float64) {
+	t0 := samples[0].observedAt
+	n := float64(len(samples))
+
+	var sumX, sumY, sumXY, sumXX float64
+	xs := make([]float64, len(samples))
+	for i, s := range samples {
+		x := s.observedAt.Sub(t0).Seconds()
+		y := float64(s.tsval)
+		xs[i] = x
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0, nil
+	}
+	slope := (n*sumXY - sumX*sumY) / denom
+	intercept := (sumY - slope*sumX) / n
+
+	offsets = make([]float64, len(samples))
+	for i, s := range samples {
+		// Offset of this sample's extrapolated t=0 tsval vs the fitted intercept,
+		// expressed in seconds assuming the fitted clock rate.
+		predicted := intercept + slope*xs[i]
+		residualTicks := float64(s.tsval) - predicted
+		if slope != 0 {
+			offsets[i] = residualTicks / slope
+		}
+	}
+
+	return slope, offsets
+}
+
+type offsetCluster struct {
+	center float64
+	size   int
+}
+
+// clusterOffsets runs a small, dependency-free 1-D k-means (k up to `k`) over offsets
+// and returns clusters sorted by center, merging clusters that end up empty or that
+// fail to visibly separate (poor-man's silhouette check via a minimum gap).
+func clusterOffsets(offsets []float64, k int) []offsetCluster {
+	if len(offsets) == 0 {
+		return nil
+	}
+	sorted := append([]float64(nil), offsets...)
+	sort.Float64s(sorted)
+
+	if k > len(sorted) {
+		k = len(sorted)
+	}
+	// Seed centers evenly across the sorted range
+	centers := make([]float64, k)
+	for i := range centers {
+		idx := i * (len(sorted) - 1) / max(k-1, 1)
+		centers[i] = sorted[idx]
+	}
+
+	var assign []int
+	for iter := 0; iter < 10; iter++ {
+		assign = make([]int, len(sorted))
+		sums := make([]float64, k)
+		counts := make([]int, k)
+		for i, v := range sorted {
+			best, bestDist := 0, abs(v-centers[0])
+			for c := 1; c < k; c++ {
+				if d := abs(v - centers[c]); d < bestDist {
+					best, bestDist = c, d
+				}
+			}
+			assign[i] = best
+			sums[best] += v
+			counts[best]++
+		}
+		for c := 0; c < k; c++ {
+			if counts[c] > 0 {
+				centers[c] = sums[c] / float64(counts[c])
+			}
+		}
+	}
+
+	clusters := make([]offsetCluster, 0, k)
+	for c := 0; c < k; c++ {
+		size := 0
+		for _, a := range assign {
+			if a == c {
+				size++
+			}
+		}
+		if size > 0 {
+			clusters = append(clusters, offsetCluster{center: centers[c], size: size})
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].center < clusters[j].center })
+	return clusters
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func abs(f float64) float64 {
+	if f < 0 {
+		return -f
+	}
+	return f
+}
+
+// natAnomalyStrings renders a NATAnalysis into CheckConsistency-style anomaly strings
+func natAnomalyStrings(n *NATAnalysis) []string {
+	if n == nil {
+		return nil
+	}
+	var out []string
+	if n.NATSuspected {
+		out = append(out, fmt.Sprintf("NAT_SUSPECTED: TCP timestamp clock-skew clustering suggests ~%d distinct hosts behind this IP (offset spread %.1fs)",
+			n.EstimatedHosts, n.OffsetSpreadSecs))
+	}
+	if n.TimestampReset {
+		out = append(out, "TIMESTAMP_RESET: TCP timestamp (TSval) jumped backward without a plausible reboot interval")
+	}
+	return out
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// P0fSignature represents a single parsed p0f.fp record:
+//   wwww:ttt:D:ss:OOO:QQ:OS:Details
+// window size : initial TTL : DF bit : overall SYN size : options list : quirks : OS label : details
+type P0fSignature struct {
+	Window      string // raw window field, may be "*", "S4", "S44", or a number
+	TTL         string // raw ttl field, may be "*", "64", "64-", "64+10"
+	DF          bool
+	OverallSize int // 0 means "*" (any)
+	Options     []string
+	Quirks      []string
+	OS          string
+	Details     string
+}
+
+// P0fDatabase holds loaded p0f-style SYN signatures
+type P0fDatabase struct {
+	mu   sync.RWMutex
+	sigs []P0fSignature
+}
+
+var p0fDB = &P0fDatabase{}
+
+// LoadP0fDatabase parses a p0f.fp style file and (re)loads it into the global database.
+// The file format groups records under `[module:direction]` and `label = ...` / `sig = ...`
+// lines in the real p0f project; this loader accepts the simplified flat record shape
+// described in the module header comment, one signature per non-comment line.
+func LoadP0fDatabase(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open p0f database: %w", err)
+	}
+	defer f.Close()
+
+	var sigs []P0fSignature
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		sig, err := parseP0fLine(line)
+		if err != nil {
+			continue // skip malformed lines, keep loading the rest
+		}
+		sigs = append(sigs, sig)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read p0f database: %w", err)
+	}
+
+	p0fDB.mu.Lock()
+	p0fDB.sigs = sigs
+	p0fDB.mu.Unlock()
+	return nil
+}
+
+// parseP0fLine parses a single "wwww:ttt:D:ss:OOO:QQ:OS:Details" record
+func parseP0fLine(line string) (P0fSignature, error) {
+	parts := strings.SplitN(line, ":", 8)
+	if len(parts) != 8 {
+		return P0fSignature{}, fmt.Errorf("expected 8 fields, got %d", len(parts))
+	}
+
+	sig := P0fSignature{
+		Window: parts[0],
+		TTL:    parts[1],
+		DF:     parts[2] == "1",
+		OS:     parts[6],
+		Details: parts[7],
+	}
+
+	if parts[3] != "*" {
+		if n, err := strconv.Atoi(parts[3]); err == nil {
+			sig.OverallSize = n
+		}
+	}
+	if parts[4] != "*" && parts[4] != "" {
+		sig.Options = strings.Split(parts[4], ",")
+	}
+	if parts[5] != "*" && parts[5] != "" {
+		sig.Quirks = strings.Split(parts[5], ",")
+	}
+
+	return sig, nil
+}
+
+// MatchSignature scores fp against every loaded p0f signature and returns the
+// best match. confidence is one of "high", "medium", "low", or "" if nothing matched.
+func MatchSignature(fp *TCPIPFingerprint) (label, details, confidence string) {
+	p0fDB.mu.RLock()
+	defer p0fDB.mu.RUnlock()
+
+	if len(p0fDB.sigs) == 0 {
+		return "", "", ""
+	}
+
+	var best P0fSignature
+	bestScore := -1
+	for _, sig := range p0fDB.sigs {
+		score := scoreSignature(sig, fp)
+		if score > bestScore {
+			bestScore = score
+			best = sig
+		}
+	}
+
+	switch {
+	case bestScore >= 4:
+		confidence = "high"
+	case bestScore >= 2:
+		confidence = "medium"
+	case bestScore >= 1:
+		confidence = "low"
+	default:
+		return "", "", ""
+	}
+
+	return best.OS, best.Details, confidence
+}
+
+// scoreSignature scores how well sig matches fp. Higher is better; 0 means no match.
+// Scoring weights follow the request: exact option order + MSS + wscale + DF + window
+// class is "high" (4+), option-order-only is "medium" (2-3), TTL-class-only is "low" (1).
+func scoreSignature(sig P0fSignature, fp *TCPIPFingerprint) int {
+	if !matchesTTL(sig.TTL, fp.InitialTTL) {
+		return 0
+	}
+	score := 1 // TTL class matched
+
+	if matchesWindow(sig.Window, fp) {
+		score++
+	}
+	if matchesDF(sig.DF, fp.IPFlags) {
+		score++
+	}
+	if matchesOptions(sig.Options, fp.Options) {
+		score += 2 // option order is the strongest discriminator
+	}
+
+	return score
+}
+
+// matchesTTL supports "*", exact numbers, "64-" (<=64) and "64+10" (initial guess ranges)
+func matchesTTL(spec string, observed int) bool {
+	if spec == "*" {
+		return true
+	}
+	if strings.HasSuffix(spec, "-") {
+		n, err := strconv.Atoi(strings.TrimSuffix(spec, "-"))
+		return err == nil && observed <= n
+	}
+	if idx := strings.Index(spec, "+"); idx != -1 {
+		base, err1 := strconv.Atoi(spec[:idx])
+		span, err2 := strconv.Atoi(spec[idx+1:])
+		return err1 == nil && err2 == nil && observed >= base && observed <= base+span
+	}
+	n, err := strconv.Atoi(spec)
+	return err == nil && n == observed
+}
+
+// matchesWindow supports "*", raw numbers, and "Sxx" (window is a multiple of MSS*xx)
+func matchesWindow(spec string, fp *TCPIPFingerprint) bool {
+	if spec == "*" {
+		return true
+	}
+	if strings.HasPrefix(spec, "S") {
+		mult, err := strconv.Atoi(strings.TrimPrefix(spec, "S"))
+		if err != nil || fp.MSS == 0 {
+			return false
+		}
+		return fp.WindowSize == fp.MSS*mult
+	}
+	n, err := strconv.Atoi(spec)
+	return err == nil && n == fp.WindowSize
+}
+
+func matchesDF(specDF bool, ipFlags string) bool {
+	hasDF := strings.Contains(ipFlags, "DF")
+	return specDF == hasDF
+}
+
+// matchesOptions compares the ordered option-kind list ignoring values
+func matchesOptions(specOpts []string, observed []TCPOption) bool {
+	if len(specOpts) == 0 {
+		return false
+	}
+	if len(specOpts) != len(observed) {
+		return false
+	}
+	for i, opt := range specOpts {
+		if !strings.EqualFold(strings.TrimSpace(opt), observed[i].Name) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// VersionPolicy describes which browser/OS version combinations this
+// deployment considers trustworthy: minimum supported versions, EOL dates,
+// and CVE-tagged unsafe ranges. It drives the outdated-client checks in
+// analyzeVersionPolicy and the CVE-referencing AdviceItems in
+// generateSecurityAdvice. Loaded from JSON via LoadVersionPolicy and
+// hot-reloadable at runtime through the /api/policy admin endpoint
+// (see handleHTTP in main.go) without a server restart.
+type VersionPolicy struct {
+	Description string        `json:"description,omitempty"`
+	Rules       []VersionRule `json:"rules"`
+}
+
+// VersionRule matches a parsed UA's Browser/OS/OSVersion - each filter field
+// left "" matches anything - to a minimum safe version plus EOL/CVE
+// metadata. Rules are evaluated in order and the first matching rule decides
+// the client's fate, so curators should list more specific rules (a
+// particular OS/OS version) ahead of general per-browser ones.
+type VersionRule struct {
+	Browser   string `json:"browser,omitempty"`    // e.g. "Chrome"; "" matches any browser
+	OS        string `json:"os,omitempty"`         // e.g. "Windows"; "" matches any OS
+	OSVersion string `json:"os_version,omitempty"` // e.g. "7"; "" matches any OS version
+
+	MinVersion      string `json:"min_version"`                 // versions below this are "outdated"
+	MaxKnownVersion string `json:"max_known_version,omitempty"` // newest version actually shipped; above this is "impossibly new"
+
+	Risk    string   `json:"risk"` // low, medium, high
+	EOLDate string   `json:"eol_date,omitempty"`
+	CVEs    []string `json:"cves,omitempty"`
+	Reason  string   `json:"reason,omitempty"`
+}
+
+// matches reports whether rule applies to the given parsed UA fields.
+func (r VersionRule) matches(browser, os, osVersion string) bool {
+	if r.Browser != "" && !strings.EqualFold(r.Browser, browser) {
+		return false
+	}
+	if r.OS != "" && !strings.Contains(strings.ToLower(os), strings.ToLower(r.OS)) {
+		return false
+	}
+	if r.OSVersion != "" && !strings.EqualFold(r.OSVersion, osVersion) {
+		return false
+	}
+	return true
+}
+
+// VersionFinding is the outcome of a VersionRule violating the client's
+// claimed browser version, as returned by EvaluateVersionPolicy.
+type VersionFinding struct {
+	Rule          VersionRule
+	Reason        string
+	FutureVersion bool // version claims to be newer than MaxKnownVersion - likely spoofed, not merely outdated
+}
+
+// riskScoreDeduction maps a VersionRule's Risk level to the same 0-100
+// ConsistencyAnalysis.Score deduction scale used throughout analysis.go.
+func riskScoreDeduction(risk string) int {
+	switch risk {
+	case "high":
+		return 30
+	case "medium":
+		return 15
+	default:
+		return 5
+	}
+}
+
+var (
+	policyMu   sync.RWMutex
+	policy     *VersionPolicy
+	policyPath string
+)
+
+// DefaultVersionPolicy ships a small built-in baseline so the policy engine
+// is useful even when no -version-policy file is configured. Real deployments
+// are expected to supply their own, kept current with actual release trains.
+func DefaultVersionPolicy() *VersionPolicy {
+	return &VersionPolicy{
+		Description: "Built-in baseline version policy",
+		Rules: []VersionRule{
+			{Browser: "Chrome", OS: "Windows", OSVersion: "7", MinVersion: "999", Risk: "high",
+				Reason: "Windows 7 has been EOL since 2023-01-10 and no longer receives Chrome updates at all",
+				EOLDate: "2023-01-10"},
+			{Browser: "Chrome", MinVersion: "120", MaxKnownVersion: "131", Risk: "high",
+				Reason: "Chrome < 120 predates several actively-exploited V8/sandbox CVE patches",
+				CVEs:   []string{"CVE-2023-6345", "CVE-2023-7024"}},
+			{Browser: "Edge", MinVersion: "120", MaxKnownVersion: "131", Risk: "medium",
+				Reason: "Outdated Edge release (shares Chromium's patch cadence)"},
+			{Browser: "Firefox", MinVersion: "115", MaxKnownVersion: "133", Risk: "medium",
+				Reason: "Outdated Firefox ESR/release train"},
+			{Browser: "Safari", MinVersion: "14", MaxKnownVersion: "18", Risk: "medium",
+				Reason: "Outdated Safari release", CVEs: []string{"CVE-2023-32435"}},
+		},
+	}
+}
+
+// LoadVersionPolicy reads and parses a VersionPolicy JSON file.
+func LoadVersionPolicy(path string) (*VersionPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var p VersionPolicy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[Policy] Loaded version policy %q from %s: %d rules", p.Description, path, len(p.Rules))
+	return &p, nil
+}
+
+// SetVersionPolicy installs p as the active policy. path is remembered so a
+// later /api/policy?reload=true (with no body) re-reads the same file.
+func SetVersionPolicy(p *VersionPolicy, path string) {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	policy = p
+	policyPath = path
+}
+
+// GetVersionPolicy returns the active policy, falling back to the built-in
+// default if none has been set yet (e.g. -version-policy was never passed).
+func GetVersionPolicy() *VersionPolicy {
+	policyMu.RLock()
+	defer policyMu.RUnlock()
+	if policy == nil {
+		return DefaultVersionPolicy()
+	}
+	return policy
+}
+
+// ReloadVersionPolicyFromDisk re-reads the policy file last loaded via
+// LoadVersionPolicy/-version-policy, for the /api/policy?reload=true endpoint.
+// Returns an error if no file-backed policy has ever been loaded.
+func ReloadVersionPolicyFromDisk() (*VersionPolicy, error) {
+	policyMu.RLock()
+	path := policyPath
+	policyMu.RUnlock()
+
+	if path == "" {
+		return nil, fmt.Errorf("no version policy file configured (start with -version-policy or POST a policy body first)")
+	}
+
+	p, err := LoadVersionPolicy(path)
+	if err != nil {
+		return nil, err
+	}
+	SetVersionPolicy(p, path)
+	return p, nil
+}
+
+// EvaluateVersionPolicy finds the first rule matching (browser, os,
+// osVersion) and checks browserVersion against it. Returns (nil, false) when
+// no rule matches, or the matching rule is satisfied (version is current).
+func EvaluateVersionPolicy(p *VersionPolicy, browser, browserVersion, os, osVersion string) (*VersionFinding, bool) {
+	if p == nil || browser == "" || browserVersion == "" {
+		return nil, false
+	}
+
+	for _, r := range p.Rules {
+		if !r.matches(browser, os, osVersion) {
+			continue
+		}
+
+		if r.MaxKnownVersion != "" && compareVersions(browserVersion, r.MaxKnownVersion) > 0 {
+			return &VersionFinding{
+				Rule:          r,
+				Reason:        fmt.Sprintf("%s %s claims a version newer than the newest known release (%s) - likely spoofed", browser, browserVersion, r.MaxKnownVersion),
+				FutureVersion: true,
+			}, true
+		}
+
+		if r.MinVersion != "" && compareVersions(browserVersion, r.MinVersion) < 0 {
+			reason := r.Reason
+			if reason == "" {
+				reason = fmt.Sprintf("%s %s is older than the minimum supported version %s", browser, browserVersion, r.MinVersion)
+			}
+			return &VersionFinding{Rule: r, Reason: reason}, true
+		}
+
+		// First matching rule is satisfied - stop, this client is compliant.
+		return nil, false
+	}
+
+	return nil, false
+}
+
+// compareVersions compares two version strings component-by-component,
+// treating "_" as "." (macOS's "10_15_7" UA style) and stopping at the first
+// non-numeric component (Safari sometimes trails with "17.4 Beta"). Missing
+// trailing components compare as 0, so "12" == "12.0" < "12.1". Returns -1,
+// 0 or 1 like strings.Compare.
+func compareVersions(a, b string) int {
+	pa := versionParts(a)
+	pb := versionParts(b)
+
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var na, nb int
+		if i < len(pa) {
+			na = pa[i]
+		}
+		if i < len(pb) {
+			nb = pb[i]
+		}
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// versionParts splits a version string into its numeric components.
+func versionParts(v string) []int {
+	v = strings.ReplaceAll(v, "_", ".")
+	fields := strings.Split(v, ".")
+	parts := make([]int, 0, len(fields))
+	for _, f := range fields {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			break
+		}
+		parts = append(parts, n)
+	}
+	return parts
+}
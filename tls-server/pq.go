@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// This file identifies post-quantum hybrid key-exchange groups (Kyber/ML-KEM
+// hybrids) in a ClientHello. Chrome has shipped these experimentally since
+// version 124 (X25519Kyber768Draft00, then later ML-KEM variants) ahead of
+// IANA registering final codepoints, so they're absent from the embedded
+// tls-parameters-8.csv snapshot (see iana.go) and would otherwise surface as
+// an opaque "0x6399"-style hex name - pqHybridGroups below fills that gap,
+// the same way certCompressionNames in main.go covers a registry IANA
+// hasn't caught up with yet.
+
+// pqHybridGroups maps the Kyber-hybrid supported_groups codepoints Chrome
+// has shipped pre-standardization to their commonly used draft names.
+var pqHybridGroups = map[uint16]string{
+	0x6399: "X25519Kyber768Draft00",
+	0x639a: "P256Kyber768Draft00",
+	0xfe30: "X25519Kyber512Draft00",
+}
+
+// isPQHybridGroup reports whether g is one of the known Kyber-hybrid group
+// codepoints.
+func isPQHybridGroup(g uint16) bool {
+	_, ok := pqHybridGroups[g]
+	return ok
+}
+
+// PQKeyExchangeInfo records which Kyber-hybrid groups a ClientHello
+// advertised support for (supported_groups) versus actually sent a key_share
+// for - a client can list a group as supported without committing a
+// key_share to it, so the two lists can legitimately differ.
+type PQKeyExchangeInfo struct {
+	SupportedGroups []string `json:"supported_groups,omitempty"`
+	KeyShareGroups  []string `json:"key_share_groups,omitempty"`
+}
+
+// detectPQSupport builds a TLSFingerprint.PQSupport from the already-parsed
+// supported_groups (as IANAEntry, via getGroupName) and key_share groups (as
+// "0x####" keys into ClientKeyShares). Returns nil when neither list
+// contains a known PQ hybrid, so PQSupport stays absent for the overwhelming
+// majority of ClientHellos that don't use one.
+func detectPQSupport(supportedGroups []IANAEntry, keyShares map[string]string) *PQKeyExchangeInfo {
+	var info PQKeyExchangeInfo
+
+	for _, g := range supportedGroups {
+		if isPQHybridGroup(g.Value) {
+			info.SupportedGroups = append(info.SupportedGroups, g.Name)
+		}
+	}
+
+	for hexGroup := range keyShares {
+		v, err := strconv.ParseUint(strings.TrimPrefix(hexGroup, "0x"), 16, 16)
+		if err != nil {
+			continue
+		}
+		if name, ok := pqHybridGroups[uint16(v)]; ok {
+			info.KeyShareGroups = append(info.KeyShareGroups, name)
+		}
+	}
+
+	if len(info.SupportedGroups) == 0 && len(info.KeyShareGroups) == 0 {
+		return nil
+	}
+	sort.Strings(info.SupportedGroups)
+	sort.Strings(info.KeyShareGroups)
+	return &info
+}
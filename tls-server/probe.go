@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+// This file is the write half of the tool: everything else here captures a
+// ClientHello and reports what it looks like, while ReplayFingerprint dials
+// out with one, reproducing a stored fingerprint's cipher/extension/group/
+// sig-alg/ALPN order against a real target so a client library's claimed
+// fingerprint can be checked against what it actually sends. /api/probe
+// wires this up (see main.go).
+
+// ProbeResult is the outcome of replaying a fingerprint against target.
+type ProbeResult struct {
+	Target         string `json:"target"`
+	NegotiatedALPN string `json:"negotiated_alpn,omitempty"`
+	JA4S           string `json:"ja4s,omitempty"`
+	JA4X           string `json:"ja4x,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// recordingConn wraps a net.Conn and keeps a copy of every byte read off it,
+// so the raw ServerHello record uTLS parsed can be re-parsed here for
+// buildJA4S without uTLS needing to expose it directly.
+type recordingConn struct {
+	net.Conn
+	read []byte
+}
+
+func (c *recordingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.read = append(c.read, b[:n]...)
+	}
+	return n, err
+}
+
+// isDisallowedProbeIP reports whether ip is loopback, private (RFC 1918/RFC
+// 4193), link-local, or unspecified - the set of addresses resolveProbeHost
+// refuses to hand back to ReplayFingerprint.
+func isDisallowedProbeIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
+
+// resolveProbeHost resolves host to the literal IP ReplayFingerprint should
+// actually dial, validating every address a lookup returns rather than just
+// the first. /api/probe hands target straight to an authenticated caller's
+// request, so without this check - and without dialing the IP this returns
+// instead of re-resolving host at dial time - it's an SSRF/internal-port-
+// scanning oracle: a domain the attacker controls can return a public
+// address here and a private/loopback one (e.g. 169.254.169.254) to a
+// second, independent lookup inside net.Dial, or return several addresses
+// with only the first validated while net.Dial falls through to a later,
+// private one if the first is unreachable.
+func resolveProbeHost(host string) (net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		if isDisallowedProbeIP(ip) {
+			return nil, fmt.Errorf("%s is a disallowed address; loopback/private/link-local targets are blocked", ip)
+		}
+		return ip, nil
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %q: %v", host, err)
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("resolve %q: no addresses returned", host)
+	}
+	for _, ip := range ips {
+		if isDisallowedProbeIP(ip) {
+			return nil, fmt.Errorf("%q resolves to %s, a disallowed address; loopback/private/link-local targets are blocked", host, ip)
+		}
+	}
+	return ips[0], nil
+}
+
+// ReplayFingerprint dials target (host:port) and performs a TLS handshake
+// whose ClientHello reproduces fp's cipher/extension/group/sig-alg/ALPN
+// lists in their original order - including GREASE slots, since fp.Ciphers
+// etc. already preserve those (see parseClientHello in main.go) - then
+// reports the target's negotiated ALPN plus the JA4S/JA4X of its response.
+// A failed handshake still returns whatever JA4S it managed to compute
+// before the failure, via result.Error alongside it.
+func ReplayFingerprint(target string, fp *TLSFingerprint) *ProbeResult {
+	result := &ProbeResult{Target: target}
+
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		result.Error = fmt.Sprintf("invalid target: %v", err)
+		return result
+	}
+	dialIP, err := resolveProbeHost(host)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	spec, err := specFromFingerprint(fp)
+	if err != nil {
+		result.Error = fmt.Sprintf("build spec: %v", err)
+		return result
+	}
+
+	// Dial the validated literal IP rather than target itself, so there's no
+	// second, independent DNS lookup for an attacker-controlled name to
+	// rebind between the check above and the connect here; host is still
+	// passed through as the TLS ServerName/SNI below.
+	rawConn, err := net.DialTimeout("tcp", net.JoinHostPort(dialIP.String(), port), 10*time.Second)
+	if err != nil {
+		result.Error = fmt.Sprintf("dial: %v", err)
+		return result
+	}
+	defer rawConn.Close()
+	rawConn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	rec := &recordingConn{Conn: rawConn}
+
+	uconn := utls.UClient(rec, &utls.Config{ServerName: host, InsecureSkipVerify: true}, utls.HelloCustom)
+	if err := uconn.ApplyPreset(spec); err != nil {
+		result.Error = fmt.Sprintf("apply spec: %v", err)
+		return result
+	}
+
+	handshakeErr := uconn.Handshake()
+	if handshakeErr != nil {
+		result.Error = fmt.Sprintf("handshake: %v", handshakeErr)
+	}
+
+	if sh := parseServerHelloForJA4S(rec.read); sh != nil {
+		result.NegotiatedALPN = sh.alpn
+		result.JA4S = buildJA4S(sh.version, sh.cipher, sh.extensions, sh.alpn)
+	}
+
+	state := uconn.ConnectionState()
+	if state.NegotiatedProtocol != "" {
+		result.NegotiatedALPN = state.NegotiatedProtocol
+	}
+	if len(state.PeerCertificates) > 0 {
+		result.JA4X = buildJA4X(state.PeerCertificates[0])
+	}
+
+	return result
+}
+
+// parsedServerHello is the subset of a ServerHello buildJA4S needs.
+type parsedServerHello struct {
+	version    uint16
+	cipher     uint16
+	extensions []uint16
+	alpn       string
+}
+
+// parseServerHelloForJA4S extracts the negotiated version, cipher and
+// extension list from the first ServerHello record in raw (everything a
+// recordingConn saw read off the wire), the same way parseClientHello reads
+// the mirror-image message server-side. Returns nil if raw doesn't start
+// with a recognizable handshake record carrying a ServerHello.
+func parseServerHelloForJA4S(raw []byte) *parsedServerHello {
+	if len(raw) < 5 || raw[0] != 22 {
+		return nil
+	}
+	recordLen := int(binary.BigEndian.Uint16(raw[3:5]))
+	if len(raw) < 5+recordLen || recordLen < 4 {
+		return nil
+	}
+	msg := raw[5 : 5+recordLen]
+	if msg[0] != 2 { // handshake type 2 = ServerHello
+		return nil
+	}
+
+	pos := 4 // handshake header: 1-byte type + 3-byte length
+	if pos+2 > len(msg) {
+		return nil
+	}
+	sh := &parsedServerHello{version: binary.BigEndian.Uint16(msg[pos : pos+2])}
+	pos += 2
+
+	pos += 32 // server random
+	if pos >= len(msg) {
+		return sh
+	}
+	sessionIDLen := int(msg[pos])
+	pos += 1 + sessionIDLen
+	if pos+2 > len(msg) {
+		return sh
+	}
+	sh.cipher = binary.BigEndian.Uint16(msg[pos : pos+2])
+	pos += 2
+	pos++ // compression method
+
+	if pos+2 > len(msg) {
+		return sh
+	}
+	extLen := int(binary.BigEndian.Uint16(msg[pos : pos+2]))
+	pos += 2
+	extEnd := pos + extLen
+	for pos < extEnd && pos+4 <= len(msg) {
+		extType := binary.BigEndian.Uint16(msg[pos : pos+2])
+		dataLen := int(binary.BigEndian.Uint16(msg[pos+2 : pos+4]))
+		pos += 4
+		sh.extensions = append(sh.extensions, extType)
+		if extType == 16 && pos+2 < len(msg) { // ALPN
+			protoLen := int(msg[pos+2])
+			if pos+3+protoLen <= len(msg) {
+				sh.alpn = string(msg[pos+3 : pos+3+protoLen])
+			}
+		}
+		if extType == 43 && dataLen >= 2 { // supported_versions, overrides legacy_version
+			sh.version = binary.BigEndian.Uint16(msg[pos : pos+2])
+		}
+		pos += dataLen
+	}
+	return sh
+}
+
+// specFromFingerprint builds a utls.ClientHelloSpec that reproduces fp's
+// cipher suite list and extension order. Extensions this server already
+// parses into concrete fields (SNI, supported_groups, ec_point_formats,
+// signature_algorithms, ALPN, supported_versions, key_share) are rebuilt
+// byte-for-byte from those fields; anything else is reproduced as a
+// GenericExtension carrying its original ID (and, for GREASE IDs, a real
+// UtlsGREASEExtension) so the extension *shape* still matches even though
+// its content doesn't - uTLS would need the original raw extension bytes to
+// go further, and fp only keeps those for the extensions listed above.
+func specFromFingerprint(fp *TLSFingerprint) (*utls.ClientHelloSpec, error) {
+	if fp == nil {
+		return nil, fmt.Errorf("no fingerprint to replay")
+	}
+	if len(fp.Ciphers) == 0 {
+		return nil, fmt.Errorf("fingerprint has no cipher suites")
+	}
+
+	ciphers := make([]uint16, len(fp.Ciphers))
+	for i, c := range fp.Ciphers {
+		ciphers[i] = c.Value
+	}
+
+	extensions := make([]utls.TLSExtension, 0, len(fp.Extensions))
+	for _, ext := range fp.Extensions {
+		extensions = append(extensions, extensionFromInfo(fp, ext))
+	}
+
+	return &utls.ClientHelloSpec{
+		CipherSuites:       ciphers,
+		CompressionMethods: []byte{0},
+		Extensions:         extensions,
+	}, nil
+}
+
+// extensionFromInfo builds the utls.TLSExtension for one parsed ExtensionInfo.
+func extensionFromInfo(fp *TLSFingerprint, ext ExtensionInfo) utls.TLSExtension {
+	if isGREASE(ext.ID) {
+		return &utls.UtlsGREASEExtension{}
+	}
+
+	switch ext.ID {
+	case 0: // server_name
+		return &utls.SNIExtension{ServerName: fp.SNI}
+	case 10: // supported_groups
+		curves := make([]utls.CurveID, len(fp.SupportedGroups))
+		for i, g := range fp.SupportedGroups {
+			curves[i] = utls.CurveID(g.Value)
+		}
+		return &utls.SupportedCurvesExtension{Curves: curves}
+	case 11: // ec_point_formats
+		points := make([]byte, 0, len(fp.ECPointFormats))
+		for _, p := range fp.ECPointFormats {
+			if b, err := hex.DecodeString(trimHexPrefix(p)); err == nil && len(b) == 1 {
+				points = append(points, b[0])
+			}
+		}
+		return &utls.SupportedPointsExtension{SupportedPoints: points}
+	case 13: // signature_algorithms
+		algs := make([]utls.SignatureScheme, len(fp.SignatureAlgorithms))
+		for i, a := range fp.SignatureAlgorithms {
+			algs[i] = utls.SignatureScheme(a.Value)
+		}
+		return &utls.SignatureAlgorithmsExtension{SupportedSignatureAlgorithms: algs}
+	case 16: // application_layer_protocol_negotiation
+		return &utls.ALPNExtension{AlpnProtocols: fp.ALPN}
+	case 43: // supported_versions
+		versions := make([]uint16, 0, len(fp.SupportedVersions))
+		for _, name := range fp.SupportedVersions {
+			if v, ok := versionCodeFromName(name); ok {
+				versions = append(versions, v)
+			}
+		}
+		return &utls.SupportedVersionsExtension{Versions: versions}
+	case 51: // key_share
+		shares := make([]utls.KeyShare, 0, len(fp.ClientKeyShares))
+		for groupHex := range fp.ClientKeyShares {
+			if group, err := strconv.ParseUint(trimHexPrefix(groupHex), 16, 16); err == nil {
+				shares = append(shares, utls.KeyShare{Group: utls.CurveID(group)})
+			}
+		}
+		return &utls.KeyShareExtension{KeyShares: shares}
+	default:
+		return &utls.GenericExtension{Id: ext.ID}
+	}
+}
+
+// trimHexPrefix strips a leading "0x"/"0X" so hex.DecodeString can parse the
+// "0x%02x"-formatted strings fp.ECPointFormats stores.
+func trimHexPrefix(s string) string {
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		return s[2:]
+	}
+	return s
+}
+
+// versionCodeFromName reverses getVersionName for the fixed set of names it
+// can produce, since fp.SupportedVersions only keeps the human-readable name.
+func versionCodeFromName(name string) (uint16, bool) {
+	switch name {
+	case "TLS 1.3":
+		return 0x0304, true
+	case "TLS 1.2":
+		return 0x0303, true
+	case "TLS 1.1":
+		return 0x0302, true
+	case "TLS 1.0":
+		return 0x0301, true
+	}
+	return 0, false
+}
@@ -0,0 +1,534 @@
+package main
+
+import (
+	"container/list"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// QUIC version numbers we know the Initial salt for.
+const (
+	quicVersion1 uint32 = 0x00000001
+	quicVersion2 uint32 = 0x6b3343cf
+)
+
+// RFC 9001 (v1) / RFC 9369 (v2) Initial salts, used to derive the Initial
+// packet protection keys from the client's destination connection ID.
+var quicInitialSalts = map[uint32][]byte{
+	quicVersion1: mustHexDecode("38762cf7f55934b34d179ae6a4c80cadccbb7f0a"),
+	quicVersion2: mustHexDecode("0dede3def700a6db819381be6e269dcbf9bd2ed9"),
+}
+
+func mustHexDecode(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// QUICFingerprint captures the QUIC/HTTP3-visible bits of a client that aren't
+// present in a plain TCP+TLS ClientHello: the negotiated QUIC version and the
+// raw transport parameters extension, alongside the TLS fingerprint extracted
+// from the Initial packet's embedded ClientHello so h3 clients are attributed
+// the same way as h2/h1.1 clients.
+type QUICFingerprint struct {
+	Version         string          `json:"version"`
+	TransportParams string          `json:"transport_params_hex,omitempty"` // raw extension 0x39 value
+	ALPN            []string        `json:"alpn,omitempty"`
+	TLS             *TLSFingerprint `json:"tls,omitempty"`
+}
+
+// quicReassembly accumulates CRYPTO frame bytes for one client connection
+// (keyed by destination connection ID) until a full ClientHello is available.
+type quicReassembly struct {
+	chunks map[uint64][]byte // offset -> data
+	total  int
+}
+
+// quicReassemblyCacheCapacity / quicReassemblyCacheMaxAge bound the TTL+LRU
+// cache of in-progress reassemblies, the same way tsHistoryCache
+// (nat_detection.go) bounds the per-source-IP TCP timestamp history: an
+// attacker flooding the UDP listener with Initial packets under random DCIDs
+// and CRYPTO frames that never complete offset 0 would otherwise grow
+// quicReassemblyStore forever, since a connKey is only removed on successful
+// reassembly.
+const (
+	quicReassemblyCacheCapacity = 10000
+	quicReassemblyCacheMaxAge   = 5 * time.Minute
+)
+
+// quicReassemblyCacheEntry is one connKey's in-progress reassembly plus its
+// LRU/TTL bookkeeping.
+type quicReassemblyCacheEntry struct {
+	key      string
+	r        *quicReassembly
+	lastSeen time.Time
+}
+
+// quicReassemblyCache is a thread-safe TTL+LRU cache of quicReassembly
+// values keyed by destination connection ID, structured like
+// tsHistoryCache (nat_detection.go) and TCPFingerprintCache (cache.go).
+type quicReassemblyCache struct {
+	mu        sync.Mutex
+	capacity  int
+	maxAge    time.Duration
+	entries   map[string]*list.Element
+	order     *list.List
+	evictions int64
+}
+
+// newQUICReassemblyCache creates a cache with the given LRU capacity and max entry age.
+func newQUICReassemblyCache(capacity int, maxAge time.Duration) *quicReassemblyCache {
+	return &quicReassemblyCache{
+		capacity: capacity,
+		maxAge:   maxAge,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// getOrCreate returns key's quicReassembly, creating one if absent, and moves
+// it to the front of the LRU order. Evicts the least-recently-used entry if
+// over capacity.
+func (c *quicReassemblyCache) getOrCreate(key string) *quicReassembly {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*quicReassemblyCacheEntry)
+		entry.lastSeen = now
+		c.order.MoveToFront(el)
+		return entry.r
+	}
+
+	entry := &quicReassemblyCacheEntry{key: key, r: &quicReassembly{chunks: make(map[uint64][]byte)}, lastSeen: now}
+	el := c.order.PushFront(entry)
+	c.entries[key] = el
+
+	if c.capacity > 0 && len(c.entries) > c.capacity {
+		c.evictLRULocked()
+	}
+	return entry.r
+}
+
+// delete removes key's entry, e.g. once its reassembly has completed.
+func (c *quicReassemblyCache) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+}
+
+// evictLRULocked removes the least-recently-used entry. Caller must hold c.mu.
+func (c *quicReassemblyCache) evictLRULocked() {
+	el := c.order.Back()
+	if el == nil {
+		return
+	}
+	entry := el.Value.(*quicReassemblyCacheEntry)
+	c.order.Remove(el)
+	delete(c.entries, entry.key)
+	c.evictions++
+}
+
+// SweepExpired removes entries whose lastSeen is older than maxAge. Intended
+// to run periodically from a ticker goroutine, same as
+// TCPFingerprintCache.SweepExpired / tsHistoryCache.SweepExpired.
+func (c *quicReassemblyCache) SweepExpired() {
+	if c.maxAge <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cutoff := time.Now().Add(-c.maxAge)
+	var next *list.Element
+	for el := c.order.Back(); el != nil; el = next {
+		next = el.Prev()
+		entry := el.Value.(*quicReassemblyCacheEntry)
+		if entry.lastSeen.Before(cutoff) {
+			c.order.Remove(el)
+			delete(c.entries, entry.key)
+			c.evictions++
+		}
+	}
+}
+
+// Size returns the number of connections currently tracked.
+func (c *quicReassemblyCache) Size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Evictions returns the running count of entries evicted by LRU or TTL.
+func (c *quicReassemblyCache) Evictions() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.evictions
+}
+
+var quicReassemblyStore = newQUICReassemblyCache(quicReassemblyCacheCapacity, quicReassemblyCacheMaxAge)
+
+// CleanupQUICReassembly periodically sweeps entries older than maxAge from
+// quicReassemblyStore, mirroring CleanupTSHistory (nat_detection.go) and
+// CleanupOldFingerprints (tcp.go).
+func CleanupQUICReassembly(maxAge time.Duration) {
+	ticker := time.NewTicker(maxAge / 2)
+	go func() {
+		for range ticker.C {
+			before := quicReassemblyStore.Evictions()
+			quicReassemblyStore.SweepExpired()
+			after := quicReassemblyStore.Evictions()
+			if after > before {
+				log.Printf("[QUIC] Swept %d expired reassembly entr(ies), cache size now %d",
+					after-before, quicReassemblyStore.Size())
+			}
+		}
+	}()
+}
+
+// StartQUICListener opens a UDP socket on addr and fingerprints QUIC Initial
+// packets alongside the existing TCP/TLS collector.
+func StartQUICListener(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to open QUIC UDP listener: %v", err)
+	}
+
+	log.Printf("[QUIC] Listening for QUIC Initial packets on %s", addr)
+
+	go func() {
+		defer conn.Close()
+		buf := make([]byte, 2048)
+		for {
+			n, remote, err := conn.ReadFrom(buf)
+			if err != nil {
+				log.Printf("[QUIC] read error: %v", err)
+				return
+			}
+			datagram := make([]byte, n)
+			copy(datagram, buf[:n])
+			processQUICDatagram(datagram, remote)
+		}
+	}()
+
+	return nil
+}
+
+// processQUICDatagram parses a (possibly coalesced) UDP datagram for a QUIC
+// long-header Initial packet and, once the embedded CRYPTO stream yields a
+// complete ClientHello, stores a QUICFingerprint for the sender.
+func processQUICDatagram(data []byte, remote net.Addr) {
+	if len(data) < 7 || data[0]&0x80 == 0 || data[0]&0x40 == 0 {
+		return // not a QUIC long header packet
+	}
+
+	version := binary.BigEndian.Uint32(data[1:5])
+	salt, ok := quicInitialSalts[version]
+	if !ok {
+		return // not an Initial packet we can unprotect (version negotiation, unknown version, ...)
+	}
+
+	pos := 5
+	if pos >= len(data) {
+		return
+	}
+	dcidLen := int(data[pos])
+	pos++
+	if pos+dcidLen > len(data) {
+		return
+	}
+	dcid := data[pos : pos+dcidLen]
+	pos += dcidLen
+
+	if pos >= len(data) {
+		return
+	}
+	scidLen := int(data[pos])
+	pos++
+	if pos+scidLen > len(data) {
+		return
+	}
+	pos += scidLen
+
+	// Long-header packets other than Initial don't carry a token; we only
+	// expect Initial here because only Initial keys are derivable from the
+	// salt, so require the low type bits (0x30) to read as Initial (0x00).
+	if (data[0] & 0x30) != 0x00 {
+		return
+	}
+
+	tokenLen, n := decodeVarint(data[pos:])
+	if n == 0 {
+		return
+	}
+	pos += n + int(tokenLen)
+	if pos > len(data) {
+		return
+	}
+
+	packetLen, n := decodeVarint(data[pos:])
+	if n == 0 {
+		return
+	}
+	pos += n
+	if pos+int(packetLen) > len(data) {
+		return
+	}
+	packet := data[pos : pos+int(packetLen)]
+
+	clientSecret := deriveQUICClientInitialSecret(salt, dcid)
+	key := hkdfExpandLabel(clientSecret, "quic key", nil, 16)
+	ivBase := hkdfExpandLabel(clientSecret, "quic iv", nil, 12)
+	hp := hkdfExpandLabel(clientSecret, "quic hp", nil, 16)
+
+	plaintext, err := removeInitialProtectionAndDecrypt(data[:pos], packet, key, ivBase, hp)
+	if err != nil {
+		return
+	}
+
+	cryptoData, offset, ok := extractCryptoFrame(plaintext)
+	if !ok {
+		return
+	}
+
+	clientHello, complete := reassembleQUICCrypto(string(dcid), offset, cryptoData)
+	if !complete {
+		return
+	}
+
+	// parseClientHello expects a TLS record-layer wrapper; QUIC CRYPTO frames
+	// carry the bare handshake message, so synthesize a minimal record header
+	// (the same helper handleConnection uses for its TCP-side ClientHello).
+	tlsFp, err := parseClientHello(wrapHandshakeRecord(clientHello))
+	if err != nil {
+		log.Printf("[QUIC] failed to parse embedded ClientHello: %v", err)
+		return
+	}
+
+	qfp := &QUICFingerprint{
+		Version: fmt.Sprintf("0x%08x", version),
+		ALPN:    tlsFp.ALPN,
+		TLS:     tlsFp,
+	}
+	for _, ext := range tlsFp.Extensions {
+		if ext.ID == 0x39 { // quic_transport_parameters
+			if raw, ok := ext.Data.(string); ok {
+				qfp.TransportParams = raw
+			}
+		}
+	}
+
+	host, _, _ := net.SplitHostPort(remote.String())
+	storeMutex.Lock()
+	combined, exists := fingerprintStore[remote.String()]
+	if !exists {
+		combined = &CombinedFingerprint{TLS: tlsFp}
+	}
+	combined.QUIC = qfp
+	fingerprintStore[remote.String()] = combined
+	fingerprintStore[host] = combined
+	storeMutex.Unlock()
+
+	log.Printf("[QUIC] Initial ClientHello from %s: version=%s JA3=%s JA4=%s", remote, qfp.Version, tlsFp.JA3Hash, tlsFp.JA4)
+}
+
+// deriveQUICClientInitialSecret implements RFC 9001 section 5.2's
+// initial_secret -> client_initial_secret derivation.
+func deriveQUICClientInitialSecret(salt, destConnID []byte) []byte {
+	initialSecret := hkdfExtract(salt, destConnID)
+	return hkdfExpandLabel(initialSecret, "client in", nil, 32)
+}
+
+// hkdfExtract wraps hkdf.Extract with the hash used throughout QUIC v1/v2's
+// Initial key schedule (SHA-256).
+func hkdfExtract(salt, ikm []byte) []byte {
+	return hkdf.Extract(sha256.New, ikm, salt)
+}
+
+// hkdfExpandLabel implements TLS 1.3's HKDF-Expand-Label (RFC 8446 7.1),
+// reused unmodified by the QUIC key schedule (RFC 9001 5.1).
+func hkdfExpandLabel(secret []byte, label string, context []byte, length int) []byte {
+	fullLabel := "tls13 " + label
+
+	hkdfLabel := make([]byte, 0, 2+1+len(fullLabel)+1+len(context))
+	hkdfLabel = append(hkdfLabel, byte(length>>8), byte(length))
+	hkdfLabel = append(hkdfLabel, byte(len(fullLabel)))
+	hkdfLabel = append(hkdfLabel, fullLabel...)
+	hkdfLabel = append(hkdfLabel, byte(len(context)))
+	hkdfLabel = append(hkdfLabel, context...)
+
+	out := make([]byte, length)
+	reader := hkdf.Expand(sha256.New, secret, hkdfLabel)
+	if _, err := reader.Read(out); err != nil {
+		return out
+	}
+	return out
+}
+
+// removeInitialProtectionAndDecrypt removes QUIC header protection (AES-ECB
+// sample-based masking) from the packet number and flags bits, then decrypts
+// the AEAD-protected payload with AES-128-GCM.
+func removeInitialProtectionAndDecrypt(header, packet, key, ivBase, hp []byte) ([]byte, error) {
+	// Header protection sampling starts 4 bytes after the (as-yet-unknown)
+	// packet number, which is at most 4 bytes long - RFC 9001 5.4.2.
+	const maxPNLen = 4
+	if len(packet) < maxPNLen+16 {
+		return nil, fmt.Errorf("packet too short")
+	}
+	sampleOffset := maxPNLen
+	sample := packet[sampleOffset : sampleOffset+16]
+
+	block, err := aes.NewCipher(hp)
+	if err != nil {
+		return nil, err
+	}
+	mask := make([]byte, 16)
+	block.Encrypt(mask, sample)
+
+	firstByte := header[0]
+	unprotectedFirst := firstByte ^ (mask[0] & 0x0f)
+	pnLen := int(unprotectedFirst&0x03) + 1
+
+	pnBytes := make([]byte, pnLen)
+	for i := 0; i < pnLen; i++ {
+		pnBytes[i] = packet[i] ^ mask[1+i]
+	}
+
+	var packetNumber uint64
+	for _, b := range pnBytes {
+		packetNumber = (packetNumber << 8) | uint64(b)
+	}
+
+	nonce := make([]byte, len(ivBase))
+	copy(nonce, ivBase)
+	for i := 0; i < 8 && i < len(nonce); i++ {
+		nonce[len(nonce)-1-i] ^= byte(packetNumber >> (8 * i))
+	}
+
+	aeadHeader := make([]byte, 0, len(header)+pnLen)
+	aeadHeader = append(aeadHeader, header...)
+	aeadHeader = append(aeadHeader, pnBytes...)
+	// Patch the unprotected first byte into the associated-data header copy.
+	aeadHeader[0] = unprotectedFirst
+
+	ciphertext := packet[pnLen:]
+
+	block2, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block2)
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Open(nil, nonce, ciphertext, aeadHeader)
+}
+
+// extractCryptoFrame scans decrypted Initial payload for a CRYPTO frame
+// (type 0x06) and returns its stream offset and data. PADDING (0x00) and ACK
+// (0x02/0x03) frames are skipped; anything else stops the scan since Initial
+// packets realistically only carry PADDING/ACK/CRYPTO.
+func extractCryptoFrame(payload []byte) (data []byte, offset uint64, ok bool) {
+	pos := 0
+	for pos < len(payload) {
+		frameType := payload[pos]
+		switch frameType {
+		case 0x00: // PADDING
+			pos++
+		case 0x02, 0x03: // ACK / ACK with ECN - skip without parsing, not needed
+			return nil, 0, false
+		case 0x06: // CRYPTO
+			pos++
+			off, n := decodeVarint(payload[pos:])
+			if n == 0 {
+				return nil, 0, false
+			}
+			pos += n
+			length, n := decodeVarint(payload[pos:])
+			if n == 0 {
+				return nil, 0, false
+			}
+			pos += n
+			if pos+int(length) > len(payload) {
+				return nil, 0, false
+			}
+			return payload[pos : pos+int(length)], off, true
+		default:
+			return nil, 0, false
+		}
+	}
+	return nil, 0, false
+}
+
+// reassembleQUICCrypto accumulates CRYPTO frame bytes for a connection (keyed
+// by destination connection ID) and reports whether a full TLS handshake
+// message (ClientHello: 1-byte type + 3-byte length prefix) is now available.
+func reassembleQUICCrypto(connKey string, offset uint64, data []byte) ([]byte, bool) {
+	r := quicReassemblyStore.getOrCreate(connKey)
+
+	if _, exists := r.chunks[offset]; !exists {
+		r.chunks[offset] = data
+		r.total += len(data)
+	}
+
+	// Only offset 0 can start a ClientHello; bail until we've seen it.
+	first, haveFirst := r.chunks[0]
+	if !haveFirst || len(first) < 4 {
+		return nil, false
+	}
+	want := 4 + (int(first[1])<<16 | int(first[2])<<8 | int(first[3]))
+	if r.total < want {
+		return nil, false
+	}
+
+	// Concatenate contiguous chunks starting at offset 0.
+	assembled := make([]byte, 0, want)
+	var next uint64
+	for len(assembled) < want {
+		chunk, ok := r.chunks[next]
+		if !ok {
+			return nil, false // gap; wait for more packets
+		}
+		assembled = append(assembled, chunk...)
+		next += uint64(len(chunk))
+	}
+
+	quicReassemblyStore.delete(connKey)
+	return assembled[:want], true
+}
+
+// decodeVarint decodes a QUIC variable-length integer (RFC 9000 16) and
+// returns the value plus the number of bytes consumed (0 on error).
+func decodeVarint(b []byte) (uint64, int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	prefix := b[0] >> 6
+	length := 1 << prefix
+	if len(b) < length {
+		return 0, 0
+	}
+	v := uint64(b[0] & 0x3f)
+	for i := 1; i < length; i++ {
+		v = (v << 8) | uint64(b[i])
+	}
+	return v, length
+}
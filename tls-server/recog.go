@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// This file adds support for Recog-style fingerprint definitions - the XML
+// format used by metasploit-framework/recog and metasploit_data_models - as
+// an alternative to the bespoke JSON databases in database.go. Where those
+// JSON files map an exact hash to a name, a Recog fingerprint is a regex
+// pattern with named <param> facts attached, e.g.:
+//
+//	<fingerprintdb matches="ja3">
+//	  <fingerprint pattern="^771,4865-4866-4867.*$" certainty="0.85">
+//	    <description>Chrome 131 on Windows</description>
+//	    <param pos="0" name="os.vendor" value="Microsoft"/>
+//	    <param pos="0" name="os.product" value="Windows"/>
+//	    <param pos="0" name="service.family" value="Chrome"/>
+//	  </fingerprint>
+//	</fingerprintdb>
+//
+// Operators drop additional *.xml files into ./data/recog/ and StartHotReload
+// (see database.go) picks them up without a restart. The "matches" attribute
+// routes each file's fingerprints to the JA3, HTTP/2 or User-Agent matcher.
+
+// recogParamXML is one <param> element: either a literal fact (pos="0", value
+// set in the XML) or a fact pulled from the pattern's Nth capture group
+// (pos=N>0, value left for the matcher to fill in from the submatch).
+type recogParamXML struct {
+	Pos   int    `xml:"pos,attr"`
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// recogFingerprintXML mirrors a single <fingerprint> element as it appears on
+// disk, before its pattern has been compiled.
+type recogFingerprintXML struct {
+	Pattern     string          `xml:"pattern,attr"`
+	Certainty   float64         `xml:"certainty,attr"`
+	Description string          `xml:"description"`
+	Params      []recogParamXML `xml:"param"`
+}
+
+// recogDBXML mirrors a whole Recog XML file: a flat list of fingerprints
+// plus the "matches" attribute saying which corpus (ja3/http2/ua) they apply to.
+type recogDBXML struct {
+	XMLName      xml.Name              `xml:"fingerprintdb"`
+	Matches      string                `xml:"matches,attr"`
+	Fingerprints []recogFingerprintXML `xml:"fingerprint"`
+}
+
+// RecogFingerprint is a compiled recogFingerprintXML entry, ready to match.
+type RecogFingerprint struct {
+	Description string
+	Certainty   float64
+	Params      []recogParamXML
+	pattern     *regexp.Regexp
+}
+
+// RecogMatch is the result of a successful Recog match: the facts the
+// fingerprint asserts, its certainty weight, and provenance text describing
+// which Recog corpus produced it (e.g. "recog:ja3").
+type RecogMatch struct {
+	Facts       map[string]string `json:"facts"`
+	Certainty   float64           `json:"certainty"`
+	Source      string            `json:"source"`
+	Description string            `json:"description,omitempty"`
+}
+
+// RecogIndex holds the compiled Recog fingerprints loaded from ./data/recog/,
+// split by the corpus ("matches" attribute) each file declared.
+type RecogIndex struct {
+	JA3   []RecogFingerprint
+	HTTP2 []RecogFingerprint
+	UA    []RecogFingerprint
+}
+
+// LoadRecogDir parses every *.xml file in dir as a Recog fingerprintdb and
+// returns the merged index. A missing directory is not an error - Recog
+// support is opt-in, so operators who never create ./data/recog/ just get an
+// empty index and LookupJA3Recog/LookupHTTP2Recog/LookupUA always miss.
+func LoadRecogDir(dir string) (*RecogIndex, []string, error) {
+	index := &RecogIndex{}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return index, nil, nil
+		}
+		return index, nil, err
+	}
+
+	var loadedFiles []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".xml" {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("[DB] Failed to read recog file %s: %v", path, err)
+			continue
+		}
+
+		var raw recogDBXML
+		if err := xml.Unmarshal(data, &raw); err != nil {
+			log.Printf("[DB] Failed to parse recog file %s: %v", path, err)
+			continue
+		}
+
+		compiled, err := compileRecogFingerprints(raw.Fingerprints)
+		if err != nil {
+			log.Printf("[DB] Failed to compile recog patterns in %s: %v", path, err)
+			continue
+		}
+
+		switch raw.Matches {
+		case "ja3":
+			index.JA3 = append(index.JA3, compiled...)
+		case "http2":
+			index.HTTP2 = append(index.HTTP2, compiled...)
+		case "ua", "user_agent":
+			index.UA = append(index.UA, compiled...)
+		default:
+			log.Printf("[DB] Recog file %s has unrecognized matches=%q, skipping", path, raw.Matches)
+			continue
+		}
+		loadedFiles = append(loadedFiles, path)
+	}
+
+	return index, loadedFiles, nil
+}
+
+// compileRecogFingerprints compiles each fingerprint's pattern, skipping (and
+// logging) any that don't compile rather than failing the whole file - one
+// bad upstream regex shouldn't take down every other fingerprint in it.
+func compileRecogFingerprints(raw []recogFingerprintXML) ([]RecogFingerprint, error) {
+	compiled := make([]RecogFingerprint, 0, len(raw))
+	for _, fp := range raw {
+		re, err := regexp.Compile(fp.Pattern)
+		if err != nil {
+			log.Printf("[DB] Skipping recog fingerprint with invalid pattern %q: %v", fp.Pattern, err)
+			continue
+		}
+		certainty := fp.Certainty
+		if certainty == 0 {
+			certainty = 1.0 // recog convention: an absent certainty means "certain"
+		}
+		compiled = append(compiled, RecogFingerprint{
+			Description: fp.Description,
+			Certainty:   certainty,
+			Params:      fp.Params,
+			pattern:     re,
+		})
+	}
+	return compiled, nil
+}
+
+// match runs value against a compiled Recog fingerprint set in order and
+// returns the first hit, its facts assembled from the matching fingerprint's
+// <param> entries. Recog convention is first-match-wins within a file, with
+// more specific patterns listed first by whoever curated it.
+func matchRecogFingerprints(fingerprints []RecogFingerprint, source string, value string) (RecogMatch, bool) {
+	for _, fp := range fingerprints {
+		submatches := fp.pattern.FindStringSubmatch(value)
+		if submatches == nil {
+			continue
+		}
+
+		facts := make(map[string]string, len(fp.Params))
+		for _, p := range fp.Params {
+			if p.Pos == 0 {
+				facts[p.Name] = p.Value
+				continue
+			}
+			if p.Pos < len(submatches) {
+				facts[p.Name] = submatches[p.Pos]
+			}
+		}
+
+		return RecogMatch{
+			Facts:       facts,
+			Certainty:   fp.Certainty,
+			Source:      source,
+			Description: fp.Description,
+		}, true
+	}
+	return RecogMatch{}, false
+}
+
+// LookupJA3Recog matches a normalized JA3 string (not its hash - Recog
+// patterns match against the readable "771,4865-..." form) against the
+// loaded JA3 Recog fingerprints.
+func (db *FingerprintDatabase) LookupJA3Recog(ja3 string) (RecogMatch, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.Recog == nil {
+		return RecogMatch{}, false
+	}
+	return matchRecogFingerprints(db.Recog.JA3, "recog:ja3", ja3)
+}
+
+// LookupHTTP2Recog matches an Akamai-format HTTP/2 fingerprint string against
+// the loaded HTTP/2 Recog fingerprints.
+func (db *FingerprintDatabase) LookupHTTP2Recog(akamai string) (RecogMatch, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.Recog == nil {
+		return RecogMatch{}, false
+	}
+	return matchRecogFingerprints(db.Recog.HTTP2, "recog:http2", akamai)
+}
+
+// LookupUA matches a raw User-Agent string against the loaded UA Recog
+// fingerprints, for operators who want to maintain browser/OS detection
+// rules as data (Recog XML) instead of the Go evaluators in analysis.go.
+func (db *FingerprintDatabase) LookupUA(ua string) (RecogMatch, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.Recog == nil {
+		return RecogMatch{}, false
+	}
+	return matchRecogFingerprints(db.Recog.UA, "recog:ua", ua)
+}
+
+// recogIndexSummary renders a one-line "N ja3, N http2, N ua" summary for the
+// startup log, mirroring the per-database counts logged elsewhere in Load.
+func recogIndexSummary(index *RecogIndex) string {
+	if index == nil {
+		return "0 ja3, 0 http2, 0 ua"
+	}
+	return fmt.Sprintf("%d ja3, %d http2, %d ua", len(index.JA3), len(index.HTTP2), len(index.UA))
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// This file replaces IsImpersonatorByHTTP2Rules' six hardcoded Chrome/
+// Safari/Firefox/curl-impersonate if-blocks (see database.go, which still
+// owns the entry point) with a small predicate DSL sourced from
+// http2_fingerprints.json's detection_rules.impersonator_signals array -
+// the same Default*()+optional-JSON-override shape as ConsistencyRuleEngine
+// (consistency_rules.go), so a new impersonator release no longer needs a
+// Go change and rebuild.
+
+// AkamaiFingerprint is the parsed view of an HTTP2Fingerprint's static
+// fields an ImpersonatorRule's conditions match against. Settings is keyed
+// by numeric SETTINGS ID as a string (e.g. "4" -> "6291456", the same
+// id:value pairing buildAkamaiFingerprint renders) rather than the raw
+// []SettingParam, so a condition string doesn't need to know wire order.
+type AkamaiFingerprint struct {
+	Settings          map[string]string
+	WindowUpdate      string
+	Priority          string
+	PseudoHeaderOrder string
+	ALPN              string // comma-joined, negotiated-protocol order as sent
+}
+
+// parseAkamaiFingerprint splits an Akamai-format HTTP/2 fingerprint string
+// (SETTINGS|WINDOW_UPDATE|PRIORITY|pseudo_header_order, see
+// buildAkamaiFingerprint in http2.go) into an AkamaiFingerprint. pseudoOrder
+// overrides the string's own fourth field when the caller already has
+// HTTP2Fingerprint.PseudoHeaderOrder split out separately.
+func parseAkamaiFingerprint(akamai string, pseudoOrder string) (*AkamaiFingerprint, bool) {
+	parts := strings.Split(akamai, "|")
+	if len(parts) < 4 {
+		return nil, false
+	}
+
+	settings := make(map[string]string)
+	for _, token := range strings.Split(parts[0], ";") {
+		if id, value, ok := strings.Cut(token, ":"); ok {
+			settings[id] = value
+		}
+	}
+
+	if pseudoOrder == "" {
+		pseudoOrder = parts[3]
+	}
+
+	return &AkamaiFingerprint{
+		Settings:          settings,
+		WindowUpdate:      parts[1],
+		Priority:          parts[2],
+		PseudoHeaderOrder: pseudoOrder,
+	}, true
+}
+
+// ImpersonatorRule is one weighted predicate as loaded from
+// http2_fingerprints.json's detection_rules.impersonator_signals array: all
+// of When must match an AkamaiFingerprint, and none of Unless may, for
+// Weight to count toward the verdict score.
+//
+// Each condition string is one of:
+//   - "settings.<id>=<value>" / "settings.<id>!=<value>"
+//   - "window_update=<value>" / "window_update!=<value>"
+//   - "pseudo_header_order=<value>" / "pseudo_header_order!=<value>"
+//   - "priority=<value>" / "priority!=<value>"
+//   - "alpn=<value>" / "alpn!=<value>"
+//   - "has(settings.<id>)" - true when that SETTINGS id was sent at all
+type ImpersonatorRule struct {
+	Rule   string   `json:"rule"`
+	When   []string `json:"when"`
+	Unless []string `json:"unless,omitempty"`
+	Weight int      `json:"weight"`
+	Reason string   `json:"reason"`
+}
+
+// condition is one parsed "settings.4=6291456"/"has(settings.6)"-style
+// string, compiled once at Load time so EvaluateImpersonatorRules doesn't
+// re-parse it on every connection.
+type condition struct {
+	field  string // "settings", "window_update", "pseudo_header_order", "priority", "alpn"
+	key    string // the "4" in "settings.4"; empty for non-settings fields
+	negate bool
+	value  string // "" for a has(...) condition
+	isHas  bool
+}
+
+// compiledRule is an ImpersonatorRule with its When/Unless conditions
+// pre-parsed.
+type compiledRule struct {
+	rule   string
+	when   []condition
+	unless []condition
+	weight int
+	reason string
+}
+
+// ImpersonatorRuleSet is the compiled form of a []ImpersonatorRule,
+// returned by CompileImpersonatorRules and evaluated by
+// IsImpersonatorByHTTP2Rules.
+type ImpersonatorRuleSet struct {
+	rules []compiledRule
+}
+
+// CompileImpersonatorRules parses raw's When/Unless condition strings once,
+// so a malformed condition is reported at Load time rather than silently
+// never matching later. A rule that fails to compile is dropped with its
+// error logged by the caller, not the whole rule set.
+func CompileImpersonatorRules(raw []ImpersonatorRule) (*ImpersonatorRuleSet, []error) {
+	var errs []error
+	rs := &ImpersonatorRuleSet{}
+	for _, r := range raw {
+		when, err := compileConditions(r.When)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %q: when: %w", r.Rule, err))
+			continue
+		}
+		unless, err := compileConditions(r.Unless)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %q: unless: %w", r.Rule, err))
+			continue
+		}
+		rs.rules = append(rs.rules, compiledRule{
+			rule:   r.Rule,
+			when:   when,
+			unless: unless,
+			weight: r.Weight,
+			reason: r.Reason,
+		})
+	}
+	return rs, errs
+}
+
+func compileConditions(raw []string) ([]condition, error) {
+	conditions := make([]condition, 0, len(raw))
+	for _, s := range raw {
+		c, err := compileCondition(s)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, c)
+	}
+	return conditions, nil
+}
+
+func compileCondition(s string) (condition, error) {
+	if strings.HasPrefix(s, "has(") && strings.HasSuffix(s, ")") {
+		field := strings.TrimSuffix(strings.TrimPrefix(s, "has("), ")")
+		prefix, key, ok := strings.Cut(field, ".")
+		if !ok || prefix != "settings" {
+			return condition{}, fmt.Errorf("has() only supports settings.<id>, got %q", s)
+		}
+		return condition{field: "settings", key: key, isHas: true}, nil
+	}
+
+	negate := false
+	field, value, ok := strings.Cut(s, "!=")
+	if ok {
+		negate = true
+	} else {
+		field, value, ok = strings.Cut(s, "=")
+	}
+	if !ok {
+		return condition{}, fmt.Errorf("condition %q missing '=' or '!='", s)
+	}
+
+	key := ""
+	if prefix, k, isSettings := strings.Cut(field, "."); isSettings {
+		if prefix != "settings" {
+			return condition{}, fmt.Errorf("unknown field %q", field)
+		}
+		field, key = "settings", k
+	}
+
+	switch field {
+	case "settings", "window_update", "pseudo_header_order", "priority", "alpn":
+		return condition{field: field, key: key, negate: negate, value: value}, nil
+	default:
+		return condition{}, fmt.Errorf("unknown field %q", field)
+	}
+}
+
+// matches reports whether c holds against fp.
+func (c condition) matches(fp *AkamaiFingerprint) bool {
+	if c.field == "settings" {
+		got, present := fp.Settings[c.key]
+		if c.isHas {
+			return present
+		}
+		return present && (got == c.value) != c.negate
+	}
+
+	var got string
+	switch c.field {
+	case "window_update":
+		got = fp.WindowUpdate
+	case "pseudo_header_order":
+		got = fp.PseudoHeaderOrder
+	case "priority":
+		got = fp.Priority
+	case "alpn":
+		got = fp.ALPN
+	}
+	return (got == c.value) != c.negate
+}
+
+func allConditionsMatch(conditions []condition, fp *AkamaiFingerprint) bool {
+	for _, c := range conditions {
+		if !c.matches(fp) {
+			return false
+		}
+	}
+	return true
+}
+
+func anyConditionMatches(conditions []condition, fp *AkamaiFingerprint) bool {
+	for _, c := range conditions {
+		if c.matches(fp) {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate sums the Weight of every rule whose When conditions all match fp
+// and whose Unless conditions don't, returning whether the total reaches
+// threshold (3, matching the pre-DSL signals >= 3 cutoff) plus the fired
+// rules' reasons in rule order.
+func (rs *ImpersonatorRuleSet) Evaluate(fp *AkamaiFingerprint, threshold int) (bool, []string) {
+	if rs == nil {
+		return false, nil
+	}
+
+	score := 0
+	var reasons []string
+	for _, r := range rs.rules {
+		if !allConditionsMatch(r.when, fp) {
+			continue
+		}
+		if anyConditionMatches(r.unless, fp) {
+			continue
+		}
+		score += r.weight
+		reasons = append(reasons, r.reason)
+	}
+	return score >= threshold, reasons
+}
+
+// DefaultImpersonatorRules migrates IsImpersonatorByHTTP2Rules' six
+// hardcoded cases into the rule DSL above - the built-in baseline used
+// until an operator supplies detection_rules.impersonator_signals via
+// ./data/http2_fingerprints.json. Browser baselines:
+//
+//	Chrome:  SETTINGS has 4:6291456,6:262144, WU=15663105, pseudo=m,a,s,p
+//	Firefox: SETTINGS has 4:131072,5:16384,   WU=12517377, pseudo=m,p,a,s
+//	Safari:  SETTINGS has 2:0,9:1,            WU=10420225, pseudo=m,s,a or m,s,p,a
+func DefaultImpersonatorRules() []ImpersonatorRule {
+	return []ImpersonatorRule{
+		{
+			Rule:   "chrome-settings-wu-pseudo-mismatch",
+			When:   []string{"settings.4=6291456", "settings.6=262144", "window_update=15663105"},
+			Unless: []string{"pseudo_header_order=", "pseudo_header_order=m,a,s,p"},
+			Weight: 3,
+			Reason: "Chrome SETTINGS+WINDOW_UPDATE but pseudo-header order doesn't match Chrome's m,a,s,p - likely curl-impersonate",
+		},
+		{
+			Rule:   "chrome-settings-wu-mismatch",
+			When:   []string{"settings.4=6291456", "settings.6=262144"},
+			Unless: []string{"window_update=15663105", "window_update=0"},
+			Weight: 1,
+			Reason: "Chrome-like SETTINGS but WINDOW_UPDATE doesn't match Chrome's 15663105",
+		},
+		{
+			Rule:   "safari-settings-wu-pseudo-mismatch",
+			When:   []string{"settings.2=0", "settings.9=1", "window_update=10420225"},
+			Unless: []string{"pseudo_header_order=", "pseudo_header_order=m,s,a", "pseudo_header_order=m,s,p,a"},
+			Weight: 3,
+			Reason: "Safari SETTINGS+WINDOW_UPDATE but pseudo-header order doesn't match Safari's m,s,a/m,s,p,a",
+		},
+		{
+			Rule:   "firefox-settings-wu-pseudo-mismatch",
+			When:   []string{"settings.4=131072", "settings.5=16384", "window_update=12517377"},
+			Unless: []string{"pseudo_header_order=", "pseudo_header_order=m,p,a,s"},
+			Weight: 3,
+			Reason: "Firefox SETTINGS+WINDOW_UPDATE but pseudo-header order doesn't match Firefox's m,p,a,s",
+		},
+		{
+			Rule:   "curl-impersonate-missing-path",
+			When:   []string{"settings.4=6291456", "settings.6=262144", "pseudo_header_order=m,a,s"},
+			Weight: 2,
+			Reason: "curl-impersonate signature: Chrome SETTINGS with pseudo='m,a,s' (missing ':path')",
+		},
+		{
+			Rule:   "mixed-chrome-settings-safari-wu",
+			When:   []string{"settings.4=6291456", "settings.6=262144", "window_update=10420225"},
+			Weight: 2,
+			Reason: "Mixed fingerprint: Chrome SETTINGS with Safari WINDOW_UPDATE",
+		},
+		{
+			Rule:   "mixed-safari-settings-chrome-wu",
+			When:   []string{"settings.2=0", "settings.9=1", "window_update=15663105"},
+			Weight: 2,
+			Reason: "Mixed fingerprint: Safari SETTINGS with Chrome WINDOW_UPDATE",
+		},
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import "testing"
+
+// Golden Akamai-format fingerprints (SETTINGS|WINDOW_UPDATE|PRIORITY|pseudo_header_order,
+// see buildAkamaiFingerprint in http2.go) for the clients DefaultImpersonatorRules is meant
+// to tell apart: the three genuine browsers it's modeled on, a curl-impersonate build that
+// copies Chrome's SETTINGS/WINDOW_UPDATE but not its pseudo-header order, and two well-behaved
+// Go HTTP/2 clients (undici, net/http) that shouldn't trip any of them.
+const (
+	akamaiChrome131  = "1:65536;2:0;4:6291456;6:262144|15663105|0|m,a,s,p"
+	akamaiSafari17   = "2:0;4:4194304;9:1|10420225|2:0:0:255|m,s,a"
+	akamaiFirefox121 = "1:65536;4:131072;5:16384|12517377|3:0:201:0|m,p,a,s"
+	akamaiCurlChrome = "1:65536;2:0;4:6291456;6:262144|262144|0|m,a,s"
+	akamaiNodeUndici = "1:65536;2:0;3:100;4:6291456|6291456|0|m,s,a,p"
+	akamaiGoNetHTTP  = "4:4194304|4194304|0|m,s,a,p"
+)
+
+func TestDefaultImpersonatorRulesGoldenFingerprints(t *testing.T) {
+	rs, errs := CompileImpersonatorRules(DefaultImpersonatorRules())
+	if len(errs) != 0 {
+		t.Fatalf("CompileImpersonatorRules(DefaultImpersonatorRules()) returned errors: %v", errs)
+	}
+
+	tests := []struct {
+		name             string
+		akamai           string
+		wantImpersonator bool
+	}{
+		{"chrome-131", akamaiChrome131, false},
+		{"safari-17", akamaiSafari17, false},
+		{"firefox-121", akamaiFirefox121, false},
+		{"curl-impersonate-chrome", akamaiCurlChrome, true},
+		{"node-undici", akamaiNodeUndici, false},
+		{"go-net-http", akamaiGoNetHTTP, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fp, ok := parseAkamaiFingerprint(tt.akamai, "")
+			if !ok {
+				t.Fatalf("parseAkamaiFingerprint(%q) failed to parse", tt.akamai)
+			}
+
+			got, reasons := rs.Evaluate(fp, 3)
+			if got != tt.wantImpersonator {
+				t.Errorf("Evaluate(%s) = %v, reasons=%v; want %v", tt.name, got, reasons, tt.wantImpersonator)
+			}
+		})
+	}
+}
+
+func TestCompileImpersonatorRulesRejectsMalformedCondition(t *testing.T) {
+	_, errs := CompileImpersonatorRules([]ImpersonatorRule{
+		{Rule: "bad", When: []string{"not_a_real_condition"}, Weight: 1},
+	})
+	if len(errs) == 0 {
+		t.Fatal("expected an error compiling an unknown field, got none")
+	}
+}
+
+func TestParseAkamaiFingerprintPseudoOverride(t *testing.T) {
+	fp, ok := parseAkamaiFingerprint(akamaiChrome131, "m,a,p,s")
+	if !ok {
+		t.Fatalf("parseAkamaiFingerprint(%q) failed to parse", akamaiChrome131)
+	}
+	if fp.PseudoHeaderOrder != "m,a,p,s" {
+		t.Errorf("PseudoHeaderOrder = %q, want override %q", fp.PseudoHeaderOrder, "m,a,p,s")
+	}
+}
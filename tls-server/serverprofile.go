@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+)
+
+// ServerProfile describes the exact bytes this server's TLS/HTTP2 responses
+// should use, letting the collector optionally mimic a specific target (e.g.
+// "Chrome 124 server-side behavior") instead of its own built-in defaults.
+// Useful for researching whether middleboxes/CDNs fingerprint servers the
+// same way they fingerprint clients. Loaded from JSON via LoadServerProfile;
+// DefaultServerProfile reproduces this server's original hardcoded behavior.
+type ServerProfile struct {
+	Name string `json:"name"`
+
+	// CipherPreference reorders tls.Config.CipherSuites for the TLS 1.2 and
+	// earlier path (legacy crypto/tls handshake in handleConnection). It has
+	// no effect on TLS 1.3, where Go's stdlib doesn't expose a suite-order
+	// knob - nor on the native handshake below.
+	CipherPreference []uint16 `json:"cipher_preference,omitempty"`
+
+	// NativeCipherSuite and NativeKeyShareGroup describe the intended
+	// ServerHello for the native TLS 1.3 handshake (tlsstack.go). That
+	// handshake only actually implements TLS_AES_128_GCM_SHA256 (0x1301)
+	// over X25519 (0x001d): a profile asking for anything else is honored in
+	// the advertised values only if it matches, and otherwise logged and
+	// ignored rather than sent (sending a ServerHello the server can't
+	// actually speak would just break the handshake).
+	NativeCipherSuite   uint16 `json:"native_cipher_suite,omitempty"`
+	NativeKeyShareGroup uint16 `json:"native_key_share_group,omitempty"`
+
+	// ALPNPreference is tried in order against the client's offered
+	// protocol list (first match wins), for both the legacy and native paths.
+	ALPNPreference []string `json:"alpn_preference,omitempty"`
+
+	// HTTP2Settings is sent verbatim, in order, as the server's SETTINGS frame.
+	HTTP2Settings []HTTP2SettingParam `json:"http2_settings,omitempty"`
+
+	// WindowUpdateIncrement, if non-zero, makes the server follow its
+	// SETTINGS frame with a connection-level WINDOW_UPDATE of this size -
+	// some real-world servers do this immediately, others never do.
+	WindowUpdateIncrement uint32 `json:"window_update_increment,omitempty"`
+}
+
+// HTTP2SettingParam is one SETTINGS identifier/value pair.
+type HTTP2SettingParam struct {
+	ID    uint16 `json:"id"`
+	Value uint32 `json:"value"`
+}
+
+const (
+	nativeCipherSuiteAES128GCM = 0x1301
+	nativeKeyShareGroupX25519  = 0x001d
+)
+
+// DefaultServerProfile reproduces this server's original hardcoded behavior,
+// so passing it through is a no-op for anyone not using -server-profile.
+func DefaultServerProfile() *ServerProfile {
+	return &ServerProfile{
+		Name:                "default",
+		NativeCipherSuite:   nativeCipherSuiteAES128GCM,
+		NativeKeyShareGroup: nativeKeyShareGroupX25519,
+		ALPNPreference:      []string{"h2", "http/1.1"},
+		HTTP2Settings: []HTTP2SettingParam{
+			{ID: 0x3, Value: 100},   // SETTINGS_MAX_CONCURRENT_STREAMS
+			{ID: 0x4, Value: 65535}, // SETTINGS_INITIAL_WINDOW_SIZE
+		},
+	}
+}
+
+// LoadServerProfile reads a ServerProfile from a JSON file, filling in any
+// field the file omits from DefaultServerProfile so a profile only needs to
+// specify what it wants to change.
+func LoadServerProfile(path string) (*ServerProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := DefaultServerProfile()
+	if err := json.Unmarshal(data, profile); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[ServerProfile] Loaded %q from %s", profile.Name, path)
+	return profile, nil
+}
+
+// resolveNativeHandshakeParams picks the cipher suite and key_share group the
+// native TLS 1.3 handshake will actually send, honoring profile only where it
+// matches what the handshake implements.
+func resolveNativeHandshakeParams(profile *ServerProfile) (cipherSuite, keyShareGroup uint16) {
+	cipherSuite, keyShareGroup = nativeCipherSuiteAES128GCM, nativeKeyShareGroupX25519
+	if profile == nil {
+		return
+	}
+	if profile.NativeCipherSuite != 0 && profile.NativeCipherSuite != nativeCipherSuiteAES128GCM {
+		log.Printf("[ServerProfile] %q requests native cipher suite 0x%04x, but the native handshake only speaks 0x%04x - ignoring",
+			profile.Name, profile.NativeCipherSuite, nativeCipherSuiteAES128GCM)
+	}
+	if profile.NativeKeyShareGroup != 0 && profile.NativeKeyShareGroup != nativeKeyShareGroupX25519 {
+		log.Printf("[ServerProfile] %q requests native key_share group 0x%04x, but the native handshake only speaks 0x%04x - ignoring",
+			profile.Name, profile.NativeKeyShareGroup, nativeKeyShareGroupX25519)
+	}
+	return
+}
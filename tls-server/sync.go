@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// This file adds a Syncer that keeps the JA3/JA4/HTTP2 JSON databases (see
+// database.go) current by periodically pulling from upstream feeds an
+// operator lists in ./data/sources.json, instead of requiring a new release
+// every time a browser ships or a fresh impersonator signature surfaces.
+// There's deliberately no built-in default source list - unlike
+// DefaultMITMDatabase/DefaultFaviconCorpus/etc., shipping a hardcoded set of
+// URLs this binary phones home to by default would be a trust and privacy
+// problem; an empty or missing sources.json just means Sync has nothing to
+// do, the same way an empty ./data/recog/ means LoadRecogDir finds nothing.
+
+// syncSource is one upstream feed entry from ./data/sources.json.
+type syncSource struct {
+	URL      string `json:"url"`
+	Format   string `json:"format"`   // "ja3csv", "ja4json", "http2json"
+	Category string `json:"category"` // JA3Database/HTTP2Database sub-map this feed merges into
+	Trust    string `json:"trust"`    // "low", "medium", "high"
+	SHA256   string `json:"sha256,omitempty"`
+}
+
+// syncMaxBodyBytes caps how much of a feed response Syncer will read, so a
+// misconfigured or compromised source URL can't exhaust memory.
+const syncMaxBodyBytes = 16 << 20 // 16 MiB
+
+// syncHTTPTimeout bounds how long a single feed fetch may take.
+const syncHTTPTimeout = 30 * time.Second
+
+// DefaultSyncInterval is how often StartSync re-syncs when the caller
+// doesn't override it (the -sync-interval flag in main.go).
+const DefaultSyncInterval = 24 * time.Hour
+
+// loadSyncSources reads ./data/sources.json. A missing file is not an error
+// - it just means there's nothing configured to sync yet, mirroring how
+// Load() treats every other optional corpus file.
+func loadSyncSources(path string) ([]syncSource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var sources []syncSource
+	if err := json.Unmarshal(data, &sources); err != nil {
+		return nil, fmt.Errorf("parsing sources.json: %w", err)
+	}
+	return sources, nil
+}
+
+// fetchSource downloads src.URL with a hardened client - timeout, a capped
+// body read, and optional sha256 pinning - and returns the raw body.
+func fetchSource(ctx context.Context, client *http.Client, src syncSource) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, syncMaxBodyBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > syncMaxBodyBytes {
+		return nil, fmt.Errorf("response exceeds %d byte cap", syncMaxBodyBytes)
+	}
+
+	if src.SHA256 != "" {
+		sum := sha256.Sum256(body)
+		if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, src.SHA256) {
+			return nil, fmt.Errorf("sha256 mismatch: expected %s, got %s", src.SHA256, got)
+		}
+	}
+
+	return body, nil
+}
+
+// writeJSONAtomic marshals v and replaces path with the result via a
+// temp-file-in-the-same-dir-then-rename, so a reader (including Load()
+// running concurrently on another goroutine) never observes a partially
+// written file.
+func writeJSONAtomic(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // no-op once the rename below succeeds
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// ja3CSVRecord is one row of a "ja3csv"-format feed: hash,name[,version[,platform]].
+func parseJA3CSV(body []byte) (map[string]JA3Entry, error) {
+	r := csv.NewReader(strings.NewReader(string(body)))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parsing ja3csv: %w", err)
+	}
+
+	entries := make(map[string]JA3Entry)
+	for _, row := range records {
+		if len(row) < 2 || strings.EqualFold(strings.TrimSpace(row[0]), "hash") {
+			continue // skip blank rows and an optional header row
+		}
+		entry := JA3Entry{Name: strings.TrimSpace(row[1])}
+		if len(row) > 2 {
+			entry.Version = strings.TrimSpace(row[2])
+		}
+		if len(row) > 3 {
+			entry.Platform = strings.TrimSpace(row[3])
+		}
+		entries[strings.TrimSpace(row[0])] = entry
+	}
+	return entries, nil
+}
+
+// ja4JSONEntry is one element of a "ja4json"-format feed, merged into
+// JA4Database.KnownFingerprints.
+type ja4JSONEntry struct {
+	Hash    string   `json:"hash"`
+	Pattern string   `json:"pattern"`
+	Clients []string `json:"clients"`
+	Notes   string   `json:"notes"`
+}
+
+func parseJA4JSON(body []byte) ([]ja4JSONEntry, error) {
+	var entries []ja4JSONEntry
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return nil, fmt.Errorf("parsing ja4json: %w", err)
+	}
+	return entries, nil
+}
+
+// http2JSONEntry is one element of an "http2json"-format feed, merged into
+// HTTP2Database.Fingerprints.<category>.
+type http2JSONEntry struct {
+	Akamai string `json:"akamai"`
+	HTTP2Entry
+}
+
+func parseHTTP2JSON(body []byte) ([]http2JSONEntry, error) {
+	var entries []http2JSONEntry
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return nil, fmt.Errorf("parsing http2json: %w", err)
+	}
+	return entries, nil
+}
+
+// ja3Categories are the JA3Database.Fingerprints sub-maps a ja3csv source's
+// Category can target.
+var ja3Categories = map[string]func(*JA3Database) *map[string]JA3Entry{
+	"browsers":  func(db *JA3Database) *map[string]JA3Entry { return &db.Fingerprints.Browsers },
+	"libraries": func(db *JA3Database) *map[string]JA3Entry { return &db.Fingerprints.Libraries },
+	"bots":      func(db *JA3Database) *map[string]JA3Entry { return &db.Fingerprints.Bots },
+	"malware":   func(db *JA3Database) *map[string]JA3Entry { return &db.Fingerprints.Malware },
+	"mobile":    func(db *JA3Database) *map[string]JA3Entry { return &db.Fingerprints.Mobile },
+	"apps":      func(db *JA3Database) *map[string]JA3Entry { return &db.Fingerprints.Apps },
+}
+
+// http2Categories are the HTTP2Database.Fingerprints sub-maps an http2json
+// source's Category can target.
+var http2Categories = map[string]func(*HTTP2Database) *map[string]HTTP2Entry{
+	"browsers":      func(db *HTTP2Database) *map[string]HTTP2Entry { return &db.Fingerprints.Browsers },
+	"impersonators": func(db *HTTP2Database) *map[string]HTTP2Entry { return &db.Fingerprints.Impersonators },
+	"libraries":     func(db *HTTP2Database) *map[string]HTTP2Entry { return &db.Fingerprints.Libraries },
+}
+
+// lowTrustBlockedCategories are categories a "low" trust source is never
+// allowed to write into, regardless of what its Category field claims -
+// a compromised or mistaken low-trust feed shouldn't be able to plant a
+// false "impersonator"/"malware" verdict that other requests get judged
+// against.
+var lowTrustBlockedCategories = map[string]bool{
+	"impersonators": true,
+	"malware":       true,
+}
+
+// syncOne fetches and merges a single source into the on-disk database file
+// its Format targets, then atomically rewrites that file.
+func syncOne(ctx context.Context, client *http.Client, dataDir string, src syncSource) error {
+	if src.Trust == "low" && lowTrustBlockedCategories[src.Category] {
+		return fmt.Errorf("refusing to merge %q category from a low-trust source", src.Category)
+	}
+
+	body, err := fetchSource(ctx, client, src)
+	if err != nil {
+		return fmt.Errorf("fetching: %w", err)
+	}
+
+	switch src.Format {
+	case "ja3csv":
+		target, ok := ja3Categories[src.Category]
+		if !ok {
+			return fmt.Errorf("unknown ja3csv category %q", src.Category)
+		}
+		entries, err := parseJA3CSV(body)
+		if err != nil {
+			return err
+		}
+		return mergeJA3(dataDir, target, entries)
+
+	case "ja4json":
+		entries, err := parseJA4JSON(body)
+		if err != nil {
+			return err
+		}
+		return mergeJA4(dataDir, entries)
+
+	case "http2json":
+		target, ok := http2Categories[src.Category]
+		if !ok {
+			return fmt.Errorf("unknown http2json category %q", src.Category)
+		}
+		entries, err := parseHTTP2JSON(body)
+		if err != nil {
+			return err
+		}
+		return mergeHTTP2(dataDir, target, entries)
+
+	default:
+		return fmt.Errorf("unknown format %q", src.Format)
+	}
+}
+
+// mergeJA3 reads ja3_fingerprints.json (or starts from an empty database if
+// it doesn't exist yet), merges entries into the sub-map target selects, and
+// atomically rewrites the file.
+func mergeJA3(dataDir string, target func(*JA3Database) *map[string]JA3Entry, entries map[string]JA3Entry) error {
+	path := filepath.Join(dataDir, "ja3_fingerprints.json")
+	var db JA3Database
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &db); err != nil {
+			return fmt.Errorf("parsing existing %s: %w", path, err)
+		}
+	}
+
+	dest := target(&db)
+	if *dest == nil {
+		*dest = make(map[string]JA3Entry)
+	}
+	for hash, entry := range entries {
+		(*dest)[hash] = entry
+	}
+	db.LastUpdated = time.Now().UTC().Format(time.RFC3339)
+
+	return writeJSONAtomic(path, &db)
+}
+
+// mergeJA4 reads ja4_fingerprints.json, merges entries into
+// KnownFingerprints, and atomically rewrites the file.
+func mergeJA4(dataDir string, entries []ja4JSONEntry) error {
+	path := filepath.Join(dataDir, "ja4_fingerprints.json")
+	var db JA4Database
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &db); err != nil {
+			return fmt.Errorf("parsing existing %s: %w", path, err)
+		}
+	}
+
+	if db.KnownFingerprints == nil {
+		db.KnownFingerprints = make(map[string]struct {
+			Pattern string   `json:"pattern"`
+			Clients []string `json:"clients"`
+			Notes   string   `json:"notes"`
+		})
+	}
+	for _, e := range entries {
+		db.KnownFingerprints[e.Hash] = struct {
+			Pattern string   `json:"pattern"`
+			Clients []string `json:"clients"`
+			Notes   string   `json:"notes"`
+		}{Pattern: e.Pattern, Clients: e.Clients, Notes: e.Notes}
+	}
+	db.LastUpdated = time.Now().UTC().Format(time.RFC3339)
+
+	return writeJSONAtomic(path, &db)
+}
+
+// mergeHTTP2 reads http2_fingerprints.json, merges entries into the
+// sub-map target selects, and atomically rewrites the file.
+func mergeHTTP2(dataDir string, target func(*HTTP2Database) *map[string]HTTP2Entry, entries []http2JSONEntry) error {
+	path := filepath.Join(dataDir, "http2_fingerprints.json")
+	var db HTTP2Database
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &db); err != nil {
+			return fmt.Errorf("parsing existing %s: %w", path, err)
+		}
+	}
+
+	dest := target(&db)
+	if *dest == nil {
+		*dest = make(map[string]HTTP2Entry)
+	}
+	for _, e := range entries {
+		(*dest)[e.Akamai] = e.HTTP2Entry
+	}
+	db.LastUpdated = time.Now().UTC().Format(time.RFC3339)
+
+	return writeJSONAtomic(path, &db)
+}
+
+// Sync runs one fetch-merge-write pass over every source in
+// ./data/sources.json, then triggers the same Reload() StartHotReload/Watch
+// use, so the freshly merged entries are live without a restart. Expose this
+// to an operator who wants a single on-demand pull via the -sync CLI flag
+// (see main.go), or call it periodically via StartSync.
+func (db *FingerprintDatabase) Sync(ctx context.Context) error {
+	dataDir := findDataDir()
+	sourcesPath := filepath.Join(dataDir, "sources.json")
+
+	sources, err := loadSyncSources(sourcesPath)
+	if err != nil {
+		return err
+	}
+	if len(sources) == 0 {
+		log.Printf("[Sync] No sources configured at %s, nothing to do", sourcesPath)
+		return nil
+	}
+
+	client := &http.Client{Timeout: syncHTTPTimeout}
+
+	var failed int
+	for _, src := range sources {
+		if err := syncOne(ctx, client, dataDir, src); err != nil {
+			log.Printf("[Sync] %s: %v", src.URL, err)
+			failed++
+			continue
+		}
+		log.Printf("[Sync] Merged %s (%s/%s, trust=%s)", src.URL, src.Format, src.Category, src.Trust)
+	}
+
+	if failed == len(sources) {
+		return fmt.Errorf("all %d sync sources failed", failed)
+	}
+
+	return db.Reload()
+}
+
+// StartSync runs Sync on a ticker (default DefaultSyncInterval, overridable
+// via the -sync-interval flag in main.go), logging failures rather than
+// stopping - the same "keep serving the last good data" posture
+// StartHotReload takes toward a bad edit on disk.
+func (db *FingerprintDatabase) StartSync(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			if err := db.Sync(context.Background()); err != nil {
+				log.Printf("[Sync] Periodic sync failed: %v", err)
+			}
+		}
+	}()
+}
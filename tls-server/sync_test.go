@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFetchSourceCanned(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hash,name\nd41d8cd98f00,TestClient\n"))
+	}))
+	defer srv.Close()
+
+	client := srv.Client()
+	body, err := fetchSource(context.Background(), client, syncSource{URL: srv.URL})
+	if err != nil {
+		t.Fatalf("fetchSource: %v", err)
+	}
+	if string(body) != "hash,name\nd41d8cd98f00,TestClient\n" {
+		t.Errorf("fetchSource returned %q", body)
+	}
+}
+
+func TestFetchSourceNonOKStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	if _, err := fetchSource(context.Background(), srv.Client(), syncSource{URL: srv.URL}); err == nil {
+		t.Fatal("expected an error for a non-200 response, got nil")
+	}
+}
+
+func TestFetchSourceOversizeBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, syncMaxBodyBytes+1))
+	}))
+	defer srv.Close()
+
+	if _, err := fetchSource(context.Background(), srv.Client(), syncSource{URL: srv.URL}); err == nil {
+		t.Fatal("expected an error for a body exceeding syncMaxBodyBytes, got nil")
+	}
+}
+
+func TestFetchSourceSHA256Pinning(t *testing.T) {
+	payload := []byte("canned upstream payload")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(payload)
+	}))
+	defer srv.Close()
+
+	sum := sha256.Sum256(payload)
+	goodHash := hex.EncodeToString(sum[:])
+
+	if _, err := fetchSource(context.Background(), srv.Client(), syncSource{URL: srv.URL, SHA256: goodHash}); err != nil {
+		t.Errorf("fetchSource with a matching sha256 pin returned an error: %v", err)
+	}
+	if _, err := fetchSource(context.Background(), srv.Client(), syncSource{URL: srv.URL, SHA256: "deadbeef"}); err == nil {
+		t.Error("expected an error for a mismatched sha256 pin, got nil")
+	}
+}
+
+func TestParseJA3CSV(t *testing.T) {
+	entries, err := parseJA3CSV([]byte("hash,name,version\nabc123,Chrome,131\ndef456,Firefox,121\n"))
+	if err != nil {
+		t.Fatalf("parseJA3CSV: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if entries["abc123"].Name != "Chrome" || entries["abc123"].Version != "131" {
+		t.Errorf("entries[abc123] = %+v", entries["abc123"])
+	}
+}
+
+func TestParseJA4JSONAndHTTP2JSON(t *testing.T) {
+	ja4, err := parseJA4JSON([]byte(`[{"hash":"t13d1516h2","pattern":"chrome","clients":["Chrome"],"notes":"n/a"}]`))
+	if err != nil {
+		t.Fatalf("parseJA4JSON: %v", err)
+	}
+	if len(ja4) != 1 || ja4[0].Hash != "t13d1516h2" {
+		t.Errorf("parseJA4JSON = %+v", ja4)
+	}
+
+	http2, err := parseHTTP2JSON([]byte(`[{"akamai":"1:65536|0|0|m,a,s,p","name":"Chrome 131"}]`))
+	if err != nil {
+		t.Fatalf("parseHTTP2JSON: %v", err)
+	}
+	if len(http2) != 1 || http2[0].Akamai != "1:65536|0|0|m,a,s,p" || http2[0].Name != "Chrome 131" {
+		t.Errorf("parseHTTP2JSON = %+v", http2)
+	}
+}
+
+// TestSyncOneMergesJA3CSV drives syncOne end to end against an httptest.Server
+// serving a canned ja3csv payload, and checks the merged entry lands in
+// ja3_fingerprints.json under the requested category.
+func TestSyncOneMergesJA3CSV(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hash,name,version\nabc123,Chrome,131\n"))
+	}))
+	defer srv.Close()
+
+	dataDir := t.TempDir()
+	src := syncSource{URL: srv.URL, Format: "ja3csv", Category: "browsers", Trust: "high"}
+	if err := syncOne(context.Background(), srv.Client(), dataDir, src); err != nil {
+		t.Fatalf("syncOne: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dataDir, "ja3_fingerprints.json"))
+	if err != nil {
+		t.Fatalf("reading merged ja3_fingerprints.json: %v", err)
+	}
+	var db JA3Database
+	if err := json.Unmarshal(data, &db); err != nil {
+		t.Fatalf("unmarshaling merged database: %v", err)
+	}
+	if entry, ok := db.Fingerprints.Browsers["abc123"]; !ok || entry.Name != "Chrome" {
+		t.Errorf("Fingerprints.Browsers[abc123] = %+v, ok=%v", entry, ok)
+	}
+}
+
+// TestSyncOneLowTrustBlockedCategory confirms a low-trust source can't write
+// into a blocked category like "impersonators", regardless of what it claims.
+func TestSyncOneLowTrustBlockedCategory(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"akamai":"1:65536|0|0|m,a,s,p","name":"evil"}]`))
+	}))
+	defer srv.Close()
+
+	dataDir := t.TempDir()
+	src := syncSource{URL: srv.URL, Format: "http2json", Category: "impersonators", Trust: "low"}
+	if err := syncOne(context.Background(), srv.Client(), dataDir, src); err == nil {
+		t.Fatal("expected syncOne to refuse a low-trust source writing to a blocked category")
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "http2_fingerprints.json")); !os.IsNotExist(err) {
+		t.Errorf("expected no http2_fingerprints.json to be written, stat err = %v", err)
+	}
+}
+
+// TestSyncNoSources confirms Sync is a no-op (not an error) when
+// sources.json is absent, mirroring how Load() treats every other
+// optional corpus file.
+func TestSyncNoSources(t *testing.T) {
+	sources, err := loadSyncSources(filepath.Join(t.TempDir(), "sources.json"))
+	if err != nil {
+		t.Fatalf("loadSyncSources on a missing file: %v", err)
+	}
+	if sources != nil {
+		t.Errorf("loadSyncSources on a missing file = %v, want nil", sources)
+	}
+}
@@ -10,7 +10,6 @@ import (
 	"fmt"
 	"log"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/google/gopacket"
@@ -18,8 +17,19 @@ import (
 	"github.com/google/gopacket/pcap"
 )
 
+// TCP packet types we fingerprint. A single connection can yield both a
+// client ("syn") and a server ("syn_ack") fingerprint.
+const (
+	PacketTypeSYN    = "syn"
+	PacketTypeSYNACK = "syn_ack"
+	PacketTypeRST    = "rst"
+)
+
 // TCPIPFingerprint represents TCP/IP layer fingerprint
 type TCPIPFingerprint struct {
+	// PacketType identifies which handshake packet this fingerprint was derived from
+	PacketType string `json:"packet_type,omitempty"`
+
 	// IP layer
 	TTL        int    `json:"ttl"`         // Observed TTL
 	InitialTTL int    `json:"initial_ttl"` // Inferred initial TTL (64/128/255)
@@ -41,12 +51,22 @@ type TCPIPFingerprint struct {
 	// Fingerprint hash
 	Signature string `json:"signature"` // Full fingerprint hash
 
+	// JA4T: the JA4+ family's TCP fingerprint (window size, option kinds in
+	// order, MSS, window scale). See buildJA4T in ja4plus.go.
+	JA4T string `json:"ja4t,omitempty"`
+
 	// Inference results
 	InferredOS   string `json:"inferred_os"`   // Windows/Linux/macOS/iOS/Android
 	OSConfidence string `json:"os_confidence"` // high/medium/low
 
 	// Consistency check results
 	Anomalies []string `json:"anomalies,omitempty"`
+
+	// NAT/multi-host detection from TSval clock-skew clustering
+	NAT *NATAnalysis `json:"nat,omitempty"`
+
+	// Link/MTU inference from MSS and option layout (tunnels, VPNs, PPPoE, etc.)
+	Link *LinkAnalysis `json:"link,omitempty"`
 }
 
 // TCPOption represents a single TCP option
@@ -63,9 +83,20 @@ type TCPTimestamp struct {
 	Uptime string `json:"uptime,omitempty"` // Inferred system uptime
 }
 
-// Global storage: IP -> TCP fingerprint
-var tcpFingerprintStore = make(map[string]*TCPIPFingerprint)
-var tcpStoreMutex sync.RWMutex
+// tcpFingerprintCacheCapacity / tcpFingerprintCacheMaxAge bound the TTL+LRU cache used
+// for all captured TCP/IP fingerprints (client SYN, peer SYN-ACK, and RST), replacing
+// the old "wipe everything past 10000 entries" behavior.
+const (
+	tcpFingerprintCacheCapacity = 10000
+	tcpFingerprintCacheMaxAge   = 30 * time.Minute
+)
+
+// tcpFingerprintStoreByType is the TTL+LRU cache, keyed by "ip|packetType".
+var tcpFingerprintStoreByType = NewTCPFingerprintCache(tcpFingerprintCacheCapacity, tcpFingerprintCacheMaxAge)
+
+func typeStoreKey(ip, packetType string) string {
+	return ip + "|" + packetType
+}
 
 // TCP option kind constants
 const (
@@ -78,8 +109,45 @@ const (
 	TCPOptionKindTimestamp  = 8
 )
 
-// StartTCPCapture starts the TCP packet capture goroutine
+// CaptureMode selects which TCP control packets are captured and fingerprinted.
+type CaptureMode string
+
+const (
+	CaptureModeSYN     CaptureMode = "syn"      // inbound client SYN (default: client fingerprinting)
+	CaptureModeSYNACK  CaptureMode = "syn_ack"  // outbound/peer SYN-ACK (server-side fingerprinting)
+	CaptureModeRST     CaptureMode = "rst"      // RST/RST-ACK (reverse proxies, load balancers, embedded stacks)
+	CaptureModeAll     CaptureMode = "all"      // capture all three modes on the same interface
+)
+
+// bpfFilterForMode builds the BPF filter string for a given capture mode and port
+func bpfFilterForMode(mode CaptureMode, port int) string {
+	switch mode {
+	case CaptureModeSYNACK:
+		return fmt.Sprintf("tcp src port %d and tcp[tcpflags] & (tcp-syn|tcp-ack) == (tcp-syn|tcp-ack)", port)
+	case CaptureModeRST:
+		return fmt.Sprintf("tcp port %d and tcp[tcpflags] & tcp-rst != 0", port)
+	case CaptureModeAll:
+		return fmt.Sprintf("tcp port %d and (tcp[tcpflags] & tcp-syn != 0 or tcp[tcpflags] & tcp-rst != 0)", port)
+	default: // CaptureModeSYN
+		return fmt.Sprintf("tcp dst port %d and tcp[tcpflags] & tcp-syn != 0 and tcp[tcpflags] & tcp-ack == 0", port)
+	}
+}
+
+// StartTCPCapture starts the TCP packet capture goroutine in the default (SYN-only) mode
+// using the libpcap backend.
 func StartTCPCapture(iface string, port int) error {
+	return StartTCPCaptureMode(iface, port, CaptureModeSYN)
+}
+
+// StartTCPCaptureMode starts packet capture for a specific capture mode using the
+// libpcap backend.
+func StartTCPCaptureMode(iface string, port int, mode CaptureMode) error {
+	return StartTCPCaptureWithConfig(iface, port, mode, DefaultCaptureConfig)
+}
+
+// StartTCPCaptureWithConfig starts packet capture for a specific capture mode and
+// CaptureBackend (libpcap, afpacket, or ebpf - see CaptureConfig).
+func StartTCPCaptureWithConfig(iface string, port int, mode CaptureMode, cfg CaptureConfig) error {
 	// Find available interfaces if not specified
 	if iface == "" {
 		interfaces := findCaptureInterfaces()
@@ -89,7 +157,7 @@ func StartTCPCapture(iface string, port int) error {
 		}
 		// Start capture on all found interfaces
 		for _, ifaceName := range interfaces {
-			if err := startCaptureOnInterface(ifaceName, port); err != nil {
+			if err := startCaptureOnInterface(ifaceName, port, mode, cfg); err != nil {
 				log.Printf("[TCP] Warning: Failed to start capture on %s: %v", ifaceName, err)
 			}
 		}
@@ -97,30 +165,22 @@ func StartTCPCapture(iface string, port int) error {
 	}
 
 	// Use specified interface
-	return startCaptureOnInterface(iface, port)
+	return startCaptureOnInterface(iface, port, mode, cfg)
 }
 
-// startCaptureOnInterface starts packet capture on a single interface
-func startCaptureOnInterface(iface string, port int) error {
-	// BPF filter: only capture SYN packets to our port
-	filter := fmt.Sprintf("tcp dst port %d and tcp[tcpflags] & tcp-syn != 0 and tcp[tcpflags] & tcp-ack == 0", port)
-
-	handle, err := pcap.OpenLive(iface, 1600, true, pcap.BlockForever)
+// startCaptureOnInterface starts packet capture on a single interface via the
+// CaptureBackend selected by cfg.
+func startCaptureOnInterface(iface string, port int, mode CaptureMode, cfg CaptureConfig) error {
+	backend, err := NewCaptureBackend(iface, port, mode, cfg)
 	if err != nil {
-		return fmt.Errorf("failed to open interface %s: %v", iface, err)
-	}
-
-	if err := handle.SetBPFFilter(filter); err != nil {
-		handle.Close()
-		return fmt.Errorf("failed to set BPF filter: %v", err)
+		return err
 	}
 
-	log.Printf("[TCP] Capturing SYN packets on interface %s, port %d", iface, port)
+	log.Printf("[TCP] Capturing %s packets on interface %s, port %d (backend=%s)", mode, iface, port, cfg.Backend)
 
 	go func() {
-		defer handle.Close()
-		packetSource := gopacket.NewPacketSource(handle, handle.LinkType())
-		for packet := range packetSource.Packets() {
+		defer backend.Close()
+		for packet := range backend.Packets() {
 			processTCPPacket(packet)
 		}
 	}()
@@ -204,12 +264,21 @@ func processTCPPacket(packet gopacket.Packet) {
 	}
 	tcp := tcpLayer.(*layers.TCP)
 
-	// Only process SYN packets (not SYN-ACK)
-	if !tcp.SYN || tcp.ACK {
+	// Classify the packet: plain SYN (client), SYN-ACK (peer/server), or RST
+	var packetType string
+	switch {
+	case tcp.SYN && !tcp.ACK:
+		packetType = PacketTypeSYN
+	case tcp.SYN && tcp.ACK:
+		packetType = PacketTypeSYNACK
+	case tcp.RST:
+		packetType = PacketTypeRST
+	default:
 		return
 	}
 
 	fp := &TCPIPFingerprint{
+		PacketType: packetType,
 		TTL:        ttl,
 		InitialTTL: guessInitialTTL(ttl),
 		IPVersion:  ipVersion,
@@ -217,22 +286,38 @@ func processTCPPacket(packet gopacket.Packet) {
 		WindowSize: int(tcp.Window),
 	}
 
-	// Parse TCP options
+	// Parse TCP options (RST packets frequently carry none)
 	fp.Options, fp.OptionsStr, fp.MSS, fp.WindowScale, fp.Timestamp = parseTCPOptions(tcp.Options)
 
+	// NAT / multi-host detection via TSval clock-skew clustering (client SYNs only,
+	// since that's where we see the claimed client's own timestamp clock)
+	if packetType == PacketTypeSYN && fp.Timestamp != nil {
+		nat := analyzeTSvalForNAT(srcIP, fp.Timestamp.TSval, time.Now())
+		if nat.NATSuspected || nat.TimestampReset {
+			fp.NAT = nat
+		}
+	}
+
 	// Infer operating system
 	fp.InferredOS, fp.OSConfidence = inferOS(fp)
 
+	// Infer link type / MTU / tunnel suspicion from MSS and option layout
+	fp.Link = analyzeLinkFromMSS(fp)
+
 	// Generate signature hash
 	fp.Signature = generateSignature(fp)
 
-	// Store fingerprint
-	tcpStoreMutex.Lock()
-	tcpFingerprintStore[srcIP] = fp
-	tcpStoreMutex.Unlock()
+	// JA4T only makes sense for the client's own SYN (SYN-ACK/RST options
+	// describe our side of the handshake, not theirs)
+	if packetType == PacketTypeSYN {
+		fp.JA4T = buildJA4T(fp.WindowSize, fp.Options, fp.MSS, fp.WindowScale)
+	}
 
-	log.Printf("[TCP] SYN from %s: TTL=%d(%d), Win=%d, Options=%s, OS=%s",
-		srcIP, ttl, fp.InitialTTL, fp.WindowSize, fp.OptionsStr, fp.InferredOS)
+	// Store fingerprint, keyed by (ip, type) so client/server fingerprints coexist
+	tcpFingerprintStoreByType.Set(typeStoreKey(srcIP, packetType), fp)
+
+	log.Printf("[TCP] %s from %s: TTL=%d(%d), Win=%d, Options=%s, OS=%s",
+		strings.ToUpper(packetType), srcIP, ttl, fp.InitialTTL, fp.WindowSize, fp.OptionsStr, fp.InferredOS)
 }
 
 // formatIPv4Flags formats IPv4 flags to string
@@ -367,8 +452,14 @@ func estimateUptime(tsval uint32) string {
 	return fmt.Sprintf("%ds", seconds)
 }
 
-// inferOS infers operating system from TCP/IP fingerprint
+// inferOS infers operating system from TCP/IP fingerprint.
+// When a p0f signature database is loaded, prefer its fuzzy-matched verdict;
+// fall back to the hardcoded TTL/window heuristics otherwise.
 func inferOS(fp *TCPIPFingerprint) (string, string) {
+	if label, _, confidence := MatchSignature(fp); confidence != "" {
+		return label, confidence
+	}
+
 	// Check for TCP Timestamp option
 	hasTimestamp := fp.Timestamp != nil
 
@@ -429,11 +520,28 @@ func generateSignature(fp *TCPIPFingerprint) string {
 	return hex.EncodeToString(hash[:])
 }
 
-// GetTCPFingerprint retrieves TCP fingerprint for an IP address
+// GetTCPFingerprint retrieves the client SYN fingerprint for an IP address,
+// bumping its LastSeen in the cache.
 func GetTCPFingerprint(ip string) *TCPIPFingerprint {
-	tcpStoreMutex.RLock()
-	defer tcpStoreMutex.RUnlock()
-	return tcpFingerprintStore[ip]
+	return tcpFingerprintStoreByType.Get(typeStoreKey(ip, PacketTypeSYN))
+}
+
+// GetTCPFingerprintByType retrieves a fingerprint for a specific packet type
+// ("syn", "syn_ack", or "rst") observed from the given IP, bumping its LastSeen.
+func GetTCPFingerprintByType(ip, packetType string) *TCPIPFingerprint {
+	return tcpFingerprintStoreByType.Get(typeStoreKey(ip, packetType))
+}
+
+// TCPFingerprintCacheStats exposes cache observability counters for the /api/all
+// endpoint and similar diagnostics.
+func TCPFingerprintCacheStats() CacheStats {
+	return tcpFingerprintStoreByType.Stats()
+}
+
+// SnapshotTCPFingerprints returns a stable copy of all cached TCP fingerprints for
+// JSON serialization without holding the cache's internal lock.
+func SnapshotTCPFingerprints() map[string]*TCPIPFingerprint {
+	return tcpFingerprintStoreByType.Snapshot()
 }
 
 // CheckConsistency checks for anomalies between TCP fingerprint and User-Agent
@@ -482,6 +590,16 @@ func CheckConsistency(tcpFp *TCPIPFingerprint, userAgent string) []string {
 			"MINIMAL_OPTIONS: Very few TCP options, unusual for modern browsers")
 	}
 
+	// 6. NAT / multi-host clock-skew anomalies
+	anomalies = append(anomalies, natAnomalyStrings(tcpFp.NAT)...)
+
+	// 7. Tunnel/VPN link type contradicting the claimed OS
+	if tcpFp.Link != nil && tcpFp.Link.TunnelSuspected && claimedOS != "" {
+		anomalies = append(anomalies,
+			fmt.Sprintf("TUNNEL_DETECTED: link layer looks like %s (MTU %d), but UA claims %s",
+				tcpFp.Link.LinkType, tcpFp.Link.InferredMTU, claimedOS))
+	}
+
 	return anomalies
 }
 
@@ -535,21 +653,21 @@ func osMatches(claimed, inferred string) bool {
 	return false
 }
 
-// CleanupOldFingerprints removes fingerprints older than the specified duration
+// CleanupOldFingerprints periodically sweeps entries older than maxAge from the
+// TTL+LRU cache. Unlike the old behavior, this never wipes the whole store at once:
+// age-based eviction runs here, and LRU eviction runs inline on every Set() once the
+// cache exceeds its capacity.
 func CleanupOldFingerprints(maxAge time.Duration) {
-	// Note: This is a simplified cleanup. In production, you'd want to
-	// track timestamps for each fingerprint entry.
-	ticker := time.NewTicker(maxAge)
+	ticker := time.NewTicker(maxAge / 2)
 	go func() {
 		for range ticker.C {
-			tcpStoreMutex.Lock()
-			// Clear all fingerprints periodically
-			// In production, implement proper timestamp-based cleanup
-			if len(tcpFingerprintStore) > 10000 {
-				tcpFingerprintStore = make(map[string]*TCPIPFingerprint)
-				log.Printf("[TCP] Cleared fingerprint store (exceeded 10000 entries)")
+			before := tcpFingerprintStoreByType.Stats()
+			tcpFingerprintStoreByType.SweepExpired()
+			after := tcpFingerprintStoreByType.Stats()
+			if after.Evictions > before.Evictions {
+				log.Printf("[TCP] Swept %d expired fingerprint(s), cache size now %d",
+					after.Evictions-before.Evictions, after.Size)
 			}
-			tcpStoreMutex.Unlock()
 		}
 	}()
 }
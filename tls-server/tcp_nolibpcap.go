@@ -0,0 +1,660 @@
+//go:build nolibpcap && (linux || darwin)
+// +build nolibpcap
+// +build linux darwin
+
+package main
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/bpf"
+)
+
+// This is the -tags nolibpcap capture path for Linux and Darwin: a cgo-free
+// replacement for tcp.go's gopacket/pcap backend, so the collector can run in
+// minimal containers without libpcap installed. It reimplements the same
+// TCPIPFingerprint extraction tcp.go does (same struct shape, same p0f.go/
+// nat_detection.go/link_analysis.go/ja4plus.go helpers downstream), just
+// sourced from a raw socket instead of a pcap handle, and filtered by a
+// classic BPF program assembled with golang.org/x/net/bpf instead of a
+// tcpdump-syntax filter string. Everything other than packet *ingestion* -
+// option parsing, OS inference, signature hashing, the TTL+LRU cache - is
+// duplicated here rather than shared with tcp.go, the same way tcp_stub.go
+// already duplicates the types for its own build tag; the per-platform raw
+// socket code lives in capture_raw_linux.go / capture_raw_bsd.go.
+
+// TCP packet types we fingerprint. A single connection can yield both a
+// client ("syn") and a server ("syn_ack") fingerprint.
+const (
+	PacketTypeSYN    = "syn"
+	PacketTypeSYNACK = "syn_ack"
+	PacketTypeRST    = "rst"
+)
+
+// TCPIPFingerprint represents TCP/IP layer fingerprint
+type TCPIPFingerprint struct {
+	// PacketType identifies which handshake packet this fingerprint was derived from
+	PacketType string `json:"packet_type,omitempty"`
+
+	// IP layer
+	TTL        int    `json:"ttl"`         // Observed TTL
+	InitialTTL int    `json:"initial_ttl"` // Inferred initial TTL (64/128/255)
+	IPVersion  int    `json:"ip_version"`  // always 4 - see processRawIPPacket
+	IPFlags    string `json:"ip_flags"`    // DF, MF, etc.
+
+	// TCP layer
+	WindowSize  int `json:"window_size"`  // TCP initial window size
+	MSS         int `json:"mss"`          // Maximum Segment Size
+	WindowScale int `json:"window_scale"` // Window Scale factor
+
+	// TCP Options
+	Options    []TCPOption `json:"options"`     // Full options list
+	OptionsStr string      `json:"options_str"` // Options signature: "M1460,S,T,N,W7"
+
+	// TCP Timestamp (for uptime inference)
+	Timestamp *TCPTimestamp `json:"timestamp,omitempty"`
+
+	// Fingerprint hash
+	Signature string `json:"signature"` // Full fingerprint hash
+
+	// JA4T: the JA4+ family's TCP fingerprint (window size, option kinds in
+	// order, MSS, window scale). See buildJA4T in ja4plus.go.
+	JA4T string `json:"ja4t,omitempty"`
+
+	// Inference results
+	InferredOS   string `json:"inferred_os"`   // Windows/Linux/macOS/iOS/Android
+	OSConfidence string `json:"os_confidence"` // high/medium/low
+
+	// Consistency check results
+	Anomalies []string `json:"anomalies,omitempty"`
+
+	// NAT/multi-host detection from TSval clock-skew clustering
+	NAT *NATAnalysis `json:"nat,omitempty"`
+
+	// Link/MTU inference from MSS and option layout (tunnels, VPNs, PPPoE, etc.)
+	Link *LinkAnalysis `json:"link,omitempty"`
+}
+
+// TCPOption represents a single TCP option
+type TCPOption struct {
+	Kind  int    `json:"kind"`            // Option type: 2=MSS, 3=WScale, 4=SACK, 8=Timestamp
+	Name  string `json:"name"`            // Option name
+	Value int    `json:"value,omitempty"` // Option value
+}
+
+// TCPTimestamp represents TCP timestamp option values
+type TCPTimestamp struct {
+	TSval  uint32 `json:"tsval"`            // Sender timestamp
+	TSecr  uint32 `json:"tsecr"`            // Echo reply timestamp
+	Uptime string `json:"uptime,omitempty"` // Inferred system uptime
+}
+
+// CaptureMode selects which TCP control packets are captured and fingerprinted.
+type CaptureMode string
+
+const (
+	CaptureModeSYN    CaptureMode = "syn"     // inbound client SYN (default: client fingerprinting)
+	CaptureModeSYNACK CaptureMode = "syn_ack" // outbound/peer SYN-ACK (server-side fingerprinting)
+	CaptureModeRST    CaptureMode = "rst"     // RST/RST-ACK (reverse proxies, load balancers, embedded stacks)
+	CaptureModeAll    CaptureMode = "all"     // capture all three modes on the same interface
+)
+
+// CaptureConfig selects and tunes the packet capture backend. The nolibpcap
+// build only ever opens a raw socket, so Backend/RingSizeMB/FanoutWorkers are
+// accepted (so callers built against the libpcap CaptureConfig still
+// compile) but only Backend is consulted, purely for the startup log line.
+type CaptureConfig struct {
+	Backend       string
+	RingSizeMB    int
+	FanoutWorkers int
+}
+
+// DefaultCaptureConfig is used wherever callers don't care about the backend.
+var DefaultCaptureConfig = CaptureConfig{Backend: "raw"}
+
+// TCP option kind constants
+const (
+	TCPOptionKindEndList   = 0
+	TCPOptionKindNOP       = 1
+	TCPOptionKindMSS       = 2
+	TCPOptionKindWScale    = 3
+	TCPOptionKindSACKPerm  = 4
+	TCPOptionKindSACK      = 5
+	TCPOptionKindTimestamp = 8
+)
+
+// tcpFingerprintCacheCapacity / tcpFingerprintCacheMaxAge bound the TTL+LRU
+// cache used for all captured TCP/IP fingerprints (client SYN, peer SYN-ACK,
+// and RST) - see cache.go.
+const (
+	tcpFingerprintCacheCapacity = 10000
+	tcpFingerprintCacheMaxAge   = 30 * time.Minute
+)
+
+// tcpFingerprintStoreByType is the TTL+LRU cache, keyed by "ip|packetType".
+var tcpFingerprintStoreByType = NewTCPFingerprintCache(tcpFingerprintCacheCapacity, tcpFingerprintCacheMaxAge)
+
+func typeStoreKey(ip, packetType string) string {
+	return ip + "|" + packetType
+}
+
+// rawCaptureHandle is what the platform-specific opener (openRawCapture in
+// capture_raw_linux.go / capture_raw_bsd.go) returns: a running background
+// reader that can be torn down.
+type rawCaptureHandle interface {
+	Close()
+}
+
+// StartTCPCapture starts the TCP packet capture goroutine in the default
+// (SYN-only) mode.
+func StartTCPCapture(iface string, port int) error {
+	return StartTCPCaptureMode(iface, port, CaptureModeSYN)
+}
+
+// StartTCPCaptureMode starts packet capture for a specific capture mode.
+func StartTCPCaptureMode(iface string, port int, mode CaptureMode) error {
+	return StartTCPCaptureWithConfig(iface, port, mode, DefaultCaptureConfig)
+}
+
+// StartTCPCaptureWithConfig opens a raw socket (AF_PACKET on Linux, AF_INET
+// SOCK_RAW on Darwin - see openRawCapture) and starts fingerprinting the TCP
+// control packets cfg/mode select. An empty iface captures on every
+// interface the platform's raw socket naturally sees.
+func StartTCPCaptureWithConfig(iface string, port int, mode CaptureMode, cfg CaptureConfig) error {
+	handle, err := openRawCapture(iface, port, mode, cfg)
+	if err != nil {
+		return fmt.Errorf("raw capture: %v", err)
+	}
+
+	log.Printf("[TCP] Capturing %s packets on interface %q, port %d (backend=raw, no libpcap)", mode, iface, port)
+	_ = handle // kept alive by its own goroutine; nothing else to do with it here
+
+	return nil
+}
+
+// classicBPFProgram assembles the classic BPF program matching only the TCP
+// control packets mode/port care about, for packets that still carry their
+// Ethernet header (the AF_PACKET case on Linux): IPv4, TCP, destination port
+// == port, with the flag pattern bpfFilterForMode (tcp.go) describes as a
+// tcpdump filter string. IP/TCP options are handled correctly via
+// LoadMemShift; IPv6 is not matched (this capture path doesn't fingerprint
+// IPv6 traffic - see TCPIPFingerprint.IPVersion always being 4 below).
+func classicBPFProgram(mode CaptureMode, port int) ([]bpf.Instruction, error) {
+	return buildClassicBPF(14, mode, port)
+}
+
+// classicBPFProgramNoEthernet is classicBPFProgram for raw sockets that
+// deliver the IP datagram directly with no link-layer header (AF_INET
+// SOCK_RAW, the Darwin/BSD capture path).
+func classicBPFProgramNoEthernet(mode CaptureMode, port int) ([]bpf.Instruction, error) {
+	return buildClassicBPF(0, mode, port)
+}
+
+// buildClassicBPF builds the shared instruction sequence for both of the
+// above, parameterized by l2Len (0 or 14) so the same TCP/IP field offsets
+// can be expressed once. Every conditional jump's false branch lands on the
+// single reject instruction at the end; skip counts are patched in once the
+// final layout (and therefore the reject instruction's index) is known.
+func buildClassicBPF(l2Len uint32, mode CaptureMode, port int) ([]bpf.Instruction, error) {
+	if port <= 0 || port > 0xffff {
+		return nil, fmt.Errorf("invalid port %d", port)
+	}
+
+	var prog []bpf.Instruction
+	var conditionals []int // indices of JumpIf instructions whose SkipFalse must reach the reject
+
+	addConditional := func(ji bpf.JumpIf) {
+		conditionals = append(conditionals, len(prog))
+		prog = append(prog, ji)
+	}
+
+	if l2Len > 0 {
+		prog = append(prog, bpf.LoadAbsolute{Off: 12, Size: 2}) // A = EtherType
+		addConditional(bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0x0800})
+	}
+
+	prog = append(prog, bpf.LoadAbsolute{Off: l2Len + 9, Size: 1}) // A = IP protocol
+	addConditional(bpf.JumpIf{Cond: bpf.JumpEqual, Val: 6})        // TCP
+
+	prog = append(prog,
+		bpf.LoadMemShift{Off: l2Len},                    // X = IHL*4
+		bpf.LoadIndirect{Off: l2Len + 2, Size: 2},        // A = TCP destination port
+	)
+	addConditional(bpf.JumpIf{Cond: bpf.JumpEqual, Val: uint32(port)})
+
+	prog = append(prog, bpf.LoadIndirect{Off: l2Len + 13, Size: 1}) // A = TCP flags
+
+	switch mode {
+	case CaptureModeSYNACK: // flags & (SYN|ACK) == (SYN|ACK)
+		prog = append(prog, bpf.ALUOpConstant{Op: bpf.ALUOpAnd, Val: 0x12})
+		addConditional(bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0x12})
+	case CaptureModeRST: // flags & RST != 0
+		addConditional(bpf.JumpIf{Cond: bpf.JumpBitsSet, Val: 0x04})
+	case CaptureModeAll: // flags & (SYN|RST) != 0
+		addConditional(bpf.JumpIf{Cond: bpf.JumpBitsSet, Val: 0x06})
+	default: // CaptureModeSYN: flags & (SYN|ACK) == SYN
+		prog = append(prog, bpf.ALUOpConstant{Op: bpf.ALUOpAnd, Val: 0x12})
+		addConditional(bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0x02})
+	}
+
+	prog = append(prog, bpf.RetConstant{Val: 0x40000}) // accept, whole packet
+	rejectIdx := len(prog)
+	prog = append(prog, bpf.RetConstant{Val: 0}) // reject
+
+	for _, idx := range conditionals {
+		ji := prog[idx].(bpf.JumpIf)
+		ji.SkipFalse = uint8(rejectIdx - idx - 1)
+		prog[idx] = ji
+	}
+
+	return prog, nil
+}
+
+// processRawIPPacket parses one IPv4 datagram (no link-layer header - callers
+// strip that first, see capture_raw_linux.go/capture_raw_bsd.go), classifies
+// it against mode/port the same way afpacketMatchesMode does for the libpcap
+// build, and on a match runs the same fingerprint extraction processTCPPacket
+// does in tcp.go.
+func processRawIPPacket(ipData []byte, mode CaptureMode, port int) {
+	if len(ipData) < 20 || ipData[0]>>4 != 4 {
+		return // not IPv4 - this capture path doesn't attempt IPv6 (see classicBPFProgram)
+	}
+	ihl := int(ipData[0]&0x0f) * 4
+	if ihl < 20 || len(ipData) < ihl+20 {
+		return
+	}
+	if ipData[9] != 6 { // protocol != TCP
+		return
+	}
+
+	ttl := int(ipData[8])
+	ipFlags := formatIPv4FlagsRaw(ipData[6])
+	srcIP := net.IP(ipData[12:16]).String()
+
+	tcpData := ipData[ihl:]
+	dstPort := int(binary.BigEndian.Uint16(tcpData[2:4]))
+	if dstPort != port {
+		// Our BPF program already enforces this; re-checking here is cheap
+		// insurance against a filter/parser offset mismatch.
+		return
+	}
+	flags := tcpData[13]
+	dataOffset := int(tcpData[12]>>4) * 4
+	if dataOffset < 20 || len(tcpData) < dataOffset {
+		return
+	}
+
+	var packetType string
+	switch {
+	case flags&0x02 != 0 && flags&0x10 == 0: // SYN, !ACK
+		packetType = PacketTypeSYN
+	case flags&0x02 != 0 && flags&0x10 != 0: // SYN, ACK
+		packetType = PacketTypeSYNACK
+	case flags&0x04 != 0: // RST
+		packetType = PacketTypeRST
+	default:
+		return
+	}
+
+	fp := &TCPIPFingerprint{
+		PacketType: packetType,
+		TTL:        ttl,
+		InitialTTL: guessInitialTTL(ttl),
+		IPVersion:  4,
+		IPFlags:    ipFlags,
+		WindowSize: int(binary.BigEndian.Uint16(tcpData[14:16])),
+	}
+
+	fp.Options, fp.OptionsStr, fp.MSS, fp.WindowScale, fp.Timestamp = parseTCPOptionsRaw(tcpData[20:dataOffset])
+
+	if packetType == PacketTypeSYN && fp.Timestamp != nil {
+		nat := analyzeTSvalForNAT(srcIP, fp.Timestamp.TSval, time.Now())
+		if nat.NATSuspected || nat.TimestampReset {
+			fp.NAT = nat
+		}
+	}
+
+	fp.InferredOS, fp.OSConfidence = inferOS(fp)
+	fp.Link = analyzeLinkFromMSS(fp)
+	fp.Signature = generateSignature(fp)
+
+	if packetType == PacketTypeSYN {
+		fp.JA4T = buildJA4T(fp.WindowSize, fp.Options, fp.MSS, fp.WindowScale)
+	}
+
+	tcpFingerprintStoreByType.Set(typeStoreKey(srcIP, packetType), fp)
+
+	log.Printf("[TCP] %s from %s: TTL=%d(%d), Win=%d, Options=%s, OS=%s",
+		strings.ToUpper(packetType), srcIP, ttl, fp.InitialTTL, fp.WindowSize, fp.OptionsStr, fp.InferredOS)
+}
+
+// formatIPv4FlagsRaw formats the IPv4 header's 3 flag bits (top 3 bits of the
+// flags+fragment-offset field at header offset 6) the same way
+// formatIPv4Flags does in tcp.go for gopacket's layers.IPv4Flag.
+func formatIPv4FlagsRaw(b byte) string {
+	var parts []string
+	if b&0x40 != 0 {
+		parts = append(parts, "DF")
+	}
+	if b&0x20 != 0 {
+		parts = append(parts, "MF")
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ",")
+}
+
+// guessInitialTTL guesses the initial TTL based on observed TTL
+func guessInitialTTL(ttl int) int {
+	if ttl <= 32 {
+		return 32
+	} else if ttl <= 64 {
+		return 64
+	} else if ttl <= 128 {
+		return 128
+	}
+	return 255
+}
+
+// parseTCPOptionsRaw parses a TCP header's options block and returns
+// structured data, the same way parseTCPOptions does in tcp.go for
+// gopacket's layers.TCPOption.
+func parseTCPOptionsRaw(data []byte) ([]TCPOption, string, int, int, *TCPTimestamp) {
+	var options []TCPOption
+	var optStrs []string
+	var mss int
+	var wscale int
+	var timestamp *TCPTimestamp
+
+	pos := 0
+	for pos < len(data) {
+		kind := int(data[pos])
+
+		if kind == TCPOptionKindEndList {
+			break
+		}
+		if kind == TCPOptionKindNOP {
+			options = append(options, TCPOption{Kind: kind, Name: "NOP"})
+			optStrs = append(optStrs, "N")
+			pos++
+			continue
+		}
+
+		if pos+1 >= len(data) {
+			break
+		}
+		optLen := int(data[pos+1])
+		if optLen < 2 || pos+optLen > len(data) {
+			break
+		}
+		optData := data[pos+2 : pos+optLen]
+
+		tcpOpt := TCPOption{Kind: kind}
+		switch kind {
+		case TCPOptionKindMSS:
+			tcpOpt.Name = "MSS"
+			if len(optData) >= 2 {
+				mss = int(optData[0])<<8 | int(optData[1])
+				tcpOpt.Value = mss
+			}
+			optStrs = append(optStrs, fmt.Sprintf("M%d", mss))
+
+		case TCPOptionKindWScale:
+			tcpOpt.Name = "WScale"
+			if len(optData) >= 1 {
+				wscale = int(optData[0])
+				tcpOpt.Value = wscale
+			}
+			optStrs = append(optStrs, fmt.Sprintf("W%d", wscale))
+
+		case TCPOptionKindSACKPerm:
+			tcpOpt.Name = "SACK_PERM"
+			optStrs = append(optStrs, "S")
+
+		case TCPOptionKindSACK:
+			tcpOpt.Name = "SACK"
+			optStrs = append(optStrs, "K")
+
+		case TCPOptionKindTimestamp:
+			tcpOpt.Name = "Timestamp"
+			if len(optData) >= 8 {
+				tsval := binary.BigEndian.Uint32(optData[0:4])
+				tsecr := binary.BigEndian.Uint32(optData[4:8])
+				tcpOpt.Value = int(tsval)
+				timestamp = &TCPTimestamp{
+					TSval:  tsval,
+					TSecr:  tsecr,
+					Uptime: estimateUptime(tsval),
+				}
+			}
+			optStrs = append(optStrs, "T")
+
+		default:
+			tcpOpt.Name = fmt.Sprintf("Unknown(%d)", kind)
+			optStrs = append(optStrs, fmt.Sprintf("U%d", kind))
+		}
+
+		options = append(options, tcpOpt)
+		pos += optLen
+	}
+
+	return options, strings.Join(optStrs, ","), mss, wscale, timestamp
+}
+
+// estimateUptime estimates system uptime from a TCP timestamp, assuming the
+// common 1000Hz tick rate (see tcp.go's estimateUptime - this is the same
+// rough estimate, duplicated for this build's independent TCPTimestamp type).
+func estimateUptime(tsval uint32) string {
+	seconds := tsval / 1000
+	if seconds == 0 {
+		return ""
+	}
+
+	days := seconds / 86400
+	hours := (seconds % 86400) / 3600
+	minutes := (seconds % 3600) / 60
+
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh %dm", days, hours, minutes)
+	} else if hours > 0 {
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	} else if minutes > 0 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%ds", seconds)
+}
+
+// inferOS infers operating system from TCP/IP fingerprint. When a p0f
+// signature database is loaded, prefer its fuzzy-matched verdict; fall back
+// to the hardcoded TTL/window heuristics otherwise.
+func inferOS(fp *TCPIPFingerprint) (string, string) {
+	if label, _, confidence := MatchSignature(fp); confidence != "" {
+		return label, confidence
+	}
+
+	hasTimestamp := fp.Timestamp != nil
+
+	switch fp.InitialTTL {
+	case 128:
+		if !hasTimestamp {
+			return "Windows", "high"
+		}
+		return "Windows", "medium"
+
+	case 64:
+		if fp.WindowSize == 65535 {
+			return "macOS/iOS", "medium"
+		}
+		if fp.WindowSize < 20000 && hasTimestamp {
+			return "Android", "low"
+		}
+		if fp.WindowSize > 20000 && hasTimestamp {
+			return "Linux", "medium"
+		}
+		return "Linux/Unix", "low"
+
+	case 255:
+		return "Network Device", "medium"
+
+	case 32:
+		return "Embedded/Old", "low"
+	}
+
+	return "Unknown", "low"
+}
+
+// generateSignature generates a hash signature for the TCP fingerprint
+func generateSignature(fp *TCPIPFingerprint) string {
+	sigStr := fmt.Sprintf("%d:%d:%s:%d:%s",
+		fp.IPVersion,
+		fp.InitialTTL,
+		fp.OptionsStr,
+		fp.WindowSize,
+		fp.IPFlags,
+	)
+
+	hash := md5.Sum([]byte(sigStr))
+	return hex.EncodeToString(hash[:])
+}
+
+// GetTCPFingerprint retrieves the client SYN fingerprint for an IP address,
+// bumping its LastSeen in the cache.
+func GetTCPFingerprint(ip string) *TCPIPFingerprint {
+	return tcpFingerprintStoreByType.Get(typeStoreKey(ip, PacketTypeSYN))
+}
+
+// GetTCPFingerprintByType retrieves a fingerprint for a specific packet type
+// ("syn", "syn_ack", or "rst") observed from the given IP, bumping its LastSeen.
+func GetTCPFingerprintByType(ip, packetType string) *TCPIPFingerprint {
+	return tcpFingerprintStoreByType.Get(typeStoreKey(ip, packetType))
+}
+
+// TCPFingerprintCacheStats exposes cache observability counters for the
+// /api/all endpoint and similar diagnostics.
+func TCPFingerprintCacheStats() CacheStats {
+	return tcpFingerprintStoreByType.Stats()
+}
+
+// SnapshotTCPFingerprints returns a stable copy of all cached TCP
+// fingerprints for JSON serialization without holding the cache's lock.
+func SnapshotTCPFingerprints() map[string]*TCPIPFingerprint {
+	return tcpFingerprintStoreByType.Snapshot()
+}
+
+// CheckConsistency checks for anomalies between TCP fingerprint and User-Agent
+func CheckConsistency(tcpFp *TCPIPFingerprint, userAgent string) []string {
+	if tcpFp == nil {
+		return nil
+	}
+
+	var anomalies []string
+
+	claimedOS := parseOSFromUA(userAgent)
+
+	if claimedOS != "" && !osMatches(claimedOS, tcpFp.InferredOS) {
+		anomalies = append(anomalies,
+			fmt.Sprintf("OS_MISMATCH: UA claims %s, TCP fingerprint suggests %s",
+				claimedOS, tcpFp.InferredOS))
+	}
+
+	if strings.Contains(strings.ToLower(claimedOS), "windows") && tcpFp.Timestamp != nil {
+		anomalies = append(anomalies,
+			"TCP_TIMESTAMP_ANOMALY: Windows typically doesn't send TCP Timestamp option")
+	}
+
+	if tcpFp.WindowSize == 65535 && !strings.Contains(tcpFp.InferredOS, "macOS") && !strings.Contains(tcpFp.InferredOS, "iOS") {
+		anomalies = append(anomalies,
+			"DEFAULT_WINDOW: Using default TCP window size 65535, possible bot/script")
+	}
+
+	if tcpFp.Timestamp != nil && tcpFp.Timestamp.TSval > 0 {
+		uptimeSeconds := tcpFp.Timestamp.TSval / 1000
+		if uptimeSeconds < 600 {
+			anomalies = append(anomalies,
+				fmt.Sprintf("SHORT_UPTIME: System uptime ~%s, possibly a newly started container/VM",
+					tcpFp.Timestamp.Uptime))
+		}
+	}
+
+	if len(tcpFp.Options) < 3 {
+		anomalies = append(anomalies,
+			"MINIMAL_OPTIONS: Very few TCP options, unusual for modern browsers")
+	}
+
+	anomalies = append(anomalies, natAnomalyStrings(tcpFp.NAT)...)
+
+	if tcpFp.Link != nil && tcpFp.Link.TunnelSuspected && claimedOS != "" {
+		anomalies = append(anomalies,
+			fmt.Sprintf("TUNNEL_DETECTED: link layer looks like %s (MTU %d), but UA claims %s",
+				tcpFp.Link.LinkType, tcpFp.Link.InferredMTU, claimedOS))
+	}
+
+	return anomalies
+}
+
+// parseOSFromUA extracts OS information from User-Agent string
+func parseOSFromUA(ua string) string {
+	ua = strings.ToLower(ua)
+	switch {
+	case strings.Contains(ua, "windows"):
+		return "Windows"
+	case strings.Contains(ua, "macintosh") || strings.Contains(ua, "mac os"):
+		return "macOS"
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad"):
+		return "iOS"
+	case strings.Contains(ua, "android"):
+		return "Android"
+	case strings.Contains(ua, "linux"):
+		return "Linux"
+	case strings.Contains(ua, "cros"):
+		return "ChromeOS"
+	}
+	return ""
+}
+
+// osMatches checks if claimed OS matches inferred OS
+func osMatches(claimed, inferred string) bool {
+	claimed = strings.ToLower(claimed)
+	inferred = strings.ToLower(inferred)
+
+	if strings.Contains(inferred, claimed) || strings.Contains(claimed, inferred) {
+		return true
+	}
+	if claimed == "windows" && strings.Contains(inferred, "windows") {
+		return true
+	}
+	if (claimed == "macos" || claimed == "ios") &&
+		(strings.Contains(inferred, "macos") || strings.Contains(inferred, "ios")) {
+		return true
+	}
+	if (claimed == "linux" || claimed == "android") &&
+		(strings.Contains(inferred, "linux") || strings.Contains(inferred, "android") || strings.Contains(inferred, "unix")) {
+		return true
+	}
+	return false
+}
+
+// CleanupOldFingerprints periodically sweeps entries older than maxAge from
+// the TTL+LRU cache.
+func CleanupOldFingerprints(maxAge time.Duration) {
+	ticker := time.NewTicker(maxAge / 2)
+	go func() {
+		for range ticker.C {
+			before := tcpFingerprintStoreByType.Stats()
+			tcpFingerprintStoreByType.SweepExpired()
+			after := tcpFingerprintStoreByType.Stats()
+			if after.Evictions > before.Evictions {
+				log.Printf("[TCP] Swept %d expired fingerprint(s), cache size now %d",
+					after.Evictions-before.Evictions, after.Size)
+			}
+		}
+	}()
+}
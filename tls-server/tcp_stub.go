@@ -1,5 +1,11 @@
-//go:build nolibpcap || (!linux && !darwin && !windows)
-// +build nolibpcap !linux,!darwin,!windows
+//go:build (nolibpcap && !linux && !darwin) || (!nolibpcap && !linux && !darwin && !windows)
+
+// Linux and Darwin now have real capture backends under -tags nolibpcap (see
+// tcp_nolibpcap.go, capture_raw_linux.go, capture_raw_bsd.go), so this stub is
+// left covering only: a nolibpcap build on any other OS, and a normal build on
+// an OS with neither libpcap nor a pure-Go backend. That combination isn't
+// expressible as a legacy build-tag comment line, so this file only carries
+// the modern //go:build constraint.
 
 package main
 
@@ -12,6 +18,7 @@ import (
 
 // TCPIPFingerprint represents TCP/IP layer fingerprint (stub)
 type TCPIPFingerprint struct {
+	PacketType   string        `json:"packet_type,omitempty"`
 	TTL          int           `json:"ttl"`
 	InitialTTL   int           `json:"initial_ttl"`
 	IPVersion    int           `json:"ip_version"`
@@ -26,6 +33,8 @@ type TCPIPFingerprint struct {
 	InferredOS   string        `json:"inferred_os"`
 	OSConfidence string        `json:"os_confidence"`
 	Anomalies    []string      `json:"anomalies,omitempty"`
+	NAT          *NATAnalysis  `json:"nat,omitempty"`
+	Link         *LinkAnalysis `json:"link,omitempty"`
 }
 
 type TCPOption struct {
@@ -40,17 +49,48 @@ type TCPTimestamp struct {
 	Uptime string `json:"uptime,omitempty"`
 }
 
+// CaptureMode selects which TCP control packets are captured (stub)
+type CaptureMode string
+
+const (
+	CaptureModeSYN    CaptureMode = "syn"
+	CaptureModeSYNACK CaptureMode = "syn_ack"
+	CaptureModeRST    CaptureMode = "rst"
+	CaptureModeAll    CaptureMode = "all"
+)
+
+// CaptureConfig selects the packet capture backend (stub)
+type CaptureConfig struct {
+	Backend       string
+	RingSizeMB    int
+	FanoutWorkers int
+}
+
+// DefaultCaptureConfig is used wherever callers don't care about the backend.
+var DefaultCaptureConfig = CaptureConfig{Backend: "libpcap"}
+
 // StartTCPCapture is a stub that always returns nil (disabled)
 func StartTCPCapture(iface string, port int) error {
 	log.Printf("[TCP] TCP fingerprinting not available (built without libpcap support)")
 	return nil
 }
 
+// StartTCPCaptureWithConfig is a stub that always returns nil (disabled)
+func StartTCPCaptureWithConfig(iface string, port int, mode CaptureMode, cfg CaptureConfig) error {
+	log.Printf("[TCP] TCP fingerprinting not available (built without libpcap support)")
+	return nil
+}
+
 // GetTCPFingerprint always returns nil (disabled)
 func GetTCPFingerprint(ip string) *TCPIPFingerprint {
 	return nil
 }
 
+// GetTCPFingerprintByType always returns nil (disabled)
+func GetTCPFingerprintByType(ip, packetType string) *TCPIPFingerprint {
+	return nil
+}
+
 // CheckConsistency returns nil (disabled)
 func CheckConsistency(tcpFp *TCPIPFingerprint, userAgent string) []string {
 	return nil
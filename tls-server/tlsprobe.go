@@ -0,0 +1,176 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// TLSBehavior records the TLS Alert a client returned for each variant in
+// probeSequence, keyed by probe name. Two clients can present identical
+// ClientHellos (same JA3/JA4) yet disagree on how strictly they validate a
+// ServerHello - this matrix is the fingerprint dimension that tells them
+// apart (e.g. curl-with-OpenSSL vs curl-with-NSS).
+type TLSBehavior struct {
+	Probes map[string]string `json:"probes"`
+}
+
+// probeSequence is the fixed, ordered set of malformed ServerHellos used by
+// -enable-probe mode. Each variant targets one thing real TLS stacks are
+// known to diverge on when validating a ServerHello.
+var probeSequence = []struct {
+	name  string
+	build func() []byte
+}{
+	{"unsupported_version", buildProbeUnsupportedVersion},
+	{"unknown_cipher", buildProbeUnknownCipher},
+	{"truncated_extension", buildProbeTruncatedExtension},
+	{"wrong_key_share_group", buildProbeWrongKeyShareGroup},
+}
+
+var (
+	probeStore = make(map[string]*TLSBehavior)
+	probeMutex sync.Mutex
+)
+
+// runActiveProbe sends the next not-yet-tried probeSequence variant for
+// clientIP (cycling back to the start once all have been tried) in reply to
+// the ClientHello handleConnection already read, then waits briefly for the
+// client's Alert. The result is merged into clientIP's running TLSBehavior
+// and that behavior is returned so /api/fingerprint reflects it immediately.
+func runActiveProbe(conn net.Conn, clientIP string) *TLSBehavior {
+	probe := probeSequence[nextProbeIndex(clientIP)]
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write(wrapHandshakeRecord(probe.build())); err != nil {
+		return recordProbeResult(clientIP, probe.name, fmt.Sprintf("write_error: %v", err))
+	}
+
+	return recordProbeResult(clientIP, probe.name, readProbeAlert(conn))
+}
+
+func nextProbeIndex(clientIP string) int {
+	probeMutex.Lock()
+	defer probeMutex.Unlock()
+	b := probeStore[clientIP]
+	if b == nil {
+		return 0
+	}
+	return len(b.Probes) % len(probeSequence)
+}
+
+func recordProbeResult(clientIP, probeName, result string) *TLSBehavior {
+	probeMutex.Lock()
+	defer probeMutex.Unlock()
+	b := probeStore[clientIP]
+	if b == nil {
+		b = &TLSBehavior{Probes: make(map[string]string)}
+		probeStore[clientIP] = b
+	}
+	b.Probes[probeName] = result
+	return b
+}
+
+// readProbeAlert reads the single record the client sends in response to our
+// malformed ServerHello and returns its Alert description name, or a
+// placeholder describing why no Alert arrived.
+func readProbeAlert(conn net.Conn) string {
+	reader := NewTLSRecordReader(conn)
+	contentType, body, err := reader.ReadRecord()
+	if err != nil {
+		return fmt.Sprintf("no_alert: %v", err)
+	}
+	if contentType != 21 {
+		return fmt.Sprintf("unexpected_record_type(%d)", contentType)
+	}
+	if len(body) < 2 {
+		return "malformed_alert"
+	}
+	return alertName(body[1])
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
+
+func handshakeMessage(msgType byte, body []byte) []byte {
+	return append([]byte{msgType, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}, body...)
+}
+
+// probeServerHelloPrefix builds the legacy_version..compression_method
+// portion of a ServerHello body shared by every probe variant.
+func probeServerHelloPrefix(random []byte, cipherSuite uint16) []byte {
+	var body []byte
+	body = append(body, 0x03, 0x03)
+	body = append(body, random...)
+	body = append(body, 0) // legacy_session_id_echo: empty, we never request one
+	body = append(body, byte(cipherSuite>>8), byte(cipherSuite))
+	body = append(body, 0) // legacy_compression_method
+	return body
+}
+
+// buildProbeUnsupportedVersion claims a bogus supported_versions value
+// (0x7f12, a stale TLS 1.3 draft number) instead of the real 0x0304.
+func buildProbeUnsupportedVersion() []byte {
+	body := probeServerHelloPrefix(randomBytes(32), 0x1301)
+	ext := []byte{0x00, 0x2b, 0x00, 0x02, 0x7f, 0x12} // supported_versions
+	body = append(body, uint16be(len(ext))...)
+	body = append(body, ext...)
+	return handshakeMessage(2, body)
+}
+
+// buildProbeUnknownCipher selects TLS_NULL_WITH_NULL_NULL (0x0000), a suite
+// no real client ever offers, alongside an otherwise valid TLS 1.3 ServerHello.
+func buildProbeUnknownCipher() []byte {
+	body := probeServerHelloPrefix(randomBytes(32), 0x0000)
+	ext := []byte{0x00, 0x2b, 0x00, 0x02, 0x03, 0x04} // supported_versions = TLS 1.3
+	body = append(body, uint16be(len(ext))...)
+	body = append(body, ext...)
+	return handshakeMessage(2, body)
+}
+
+// buildProbeTruncatedExtension declares its key_share entry 16 bytes longer
+// than the key material actually present, so a client parsing it strictly
+// reads past the extensions block instead of accepting a short key.
+func buildProbeTruncatedExtension() []byte {
+	body := probeServerHelloPrefix(randomBytes(32), 0x1301)
+
+	var ext []byte
+	ext = append(ext, 0x00, 0x2b, 0x00, 0x02, 0x03, 0x04) // supported_versions = TLS 1.3
+
+	key := randomBytes(32)
+	entry := append([]byte{0x00, 0x1d}, uint16be(len(key)+16)...) // x25519, length lies
+	entry = append(entry, key...)
+	ext = append(ext, 0x00, 0x33)
+	ext = append(ext, uint16be(len(entry))...)
+	ext = append(ext, entry...)
+
+	body = append(body, uint16be(len(ext))...)
+	body = append(body, ext...)
+	return handshakeMessage(2, body)
+}
+
+// buildProbeWrongKeyShareGroup answers with a key_share group (secp256r1)
+// that an X25519-only ClientHello never offered, which a spec-compliant
+// client must reject with illegal_parameter.
+func buildProbeWrongKeyShareGroup() []byte {
+	body := probeServerHelloPrefix(randomBytes(32), 0x1301)
+
+	var ext []byte
+	ext = append(ext, 0x00, 0x2b, 0x00, 0x02, 0x03, 0x04) // supported_versions = TLS 1.3
+
+	key := randomBytes(65) // bogus uncompressed secp256r1 point
+	entry := append([]byte{0x00, 0x17}, uint16be(len(key))...)
+	entry = append(entry, key...)
+	ext = append(ext, 0x00, 0x33)
+	ext = append(ext, uint16be(len(entry))...)
+	ext = append(ext, entry...)
+
+	body = append(body, uint16be(len(ext))...)
+	body = append(body, ext...)
+	return handshakeMessage(2, body)
+}
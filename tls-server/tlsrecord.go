@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// TLSRecordReader buffers raw bytes read from a conn and hands back either
+// whole TLS records or whole handshake messages, regardless of how TCP or
+// the record layer happened to split them up. It replaces doing a single
+// conn.Read into a fixed buffer, which silently truncates anything that
+// doesn't arrive in one read - notably ClientHellos carrying a post-quantum
+// key_share (X25519MLKEM768 pushes well past 4KB) and handshake messages
+// split across more than one record.
+//
+// It's the one place this server reads handshake bytes off the wire, so the
+// native TLS 1.3 stack (tlsstack.go), the active-probe path (tlsprobe.go),
+// and handleConnection's initial ClientHello read all share it.
+type TLSRecordReader struct {
+	conn Reader
+	buf  []byte
+
+	// pending holds record bodies of contentType Handshake (22) that have
+	// been read off the wire but not yet handed out as a full handshake
+	// message - i.e. reassembly state for ReadHandshakeMessage.
+	pending []byte
+}
+
+// Reader is the subset of net.Conn that TLSRecordReader needs, so it can be
+// used in tests or elsewhere without a real connection.
+type Reader interface {
+	Read(b []byte) (int, error)
+}
+
+// NewTLSRecordReader wraps conn for record/handshake-message reassembly.
+func NewTLSRecordReader(conn Reader) *TLSRecordReader {
+	return &TLSRecordReader{conn: conn}
+}
+
+// ReadRecord returns exactly one TLS record's content type and body,
+// buffering across as many conn.Read calls as it takes to see the record's
+// declared length.
+func (r *TLSRecordReader) ReadRecord() (contentType byte, body []byte, err error) {
+	for len(r.buf) < 5 {
+		if err := r.fill(); err != nil {
+			return 0, nil, err
+		}
+	}
+	length := int(binary.BigEndian.Uint16(r.buf[3:5]))
+	for len(r.buf) < 5+length {
+		if err := r.fill(); err != nil {
+			return 0, nil, err
+		}
+	}
+	contentType = r.buf[0]
+	body = r.buf[5 : 5+length]
+	r.buf = r.buf[5+length:]
+	return contentType, body, nil
+}
+
+func (r *TLSRecordReader) fill() error {
+	chunk := make([]byte, 4096)
+	n, err := r.conn.Read(chunk)
+	if err != nil {
+		return err
+	}
+	r.buf = append(r.buf, chunk[:n]...)
+	return nil
+}
+
+// ReadHandshakeMessage returns one complete handshake message (its 1-byte
+// type + 3-byte length prefix, plus that many bytes of body) regardless of
+// whether it arrived as multiple handshake messages coalesced into one
+// record, or as one message fragmented across several records: it keeps
+// pulling records of contentType Handshake (22) via ReadRecord and
+// accumulating their bodies in r.pending until a full message is available.
+func (r *TLSRecordReader) ReadHandshakeMessage() ([]byte, error) {
+	for len(r.pending) < 4 {
+		if err := r.fillHandshake(); err != nil {
+			return nil, err
+		}
+	}
+	msgLen := int(r.pending[1])<<16 | int(r.pending[2])<<8 | int(r.pending[3])
+	for len(r.pending) < 4+msgLen {
+		if err := r.fillHandshake(); err != nil {
+			return nil, err
+		}
+	}
+	msg := append([]byte(nil), r.pending[:4+msgLen]...)
+	r.pending = r.pending[4+msgLen:]
+	return msg, nil
+}
+
+func (r *TLSRecordReader) fillHandshake() error {
+	contentType, body, err := r.ReadRecord()
+	if err != nil {
+		return err
+	}
+	if contentType != 22 {
+		return fmt.Errorf("expected handshake record, got content type %d", contentType)
+	}
+	r.pending = append(r.pending, body...)
+	return nil
+}
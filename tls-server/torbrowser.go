@@ -0,0 +1,98 @@
+package main
+
+// This file classifies Tor Browser as a first-class client type rather than
+// letting it fall out as a plain "UA claims Firefox, TLS doesn't match"
+// consistency anomaly. Tor Browser tracks Firefox's ESR release train (the
+// UA string is Firefox's, version and all) but the Tor Project patches the
+// ClientHello to be identical across every user of a given release -
+// uniformity is the whole point, so one Tor Browser user can't be singled
+// out from another by their handshake. That produces a ClientHello that
+// reliably diverges from mainline Firefox of the same ESR version, which is
+// exactly the shape EvaluateMITMFingerprint (see mitm.go) was built to
+// score - so torBrowserCorpus is just another MITMDatabase, keyed by
+// (Family: "Tor Browser", Major: <ESR version>) instead of a regular browser.
+
+// torESRTrains lists the Firefox ESR versions Tor Browser has shipped on,
+// current first. DetectTorBrowser only bothers scoring a ClientHello when
+// the UA claims one of these - any other Firefox version can't be Tor
+// Browser by definition, ESR-only distribution being part of the project's
+// uniformity guarantee.
+var torESRTrains = map[string]bool{
+	"115": true,
+	"102": true,
+	"91":  true,
+	"78":  true,
+	"68":  true,
+	"52":  true,
+	"45":  true,
+}
+
+// tls13Ciphers is the TLS 1.3 suite trio Firefox (and therefore Tor Browser)
+// has offered since ESR 68 added TLS 1.3 support.
+var tls13Ciphers = []string{"0x1301", "0x1302", "0x1303"}
+
+// legacyFirefoxCiphers is Firefox's fixed TLS 1.2-and-below cipher list,
+// unchanged across the ESR trains this table covers.
+var legacyFirefoxCiphers = []string{
+	"0xc02b", "0xc02f", "0xc02c", "0xc030", "0xcca9", "0xcca8",
+	"0xc013", "0xc014", "0x009c", "0x009d", "0x002f", "0x0035", "0x000a",
+}
+
+// preTLS13Extensions is the extension set Firefox sent before ESR 68 added
+// TLS 1.3 (so no key_share/supported_versions/psk_key_exchange_modes yet).
+var preTLS13Extensions = []string{
+	"0x0000", "0x0017", "0xff01", "0x000a", "0x000b",
+	"0x0023", "0x0010", "0x0005", "0x000d", "0x0012",
+}
+
+// tls13Extensions adds the TLS 1.3 negotiation extensions on top of the
+// pre-1.3 set.
+var tls13Extensions = append(append([]string{}, preTLS13Extensions...), "0x0033", "0x002b", "0x002d")
+
+// firefoxSignatureAlgorithms is Firefox's fixed signature_algorithms list,
+// unchanged across the ESR trains this table covers.
+var firefoxSignatureAlgorithms = []string{
+	"ecdsa_secp256r1_sha256", "ecdsa_secp384r1_sha384",
+	"rsa_pss_rsae_sha256", "rsa_pss_rsae_sha384",
+	"rsa_pkcs1_sha256", "rsa_pkcs1_sha384",
+}
+
+// torBrowserCorpus is the built-in baseline, analogous to
+// DefaultMITMDatabase/DefaultVersionPolicy: enough to demonstrate the split
+// before an operator supplies a real, measured corpus. Tor Browser disabled
+// HTTP/2 entirely until it shipped on the ESR 91 train (fewer HTTP/2-level
+// fingerprinting surfaces to worry about), which is the one ALPN
+// distinction between the old and new entries below.
+var torBrowserCorpus = NewMapMITMDatabase([]ClientRecord{
+	{Family: "Tor Browser", Major: "45", CiphersHex: legacyFirefoxCiphers, ExtensionsHex: preTLS13Extensions,
+		ALPN: []string{"http/1.1"}, SignatureAlgorithms: firefoxSignatureAlgorithms},
+	{Family: "Tor Browser", Major: "52", CiphersHex: legacyFirefoxCiphers, ExtensionsHex: preTLS13Extensions,
+		ALPN: []string{"http/1.1"}, SignatureAlgorithms: firefoxSignatureAlgorithms},
+	{Family: "Tor Browser", Major: "68", CiphersHex: append(append([]string{}, tls13Ciphers...), legacyFirefoxCiphers...), ExtensionsHex: tls13Extensions,
+		ALPN: []string{"http/1.1"}, SignatureAlgorithms: firefoxSignatureAlgorithms},
+	{Family: "Tor Browser", Major: "78", CiphersHex: append(append([]string{}, tls13Ciphers...), legacyFirefoxCiphers...), ExtensionsHex: tls13Extensions,
+		ALPN: []string{"http/1.1"}, SignatureAlgorithms: firefoxSignatureAlgorithms},
+	{Family: "Tor Browser", Major: "91", CiphersHex: append(append([]string{}, tls13Ciphers...), legacyFirefoxCiphers...), ExtensionsHex: tls13Extensions,
+		ALPN: []string{"h2", "http/1.1"}, SignatureAlgorithms: firefoxSignatureAlgorithms},
+	{Family: "Tor Browser", Major: "102", CiphersHex: append(append([]string{}, tls13Ciphers...), legacyFirefoxCiphers...), ExtensionsHex: tls13Extensions,
+		ALPN: []string{"h2", "http/1.1"}, SignatureAlgorithms: firefoxSignatureAlgorithms},
+	{Family: "Tor Browser", Major: "115", CiphersHex: append(append([]string{}, tls13Ciphers...), legacyFirefoxCiphers...), ExtensionsHex: tls13Extensions,
+		ALPN: []string{"h2", "http/1.1"}, SignatureAlgorithms: firefoxSignatureAlgorithms},
+})
+
+// DetectTorBrowser checks whether tls matches the Tor Browser signature for
+// the Firefox ESR version a UA claims, rather than mainline Firefox of that
+// version. firefoxMajor is the UA's claimed Firefox major version (e.g.
+// majorVersion(parsedUA.BrowserVersion)). Returns false immediately for any
+// version that isn't a known ESR train - Tor Browser only ever ships on ESR.
+func DetectTorBrowser(tls *TLSFingerprint, firefoxMajor string) (bool, string, []string) {
+	if !torESRTrains[firefoxMajor] {
+		return false, "", nil
+	}
+
+	verdict, _, details := EvaluateMITMFingerprint(torBrowserCorpus, "Tor Browser", firefoxMajor, tls)
+	if verdict != VerdictMatch {
+		return false, "", nil
+	}
+	return true, firefoxMajor, details
+}